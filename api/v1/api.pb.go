@@ -1,6 +1,6 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.36.0
+// 	protoc-gen-go v1.36.8
 // 	protoc        (unknown)
 // source: api/v1/api.proto
 
@@ -11,6 +11,7 @@ import (
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
 	sync "sync"
+	unsafe "unsafe"
 )
 
 const (
@@ -293,21 +294,254 @@ func (x *ChatRealtimeResponse) GetChatCompletion() *ChatCompletion {
 	return nil
 }
 
-// others
-type ChatParams struct {
+type EmbedRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Model         string                 `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`
+	Inputs        []string               `protobuf:"bytes,2,rep,name=inputs,proto3" json:"inputs,omitempty"`
+	Dimensions    *int64                 `protobuf:"varint,3,opt,name=dimensions,proto3,oneof" json:"dimensions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmbedRequest) Reset() {
+	*x = EmbedRequest{}
+	mi := &file_api_v1_api_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmbedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmbedRequest) ProtoMessage() {}
+
+func (x *EmbedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_api_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmbedRequest.ProtoReflect.Descriptor instead.
+func (*EmbedRequest) Descriptor() ([]byte, []int) {
+	return file_api_v1_api_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *EmbedRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *EmbedRequest) GetInputs() []string {
+	if x != nil {
+		return x.Inputs
+	}
+	return nil
+}
+
+func (x *EmbedRequest) GetDimensions() int64 {
+	if x != nil && x.Dimensions != nil {
+		return *x.Dimensions
+	}
+	return 0
+}
+
+type EmbedResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Model         string                 `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`
-	Tools         []*ChatTool            `protobuf:"bytes,2,rep,name=tools,proto3" json:"tools,omitempty"`
-	Instructions  string                 `protobuf:"bytes,3,opt,name=instructions,proto3" json:"instructions,omitempty"`
-	Messages      []*ChatMessage         `protobuf:"bytes,4,rep,name=messages,proto3" json:"messages,omitempty"`
-	Voice         string                 `protobuf:"bytes,5,opt,name=voice,proto3" json:"voice,omitempty"`
+	Embeddings    []*Embedding           `protobuf:"bytes,2,rep,name=embeddings,proto3" json:"embeddings,omitempty"` // in the order of the inputs
+	Usage         *ChageUsage            `protobuf:"bytes,3,opt,name=usage,proto3" json:"usage,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
+func (x *EmbedResponse) Reset() {
+	*x = EmbedResponse{}
+	mi := &file_api_v1_api_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmbedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmbedResponse) ProtoMessage() {}
+
+func (x *EmbedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_api_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmbedResponse.ProtoReflect.Descriptor instead.
+func (*EmbedResponse) Descriptor() ([]byte, []int) {
+	return file_api_v1_api_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *EmbedResponse) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *EmbedResponse) GetEmbeddings() []*Embedding {
+	if x != nil {
+		return x.Embeddings
+	}
+	return nil
+}
+
+func (x *EmbedResponse) GetUsage() *ChageUsage {
+	if x != nil {
+		return x.Usage
+	}
+	return nil
+}
+
+type CountTokensRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChatParams    *ChatParams            `protobuf:"bytes,1,opt,name=chat_params,json=chatParams,proto3" json:"chat_params,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CountTokensRequest) Reset() {
+	*x = CountTokensRequest{}
+	mi := &file_api_v1_api_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CountTokensRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountTokensRequest) ProtoMessage() {}
+
+func (x *CountTokensRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_api_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountTokensRequest.ProtoReflect.Descriptor instead.
+func (*CountTokensRequest) Descriptor() ([]byte, []int) {
+	return file_api_v1_api_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *CountTokensRequest) GetChatParams() *ChatParams {
+	if x != nil {
+		return x.ChatParams
+	}
+	return nil
+}
+
+type CountTokensResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Model         string                 `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`
+	InputTokens   int64                  `protobuf:"varint,2,opt,name=input_tokens,json=inputTokens,proto3" json:"input_tokens,omitempty"`
+	Estimated     bool                   `protobuf:"varint,3,opt,name=estimated,proto3" json:"estimated,omitempty"` // counted by the local tokenizer
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CountTokensResponse) Reset() {
+	*x = CountTokensResponse{}
+	mi := &file_api_v1_api_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CountTokensResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountTokensResponse) ProtoMessage() {}
+
+func (x *CountTokensResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_api_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountTokensResponse.ProtoReflect.Descriptor instead.
+func (*CountTokensResponse) Descriptor() ([]byte, []int) {
+	return file_api_v1_api_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CountTokensResponse) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *CountTokensResponse) GetInputTokens() int64 {
+	if x != nil {
+		return x.InputTokens
+	}
+	return 0
+}
+
+func (x *CountTokensResponse) GetEstimated() bool {
+	if x != nil {
+		return x.Estimated
+	}
+	return false
+}
+
+// others
+type ChatParams struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Model             string                 `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`
+	Tools             []*ChatTool            `protobuf:"bytes,2,rep,name=tools,proto3" json:"tools,omitempty"`
+	Instructions      string                 `protobuf:"bytes,3,opt,name=instructions,proto3" json:"instructions,omitempty"`
+	Messages          []*ChatMessage         `protobuf:"bytes,4,rep,name=messages,proto3" json:"messages,omitempty"`
+	Voice             string                 `protobuf:"bytes,5,opt,name=voice,proto3" json:"voice,omitempty"`
+	Temperature       *float32               `protobuf:"fixed32,6,opt,name=temperature,proto3,oneof" json:"temperature,omitempty"`
+	TopP              *float32               `protobuf:"fixed32,7,opt,name=top_p,json=topP,proto3,oneof" json:"top_p,omitempty"`
+	TopK              *int32                 `protobuf:"varint,8,opt,name=top_k,json=topK,proto3,oneof" json:"top_k,omitempty"`
+	MaxTokens         *int64                 `protobuf:"varint,9,opt,name=max_tokens,json=maxTokens,proto3,oneof" json:"max_tokens,omitempty"`
+	StopSequences     []string               `protobuf:"bytes,10,rep,name=stop_sequences,json=stopSequences,proto3" json:"stop_sequences,omitempty"`
+	ResponseFormat    *ChatResponseFormat    `protobuf:"bytes,11,opt,name=response_format,json=responseFormat,proto3,oneof" json:"response_format,omitempty"`
+	ToolChoice        *ChatToolChoice        `protobuf:"bytes,12,opt,name=tool_choice,json=toolChoice,proto3,oneof" json:"tool_choice,omitempty"`
+	ParallelToolCalls *bool                  `protobuf:"varint,13,opt,name=parallel_tool_calls,json=parallelToolCalls,proto3,oneof" json:"parallel_tool_calls,omitempty"`
+	Reasoning         *ChatReasoning         `protobuf:"bytes,14,opt,name=reasoning,proto3,oneof" json:"reasoning,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
 func (x *ChatParams) Reset() {
 	*x = ChatParams{}
-	mi := &file_api_v1_api_proto_msgTypes[6]
+	mi := &file_api_v1_api_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -319,7 +553,7 @@ func (x *ChatParams) String() string {
 func (*ChatParams) ProtoMessage() {}
 
 func (x *ChatParams) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_api_proto_msgTypes[6]
+	mi := &file_api_v1_api_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -332,7 +566,7 @@ func (x *ChatParams) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatParams.ProtoReflect.Descriptor instead.
 func (*ChatParams) Descriptor() ([]byte, []int) {
-	return file_api_v1_api_proto_rawDescGZIP(), []int{6}
+	return file_api_v1_api_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *ChatParams) GetModel() string {
@@ -370,6 +604,69 @@ func (x *ChatParams) GetVoice() string {
 	return ""
 }
 
+func (x *ChatParams) GetTemperature() float32 {
+	if x != nil && x.Temperature != nil {
+		return *x.Temperature
+	}
+	return 0
+}
+
+func (x *ChatParams) GetTopP() float32 {
+	if x != nil && x.TopP != nil {
+		return *x.TopP
+	}
+	return 0
+}
+
+func (x *ChatParams) GetTopK() int32 {
+	if x != nil && x.TopK != nil {
+		return *x.TopK
+	}
+	return 0
+}
+
+func (x *ChatParams) GetMaxTokens() int64 {
+	if x != nil && x.MaxTokens != nil {
+		return *x.MaxTokens
+	}
+	return 0
+}
+
+func (x *ChatParams) GetStopSequences() []string {
+	if x != nil {
+		return x.StopSequences
+	}
+	return nil
+}
+
+func (x *ChatParams) GetResponseFormat() *ChatResponseFormat {
+	if x != nil {
+		return x.ResponseFormat
+	}
+	return nil
+}
+
+func (x *ChatParams) GetToolChoice() *ChatToolChoice {
+	if x != nil {
+		return x.ToolChoice
+	}
+	return nil
+}
+
+func (x *ChatParams) GetParallelToolCalls() bool {
+	if x != nil && x.ParallelToolCalls != nil {
+		return *x.ParallelToolCalls
+	}
+	return false
+}
+
+func (x *ChatParams) GetReasoning() *ChatReasoning {
+	if x != nil {
+		return x.Reasoning
+	}
+	return nil
+}
+
 type ChatMessage struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -381,7 +678,7 @@ type ChatMessage struct {
 
 func (x *ChatMessage) Reset() {
 	*x = ChatMessage{}
-	mi := &file_api_v1_api_proto_msgTypes[7]
+	mi := &file_api_v1_api_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -393,7 +690,7 @@ func (x *ChatMessage) String() string {
 func (*ChatMessage) ProtoMessage() {}
 
 func (x *ChatMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_api_proto_msgTypes[7]
+	mi := &file_api_v1_api_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -406,7 +703,7 @@ func (x *ChatMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatMessage.ProtoReflect.Descriptor instead.
 func (*ChatMessage) Descriptor() ([]byte, []int) {
-	return file_api_v1_api_proto_rawDescGZIP(), []int{7}
+	return file_api_v1_api_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *ChatMessage) GetId() string {
@@ -435,13 +732,14 @@ type ChatTool struct {
 	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	Desc          string                 `protobuf:"bytes,2,opt,name=desc,proto3" json:"desc,omitempty"`
 	Params        string                 `protobuf:"bytes,3,opt,name=params,proto3" json:"params,omitempty"`
+	CacheControl  *CacheControl          `protobuf:"bytes,4,opt,name=cache_control,json=cacheControl,proto3" json:"cache_control,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ChatTool) Reset() {
 	*x = ChatTool{}
-	mi := &file_api_v1_api_proto_msgTypes[8]
+	mi := &file_api_v1_api_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -453,7 +751,7 @@ func (x *ChatTool) String() string {
 func (*ChatTool) ProtoMessage() {}
 
 func (x *ChatTool) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_api_proto_msgTypes[8]
+	mi := &file_api_v1_api_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -466,7 +764,7 @@ func (x *ChatTool) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatTool.ProtoReflect.Descriptor instead.
 func (*ChatTool) Descriptor() ([]byte, []int) {
-	return file_api_v1_api_proto_rawDescGZIP(), []int{8}
+	return file_api_v1_api_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *ChatTool) GetName() string {
@@ -490,8 +788,212 @@ func (x *ChatTool) GetParams() string {
 	return ""
 }
 
+func (x *ChatTool) GetCacheControl() *CacheControl {
+	if x != nil {
+		return x.CacheControl
+	}
+	return nil
+}
+
+type ChatToolChoice struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"` // auto, none, required or function
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"` // of the function
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatToolChoice) Reset() {
+	*x = ChatToolChoice{}
+	mi := &file_api_v1_api_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatToolChoice) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatToolChoice) ProtoMessage() {}
+
+func (x *ChatToolChoice) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_api_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatToolChoice.ProtoReflect.Descriptor instead.
+func (*ChatToolChoice) Descriptor() ([]byte, []int) {
+	return file_api_v1_api_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ChatToolChoice) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ChatToolChoice) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type ChatReasoning struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Enabled         bool                   `protobuf:"varint,1,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	BudgetTokens    int64                  `protobuf:"varint,2,opt,name=budget_tokens,json=budgetTokens,proto3" json:"budget_tokens,omitempty"`
+	Effort          string                 `protobuf:"bytes,3,opt,name=effort,proto3" json:"effort,omitempty"` // minimal, low, medium or high
+	IncludeThoughts bool                   `protobuf:"varint,4,opt,name=include_thoughts,json=includeThoughts,proto3" json:"include_thoughts,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ChatReasoning) Reset() {
+	*x = ChatReasoning{}
+	mi := &file_api_v1_api_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatReasoning) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatReasoning) ProtoMessage() {}
+
+func (x *ChatReasoning) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_api_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatReasoning.ProtoReflect.Descriptor instead.
+func (*ChatReasoning) Descriptor() ([]byte, []int) {
+	return file_api_v1_api_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ChatReasoning) GetEnabled() bool {
+	if x != nil {
+		return x.Enabled
+	}
+	return false
+}
+
+func (x *ChatReasoning) GetBudgetTokens() int64 {
+	if x != nil {
+		return x.BudgetTokens
+	}
+	return 0
+}
+
+func (x *ChatReasoning) GetEffort() string {
+	if x != nil {
+		return x.Effort
+	}
+	return ""
+}
+
+func (x *ChatReasoning) GetIncludeThoughts() bool {
+	if x != nil {
+		return x.IncludeThoughts
+	}
+	return false
+}
+
+type ChatResponseFormat struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"` // text, json_object or json_schema
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Desc          string                 `protobuf:"bytes,3,opt,name=desc,proto3" json:"desc,omitempty"`
+	Schema        string                 `protobuf:"bytes,4,opt,name=schema,proto3" json:"schema,omitempty"` // json schema
+	Strict        bool                   `protobuf:"varint,5,opt,name=strict,proto3" json:"strict,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatResponseFormat) Reset() {
+	*x = ChatResponseFormat{}
+	mi := &file_api_v1_api_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatResponseFormat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatResponseFormat) ProtoMessage() {}
+
+func (x *ChatResponseFormat) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_api_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatResponseFormat.ProtoReflect.Descriptor instead.
+func (*ChatResponseFormat) Descriptor() ([]byte, []int) {
+	return file_api_v1_api_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ChatResponseFormat) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ChatResponseFormat) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ChatResponseFormat) GetDesc() string {
+	if x != nil {
+		return x.Desc
+	}
+	return ""
+}
+
+func (x *ChatResponseFormat) GetSchema() string {
+	if x != nil {
+		return x.Schema
+	}
+	return ""
+}
+
+func (x *ChatResponseFormat) GetStrict() bool {
+	if x != nil {
+		return x.Strict
+	}
+	return false
+}
+
 type ChatContent struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	CacheControl *CacheControl          `protobuf:"bytes,1,opt,name=cache_control,json=cacheControl,proto3" json:"cache_control,omitempty"` // with any content
 	// Types that are valid to be assigned to Content:
 	//
 	//	*ChatContent_Text
@@ -500,6 +1002,9 @@ type ChatContent struct {
 	//	*ChatContent_ToolCall
 	//	*ChatContent_ToolResult
 	//	*ChatContent_Audio
+	//	*ChatContent_RealtimeResponse
+	//	*ChatContent_ImageUrl
+	//	*ChatContent_File
 	Content       isChatContent_Content `protobuf_oneof:"content"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -507,7 +1012,7 @@ type ChatContent struct {
 
 func (x *ChatContent) Reset() {
 	*x = ChatContent{}
-	mi := &file_api_v1_api_proto_msgTypes[9]
+	mi := &file_api_v1_api_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -519,7 +1024,7 @@ func (x *ChatContent) String() string {
 func (*ChatContent) ProtoMessage() {}
 
 func (x *ChatContent) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_api_proto_msgTypes[9]
+	mi := &file_api_v1_api_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -532,7 +1037,14 @@ func (x *ChatContent) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatContent.ProtoReflect.Descriptor instead.
 func (*ChatContent) Descriptor() ([]byte, []int) {
-	return file_api_v1_api_proto_rawDescGZIP(), []int{9}
+	return file_api_v1_api_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ChatContent) GetCacheControl() *CacheControl {
+	if x != nil {
+		return x.CacheControl
+	}
+	return nil
 }
 
 func (x *ChatContent) GetContent() isChatContent_Content {
@@ -569,28 +1081,55 @@ func (x *ChatContent) GetRefusal() *ChatContentRefusal {
 	return nil
 }
 
-func (x *ChatContent) GetToolCall() *ChatContentToolCall {
+func (x *ChatContent) GetToolCall() *ChatContentToolCall {
+	if x != nil {
+		if x, ok := x.Content.(*ChatContent_ToolCall); ok {
+			return x.ToolCall
+		}
+	}
+	return nil
+}
+
+func (x *ChatContent) GetToolResult() *ChatContentToolResult {
+	if x != nil {
+		if x, ok := x.Content.(*ChatContent_ToolResult); ok {
+			return x.ToolResult
+		}
+	}
+	return nil
+}
+
+func (x *ChatContent) GetAudio() *ChatContentAudio {
+	if x != nil {
+		if x, ok := x.Content.(*ChatContent_Audio); ok {
+			return x.Audio
+		}
+	}
+	return nil
+}
+
+func (x *ChatContent) GetRealtimeResponse() *ChatContentRealtimeResponse {
 	if x != nil {
-		if x, ok := x.Content.(*ChatContent_ToolCall); ok {
-			return x.ToolCall
+		if x, ok := x.Content.(*ChatContent_RealtimeResponse); ok {
+			return x.RealtimeResponse
 		}
 	}
 	return nil
 }
 
-func (x *ChatContent) GetToolResult() *ChatContentToolResult {
+func (x *ChatContent) GetImageUrl() *ChatContentImageUrl {
 	if x != nil {
-		if x, ok := x.Content.(*ChatContent_ToolResult); ok {
-			return x.ToolResult
+		if x, ok := x.Content.(*ChatContent_ImageUrl); ok {
+			return x.ImageUrl
 		}
 	}
 	return nil
 }
 
-func (x *ChatContent) GetAudio() *ChatContentAudio {
+func (x *ChatContent) GetFile() *ChatContentFile {
 	if x != nil {
-		if x, ok := x.Content.(*ChatContent_Audio); ok {
-			return x.Audio
+		if x, ok := x.Content.(*ChatContent_File); ok {
+			return x.File
 		}
 	}
 	return nil
@@ -624,6 +1163,18 @@ type ChatContent_Audio struct {
 	Audio *ChatContentAudio `protobuf:"bytes,25,opt,name=audio,proto3,oneof"`
 }
 
+type ChatContent_RealtimeResponse struct {
+	RealtimeResponse *ChatContentRealtimeResponse `protobuf:"bytes,26,opt,name=realtime_response,json=realtimeResponse,proto3,oneof"`
+}
+
+type ChatContent_ImageUrl struct {
+	ImageUrl *ChatContentImageUrl `protobuf:"bytes,27,opt,name=image_url,json=imageUrl,proto3,oneof"`
+}
+
+type ChatContent_File struct {
+	File *ChatContentFile `protobuf:"bytes,28,opt,name=file,proto3,oneof"`
+}
+
 func (*ChatContent_Text) isChatContent_Content() {}
 
 func (*ChatContent_Reasoning) isChatContent_Content() {}
@@ -636,6 +1187,12 @@ func (*ChatContent_ToolResult) isChatContent_Content() {}
 
 func (*ChatContent_Audio) isChatContent_Content() {}
 
+func (*ChatContent_RealtimeResponse) isChatContent_Content() {}
+
+func (*ChatContent_ImageUrl) isChatContent_Content() {}
+
+func (*ChatContent_File) isChatContent_Content() {}
+
 type ChatContentText struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Delta         bool                   `protobuf:"varint,1,opt,name=delta,proto3" json:"delta,omitempty"`
@@ -646,7 +1203,7 @@ type ChatContentText struct {
 
 func (x *ChatContentText) Reset() {
 	*x = ChatContentText{}
-	mi := &file_api_v1_api_proto_msgTypes[10]
+	mi := &file_api_v1_api_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -658,7 +1215,7 @@ func (x *ChatContentText) String() string {
 func (*ChatContentText) ProtoMessage() {}
 
 func (x *ChatContentText) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_api_proto_msgTypes[10]
+	mi := &file_api_v1_api_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -671,7 +1228,7 @@ func (x *ChatContentText) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatContentText.ProtoReflect.Descriptor instead.
 func (*ChatContentText) Descriptor() ([]byte, []int) {
-	return file_api_v1_api_proto_rawDescGZIP(), []int{10}
+	return file_api_v1_api_proto_rawDescGZIP(), []int{17}
 }
 
 func (x *ChatContentText) GetDelta() bool {
@@ -698,7 +1255,7 @@ type ChatContentReasoning struct {
 
 func (x *ChatContentReasoning) Reset() {
 	*x = ChatContentReasoning{}
-	mi := &file_api_v1_api_proto_msgTypes[11]
+	mi := &file_api_v1_api_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -710,7 +1267,7 @@ func (x *ChatContentReasoning) String() string {
 func (*ChatContentReasoning) ProtoMessage() {}
 
 func (x *ChatContentReasoning) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_api_proto_msgTypes[11]
+	mi := &file_api_v1_api_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -723,7 +1280,7 @@ func (x *ChatContentReasoning) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatContentReasoning.ProtoReflect.Descriptor instead.
 func (*ChatContentReasoning) Descriptor() ([]byte, []int) {
-	return file_api_v1_api_proto_rawDescGZIP(), []int{11}
+	return file_api_v1_api_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *ChatContentReasoning) GetText() string {
@@ -749,7 +1306,7 @@ type ChatContentRefusal struct {
 
 func (x *ChatContentRefusal) Reset() {
 	*x = ChatContentRefusal{}
-	mi := &file_api_v1_api_proto_msgTypes[12]
+	mi := &file_api_v1_api_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -761,7 +1318,7 @@ func (x *ChatContentRefusal) String() string {
 func (*ChatContentRefusal) ProtoMessage() {}
 
 func (x *ChatContentRefusal) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_api_proto_msgTypes[12]
+	mi := &file_api_v1_api_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -774,7 +1331,7 @@ func (x *ChatContentRefusal) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatContentRefusal.ProtoReflect.Descriptor instead.
 func (*ChatContentRefusal) Descriptor() ([]byte, []int) {
-	return file_api_v1_api_proto_rawDescGZIP(), []int{12}
+	return file_api_v1_api_proto_rawDescGZIP(), []int{19}
 }
 
 func (x *ChatContentRefusal) GetText() string {
@@ -785,17 +1342,19 @@ func (x *ChatContentRefusal) GetText() string {
 }
 
 type ChatContentToolCall struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	Arguments     string                 `protobuf:"bytes,3,opt,name=arguments,proto3" json:"arguments,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name      string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Arguments string                 `protobuf:"bytes,3,opt,name=arguments,proto3" json:"arguments,omitempty"`
+	// position of the call in a stream, deltas without id are merged by it
+	Index         int32 `protobuf:"varint,4,opt,name=index,proto3" json:"index,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ChatContentToolCall) Reset() {
 	*x = ChatContentToolCall{}
-	mi := &file_api_v1_api_proto_msgTypes[13]
+	mi := &file_api_v1_api_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -807,7 +1366,7 @@ func (x *ChatContentToolCall) String() string {
 func (*ChatContentToolCall) ProtoMessage() {}
 
 func (x *ChatContentToolCall) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_api_proto_msgTypes[13]
+	mi := &file_api_v1_api_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -820,7 +1379,7 @@ func (x *ChatContentToolCall) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatContentToolCall.ProtoReflect.Descriptor instead.
 func (*ChatContentToolCall) Descriptor() ([]byte, []int) {
-	return file_api_v1_api_proto_rawDescGZIP(), []int{13}
+	return file_api_v1_api_proto_rawDescGZIP(), []int{20}
 }
 
 func (x *ChatContentToolCall) GetId() string {
@@ -844,6 +1403,13 @@ func (x *ChatContentToolCall) GetArguments() string {
 	return ""
 }
 
+func (x *ChatContentToolCall) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
 type ChatContentToolResult struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -855,7 +1421,7 @@ type ChatContentToolResult struct {
 
 func (x *ChatContentToolResult) Reset() {
 	*x = ChatContentToolResult{}
-	mi := &file_api_v1_api_proto_msgTypes[14]
+	mi := &file_api_v1_api_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -867,7 +1433,7 @@ func (x *ChatContentToolResult) String() string {
 func (*ChatContentToolResult) ProtoMessage() {}
 
 func (x *ChatContentToolResult) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_api_proto_msgTypes[14]
+	mi := &file_api_v1_api_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -880,7 +1446,7 @@ func (x *ChatContentToolResult) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatContentToolResult.ProtoReflect.Descriptor instead.
 func (*ChatContentToolResult) Descriptor() ([]byte, []int) {
-	return file_api_v1_api_proto_rawDescGZIP(), []int{14}
+	return file_api_v1_api_proto_rawDescGZIP(), []int{21}
 }
 
 func (x *ChatContentToolResult) GetId() string {
@@ -910,13 +1476,14 @@ type ChatContentAudio struct {
 	Data          string                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
 	Format        string                 `protobuf:"bytes,3,opt,name=format,proto3" json:"format,omitempty"`
 	Transcript    string                 `protobuf:"bytes,4,opt,name=transcript,proto3" json:"transcript,omitempty"`
+	Id            string                 `protobuf:"bytes,5,opt,name=id,proto3" json:"id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ChatContentAudio) Reset() {
 	*x = ChatContentAudio{}
-	mi := &file_api_v1_api_proto_msgTypes[15]
+	mi := &file_api_v1_api_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -928,7 +1495,7 @@ func (x *ChatContentAudio) String() string {
 func (*ChatContentAudio) ProtoMessage() {}
 
 func (x *ChatContentAudio) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_api_proto_msgTypes[15]
+	mi := &file_api_v1_api_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -941,7 +1508,7 @@ func (x *ChatContentAudio) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatContentAudio.ProtoReflect.Descriptor instead.
 func (*ChatContentAudio) Descriptor() ([]byte, []int) {
-	return file_api_v1_api_proto_rawDescGZIP(), []int{15}
+	return file_api_v1_api_proto_rawDescGZIP(), []int{22}
 }
 
 func (x *ChatContentAudio) GetDelta() bool {
@@ -972,18 +1539,184 @@ func (x *ChatContentAudio) GetTranscript() string {
 	return ""
 }
 
+func (x *ChatContentAudio) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ChatContentImageUrl struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Detail        string                 `protobuf:"bytes,2,opt,name=detail,proto3" json:"detail,omitempty"`
+	Format        string                 `protobuf:"bytes,3,opt,name=format,proto3" json:"format,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatContentImageUrl) Reset() {
+	*x = ChatContentImageUrl{}
+	mi := &file_api_v1_api_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatContentImageUrl) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatContentImageUrl) ProtoMessage() {}
+
+func (x *ChatContentImageUrl) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_api_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatContentImageUrl.ProtoReflect.Descriptor instead.
+func (*ChatContentImageUrl) Descriptor() ([]byte, []int) {
+	return file_api_v1_api_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ChatContentImageUrl) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *ChatContentImageUrl) GetDetail() string {
+	if x != nil {
+		return x.Detail
+	}
+	return ""
+}
+
+func (x *ChatContentImageUrl) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+type ChatContentFile struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MimeType      string                 `protobuf:"bytes,1,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Data          string                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"` // base64
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatContentFile) Reset() {
+	*x = ChatContentFile{}
+	mi := &file_api_v1_api_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatContentFile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatContentFile) ProtoMessage() {}
+
+func (x *ChatContentFile) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_api_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatContentFile.ProtoReflect.Descriptor instead.
+func (*ChatContentFile) Descriptor() ([]byte, []int) {
+	return file_api_v1_api_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *ChatContentFile) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *ChatContentFile) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ChatContentFile) GetData() string {
+	if x != nil {
+		return x.Data
+	}
+	return ""
+}
+
+// asks a realtime session to create a response
+type ChatContentRealtimeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatContentRealtimeResponse) Reset() {
+	*x = ChatContentRealtimeResponse{}
+	mi := &file_api_v1_api_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatContentRealtimeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatContentRealtimeResponse) ProtoMessage() {}
+
+func (x *ChatContentRealtimeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_api_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatContentRealtimeResponse.ProtoReflect.Descriptor instead.
+func (*ChatContentRealtimeResponse) Descriptor() ([]byte, []int) {
+	return file_api_v1_api_proto_rawDescGZIP(), []int{25}
+}
+
 type ChageUsage struct {
 	state            protoimpl.MessageState `protogen:"open.v1"`
 	PromptTokens     int64                  `protobuf:"varint,1,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
 	CompletionTokens int64                  `protobuf:"varint,2,opt,name=completion_tokens,json=completionTokens,proto3" json:"completion_tokens,omitempty"`
 	TotalTokens      int64                  `protobuf:"varint,3,opt,name=total_tokens,json=totalTokens,proto3" json:"total_tokens,omitempty"`
+	CachedTokens     int64                  `protobuf:"varint,4,opt,name=cached_tokens,json=cachedTokens,proto3" json:"cached_tokens,omitempty"`
+	CacheWriteTokens int64                  `protobuf:"varint,5,opt,name=cache_write_tokens,json=cacheWriteTokens,proto3" json:"cache_write_tokens,omitempty"`
 	unknownFields    protoimpl.UnknownFields
 	sizeCache        protoimpl.SizeCache
 }
 
 func (x *ChageUsage) Reset() {
 	*x = ChageUsage{}
-	mi := &file_api_v1_api_proto_msgTypes[16]
+	mi := &file_api_v1_api_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -995,7 +1728,7 @@ func (x *ChageUsage) String() string {
 func (*ChageUsage) ProtoMessage() {}
 
 func (x *ChageUsage) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_api_proto_msgTypes[16]
+	mi := &file_api_v1_api_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1008,7 +1741,7 @@ func (x *ChageUsage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChageUsage.ProtoReflect.Descriptor instead.
 func (*ChageUsage) Descriptor() ([]byte, []int) {
-	return file_api_v1_api_proto_rawDescGZIP(), []int{16}
+	return file_api_v1_api_proto_rawDescGZIP(), []int{26}
 }
 
 func (x *ChageUsage) GetPromptTokens() int64 {
@@ -1032,19 +1765,78 @@ func (x *ChageUsage) GetTotalTokens() int64 {
 	return 0
 }
 
+func (x *ChageUsage) GetCachedTokens() int64 {
+	if x != nil {
+		return x.CachedTokens
+	}
+	return 0
+}
+
+func (x *ChageUsage) GetCacheWriteTokens() int64 {
+	if x != nil {
+		return x.CacheWriteTokens
+	}
+	return 0
+}
+
+type CacheControl struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ttl           string                 `protobuf:"bytes,1,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CacheControl) Reset() {
+	*x = CacheControl{}
+	mi := &file_api_v1_api_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CacheControl) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CacheControl) ProtoMessage() {}
+
+func (x *CacheControl) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_api_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CacheControl.ProtoReflect.Descriptor instead.
+func (*CacheControl) Descriptor() ([]byte, []int) {
+	return file_api_v1_api_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *CacheControl) GetTtl() string {
+	if x != nil {
+		return x.Ttl
+	}
+	return ""
+}
+
 type ChatCompletion struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Delta         bool                   `protobuf:"varint,1,opt,name=delta,proto3" json:"delta,omitempty"`
 	Model         string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`
 	Message       *ChatMessage           `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
 	Usage         *ChageUsage            `protobuf:"bytes,4,opt,name=usage,proto3" json:"usage,omitempty"`
+	FinishReason  string                 `protobuf:"bytes,5,opt,name=finish_reason,json=finishReason,proto3" json:"finish_reason,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ChatCompletion) Reset() {
 	*x = ChatCompletion{}
-	mi := &file_api_v1_api_proto_msgTypes[17]
+	mi := &file_api_v1_api_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1056,7 +1848,7 @@ func (x *ChatCompletion) String() string {
 func (*ChatCompletion) ProtoMessage() {}
 
 func (x *ChatCompletion) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_api_proto_msgTypes[17]
+	mi := &file_api_v1_api_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1069,7 +1861,7 @@ func (x *ChatCompletion) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatCompletion.ProtoReflect.Descriptor instead.
 func (*ChatCompletion) Descriptor() ([]byte, []int) {
-	return file_api_v1_api_proto_rawDescGZIP(), []int{17}
+	return file_api_v1_api_proto_rawDescGZIP(), []int{28}
 }
 
 func (x *ChatCompletion) GetDelta() bool {
@@ -1100,6 +1892,65 @@ func (x *ChatCompletion) GetUsage() *ChageUsage {
 	return nil
 }
 
+func (x *ChatCompletion) GetFinishReason() string {
+	if x != nil {
+		return x.FinishReason
+	}
+	return ""
+}
+
+type Embedding struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Vector        []float32              `protobuf:"fixed32,2,rep,packed,name=vector,proto3" json:"vector,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Embedding) Reset() {
+	*x = Embedding{}
+	mi := &file_api_v1_api_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Embedding) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Embedding) ProtoMessage() {}
+
+func (x *Embedding) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_api_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Embedding.ProtoReflect.Descriptor instead.
+func (*Embedding) Descriptor() ([]byte, []int) {
+	return file_api_v1_api_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *Embedding) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *Embedding) GetVector() []float32 {
+	if x != nil {
+		return x.Vector
+	}
+	return nil
+}
+
 type ChatRealtimeRequest_Init struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	ChatParams    *ChatParams            `protobuf:"bytes,1,opt,name=chat_params,json=chatParams,proto3" json:"chat_params,omitempty"`
@@ -1109,7 +1960,7 @@ type ChatRealtimeRequest_Init struct {
 
 func (x *ChatRealtimeRequest_Init) Reset() {
 	*x = ChatRealtimeRequest_Init{}
-	mi := &file_api_v1_api_proto_msgTypes[18]
+	mi := &file_api_v1_api_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1121,7 +1972,7 @@ func (x *ChatRealtimeRequest_Init) String() string {
 func (*ChatRealtimeRequest_Init) ProtoMessage() {}
 
 func (x *ChatRealtimeRequest_Init) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_api_proto_msgTypes[18]
+	mi := &file_api_v1_api_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1146,233 +1997,264 @@ func (x *ChatRealtimeRequest_Init) GetChatParams() *ChatParams {
 
 var File_api_v1_api_proto protoreflect.FileDescriptor
 
-var file_api_v1_api_proto_rawDesc = []byte{
-	0x0a, 0x10, 0x61, 0x70, 0x69, 0x2f, 0x76, 0x31, 0x2f, 0x61, 0x70, 0x69, 0x2e, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x12, 0x0d, 0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76,
-	0x31, 0x22, 0x49, 0x0a, 0x0b, 0x43, 0x68, 0x61, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
-	0x12, 0x3a, 0x0a, 0x0b, 0x63, 0x68, 0x61, 0x74, 0x5f, 0x70, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61,
-	0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73,
-	0x52, 0x0a, 0x63, 0x68, 0x61, 0x74, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x22, 0x56, 0x0a, 0x0c,
-	0x43, 0x68, 0x61, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x46, 0x0a, 0x0f,
-	0x63, 0x68, 0x61, 0x74, 0x5f, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x69, 0x6f, 0x6e, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61,
-	0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65,
-	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0e, 0x63, 0x68, 0x61, 0x74, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65,
-	0x74, 0x69, 0x6f, 0x6e, 0x22, 0x4f, 0x0a, 0x11, 0x43, 0x68, 0x61, 0x74, 0x53, 0x74, 0x72, 0x65,
-	0x61, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3a, 0x0a, 0x0b, 0x63, 0x68, 0x61,
-	0x74, 0x5f, 0x70, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19,
-	0x2e, 0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43,
-	0x68, 0x61, 0x74, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x52, 0x0a, 0x63, 0x68, 0x61, 0x74, 0x50,
-	0x61, 0x72, 0x61, 0x6d, 0x73, 0x22, 0x5c, 0x0a, 0x12, 0x43, 0x68, 0x61, 0x74, 0x53, 0x74, 0x72,
-	0x65, 0x61, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x46, 0x0a, 0x0f, 0x63,
-	0x68, 0x61, 0x74, 0x5f, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70,
-	0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74,
-	0x69, 0x6f, 0x6e, 0x52, 0x0e, 0x63, 0x68, 0x61, 0x74, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74,
-	0x69, 0x6f, 0x6e, 0x22, 0xcc, 0x01, 0x0a, 0x13, 0x43, 0x68, 0x61, 0x74, 0x52, 0x65, 0x61, 0x6c,
-	0x74, 0x69, 0x6d, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3b, 0x0a, 0x04, 0x69,
-	0x6e, 0x69, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x27, 0x2e, 0x6c, 0x6c, 0x6d, 0x61,
-	0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x52, 0x65,
-	0x61, 0x6c, 0x74, 0x69, 0x6d, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x2e, 0x49, 0x6e,
-	0x69, 0x74, 0x52, 0x04, 0x69, 0x6e, 0x69, 0x74, 0x12, 0x34, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73,
-	0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x6c, 0x6c, 0x6d, 0x61,
-	0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x4d, 0x65,
-	0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x1a, 0x42,
-	0x0a, 0x04, 0x49, 0x6e, 0x69, 0x74, 0x12, 0x3a, 0x0a, 0x0b, 0x63, 0x68, 0x61, 0x74, 0x5f, 0x70,
-	0x61, 0x72, 0x61, 0x6d, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x6c, 0x6c,
-	0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x74,
-	0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x52, 0x0a, 0x63, 0x68, 0x61, 0x74, 0x50, 0x61, 0x72, 0x61,
-	0x6d, 0x73, 0x22, 0x5e, 0x0a, 0x14, 0x43, 0x68, 0x61, 0x74, 0x52, 0x65, 0x61, 0x6c, 0x74, 0x69,
-	0x6d, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x46, 0x0a, 0x0f, 0x63, 0x68,
-	0x61, 0x74, 0x5f, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70, 0x69,
-	0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x69,
-	0x6f, 0x6e, 0x52, 0x0e, 0x63, 0x68, 0x61, 0x74, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x69,
-	0x6f, 0x6e, 0x22, 0xc3, 0x01, 0x0a, 0x0a, 0x43, 0x68, 0x61, 0x74, 0x50, 0x61, 0x72, 0x61, 0x6d,
-	0x73, 0x12, 0x14, 0x0a, 0x05, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x05, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x12, 0x2d, 0x0a, 0x05, 0x74, 0x6f, 0x6f, 0x6c, 0x73,
-	0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e,
-	0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x54, 0x6f, 0x6f, 0x6c, 0x52,
-	0x05, 0x74, 0x6f, 0x6f, 0x6c, 0x73, 0x12, 0x22, 0x0a, 0x0c, 0x69, 0x6e, 0x73, 0x74, 0x72, 0x75,
-	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x69, 0x6e,
-	0x73, 0x74, 0x72, 0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x36, 0x0a, 0x08, 0x6d, 0x65,
-	0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x6c,
-	0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61,
-	0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x08, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
-	0x65, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x6f, 0x69, 0x63, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x05, 0x76, 0x6f, 0x69, 0x63, 0x65, 0x22, 0x69, 0x0a, 0x0b, 0x43, 0x68, 0x61, 0x74,
-	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x12, 0x36, 0x0a, 0x08, 0x63,
-	0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
-	0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68,
-	0x61, 0x74, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x52, 0x08, 0x63, 0x6f, 0x6e, 0x74, 0x65,
-	0x6e, 0x74, 0x73, 0x22, 0x4a, 0x0a, 0x08, 0x43, 0x68, 0x61, 0x74, 0x54, 0x6f, 0x6f, 0x6c, 0x12,
-	0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e,
-	0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x65, 0x73, 0x63, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x04, 0x64, 0x65, 0x73, 0x63, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x61, 0x72, 0x61, 0x6d,
-	0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x70, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x22,
-	0x97, 0x03, 0x0a, 0x0b, 0x43, 0x68, 0x61, 0x74, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x12,
-	0x34, 0x0a, 0x04, 0x74, 0x65, 0x78, 0x74, 0x18, 0x14, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e, 0x2e,
-	0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68,
-	0x61, 0x74, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x65, 0x78, 0x74, 0x48, 0x00, 0x52,
-	0x04, 0x74, 0x65, 0x78, 0x74, 0x12, 0x43, 0x0a, 0x09, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x69,
-	0x6e, 0x67, 0x18, 0x15, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x6c, 0x6c, 0x6d, 0x61, 0x70,
-	0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x43, 0x6f, 0x6e,
-	0x74, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x69, 0x6e, 0x67, 0x48, 0x00, 0x52,
-	0x09, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x69, 0x6e, 0x67, 0x12, 0x3d, 0x0a, 0x07, 0x72, 0x65,
-	0x66, 0x75, 0x73, 0x61, 0x6c, 0x18, 0x16, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x6c, 0x6c,
-	0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x74,
-	0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x66, 0x75, 0x73, 0x61, 0x6c, 0x48, 0x00,
-	0x52, 0x07, 0x72, 0x65, 0x66, 0x75, 0x73, 0x61, 0x6c, 0x12, 0x41, 0x0a, 0x09, 0x74, 0x6f, 0x6f,
-	0x6c, 0x5f, 0x63, 0x61, 0x6c, 0x6c, 0x18, 0x17, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x6c,
-	0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61,
-	0x74, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x6f, 0x6f, 0x6c, 0x43, 0x61, 0x6c, 0x6c,
-	0x48, 0x00, 0x52, 0x08, 0x74, 0x6f, 0x6f, 0x6c, 0x43, 0x61, 0x6c, 0x6c, 0x12, 0x47, 0x0a, 0x0b,
-	0x74, 0x6f, 0x6f, 0x6c, 0x5f, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x18, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x24, 0x2e, 0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76,
-	0x31, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x6f, 0x6f,
-	0x6c, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x48, 0x00, 0x52, 0x0a, 0x74, 0x6f, 0x6f, 0x6c, 0x52,
-	0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x37, 0x0a, 0x05, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x18, 0x19,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70,
-	0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74,
-	0x41, 0x75, 0x64, 0x69, 0x6f, 0x48, 0x00, 0x52, 0x05, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x42, 0x09,
-	0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x22, 0x3b, 0x0a, 0x0f, 0x43, 0x68, 0x61,
-	0x74, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x65, 0x78, 0x74, 0x12, 0x14, 0x0a, 0x05,
-	0x64, 0x65, 0x6c, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x64, 0x65, 0x6c,
-	0x74, 0x61, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x78, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x04, 0x74, 0x65, 0x78, 0x74, 0x22, 0x56, 0x0a, 0x14, 0x43, 0x68, 0x61, 0x74, 0x43, 0x6f,
-	0x6e, 0x74, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x69, 0x6e, 0x67, 0x12, 0x12,
-	0x0a, 0x04, 0x74, 0x65, 0x78, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x65,
-	0x78, 0x74, 0x12, 0x2a, 0x0a, 0x10, 0x74, 0x68, 0x6f, 0x75, 0x67, 0x68, 0x74, 0x53, 0x69, 0x67,
-	0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x10, 0x74, 0x68,
-	0x6f, 0x75, 0x67, 0x68, 0x74, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x22, 0x28,
-	0x0a, 0x12, 0x43, 0x68, 0x61, 0x74, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x66,
-	0x75, 0x73, 0x61, 0x6c, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x78, 0x74, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x04, 0x74, 0x65, 0x78, 0x74, 0x22, 0x57, 0x0a, 0x13, 0x43, 0x68, 0x61, 0x74,
-	0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x6f, 0x6f, 0x6c, 0x43, 0x61, 0x6c, 0x6c, 0x12,
-	0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12,
-	0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e,
-	0x61, 0x6d, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x61, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73,
-	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x61, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74,
-	0x73, 0x22, 0x53, 0x0a, 0x15, 0x43, 0x68, 0x61, 0x74, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74,
-	0x54, 0x6f, 0x6f, 0x6c, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61,
-	0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x16,
-	0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
-	0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x22, 0x74, 0x0a, 0x10, 0x43, 0x68, 0x61, 0x74, 0x43, 0x6f,
-	0x6e, 0x74, 0x65, 0x6e, 0x74, 0x41, 0x75, 0x64, 0x69, 0x6f, 0x12, 0x14, 0x0a, 0x05, 0x64, 0x65,
-	0x6c, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x64, 0x65, 0x6c, 0x74, 0x61,
-	0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
-	0x64, 0x61, 0x74, 0x61, 0x12, 0x16, 0x0a, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x18, 0x03,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x1e, 0x0a, 0x0a,
-	0x74, 0x72, 0x61, 0x6e, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x0a, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x22, 0x81, 0x01, 0x0a,
-	0x0a, 0x43, 0x68, 0x61, 0x67, 0x65, 0x55, 0x73, 0x61, 0x67, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x70,
-	0x72, 0x6f, 0x6d, 0x70, 0x74, 0x5f, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x73, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x03, 0x52, 0x0c, 0x70, 0x72, 0x6f, 0x6d, 0x70, 0x74, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x73,
-	0x12, 0x2b, 0x0a, 0x11, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x74,
-	0x6f, 0x6b, 0x65, 0x6e, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x10, 0x63, 0x6f, 0x6d,
-	0x70, 0x6c, 0x65, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x73, 0x12, 0x21, 0x0a,
-	0x0c, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x5f, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x73, 0x18, 0x03, 0x20,
-	0x01, 0x28, 0x03, 0x52, 0x0b, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x73,
-	0x22, 0xa3, 0x01, 0x0a, 0x0e, 0x43, 0x68, 0x61, 0x74, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74,
-	0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x08, 0x52, 0x05, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x12, 0x14, 0x0a, 0x05, 0x6d, 0x6f, 0x64,
-	0x65, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x12,
-	0x34, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b,
-	0x32, 0x1a, 0x2e, 0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31,
-	0x2e, 0x43, 0x68, 0x61, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x07, 0x6d, 0x65,
-	0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x2f, 0x0a, 0x05, 0x75, 0x73, 0x61, 0x67, 0x65, 0x18, 0x04,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70,
-	0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x67, 0x65, 0x55, 0x73, 0x61, 0x67, 0x65, 0x52,
-	0x05, 0x75, 0x73, 0x61, 0x67, 0x65, 0x32, 0xff, 0x01, 0x0a, 0x0a, 0x41, 0x70, 0x69, 0x53, 0x65,
-	0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x3f, 0x0a, 0x04, 0x43, 0x68, 0x61, 0x74, 0x12, 0x1a, 0x2e,
-	0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68,
-	0x61, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x6c, 0x6c, 0x6d, 0x61,
-	0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x52, 0x65,
-	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x53, 0x0a, 0x0a, 0x43, 0x68, 0x61, 0x74, 0x53, 0x74,
-	0x72, 0x65, 0x61, 0x6d, 0x12, 0x20, 0x2e, 0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70,
-	0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x21, 0x2e, 0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e,
-	0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61,
-	0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x12, 0x5b, 0x0a, 0x0c, 0x43,
-	0x68, 0x61, 0x74, 0x52, 0x65, 0x61, 0x6c, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x22, 0x2e, 0x6c, 0x6c,
-	0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x74,
-	0x52, 0x65, 0x61, 0x6c, 0x74, 0x69, 0x6d, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
-	0x23, 0x2e, 0x6c, 0x6c, 0x6d, 0x61, 0x70, 0x69, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e,
-	0x43, 0x68, 0x61, 0x74, 0x52, 0x65, 0x61, 0x6c, 0x74, 0x69, 0x6d, 0x65, 0x52, 0x65, 0x73, 0x70,
-	0x6f, 0x6e, 0x73, 0x65, 0x28, 0x01, 0x30, 0x01, 0x42, 0x0f, 0x5a, 0x0d, 0x6c, 0x6c, 0x6d, 0x61,
-	0x70, 0x69, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x33,
-}
+const file_api_v1_api_proto_rawDesc = "" +
+	"\n" +
+	"\x10api/v1/api.proto\x12\rllmapi.api.v1\"I\n" +
+	"\vChatRequest\x12:\n" +
+	"\vchat_params\x18\x01 \x01(\v2\x19.llmapi.api.v1.ChatParamsR\n" +
+	"chatParams\"V\n" +
+	"\fChatResponse\x12F\n" +
+	"\x0fchat_completion\x18\x01 \x01(\v2\x1d.llmapi.api.v1.ChatCompletionR\x0echatCompletion\"O\n" +
+	"\x11ChatStreamRequest\x12:\n" +
+	"\vchat_params\x18\x01 \x01(\v2\x19.llmapi.api.v1.ChatParamsR\n" +
+	"chatParams\"\\\n" +
+	"\x12ChatStreamResponse\x12F\n" +
+	"\x0fchat_completion\x18\x01 \x01(\v2\x1d.llmapi.api.v1.ChatCompletionR\x0echatCompletion\"\xcc\x01\n" +
+	"\x13ChatRealtimeRequest\x12;\n" +
+	"\x04init\x18\x01 \x01(\v2'.llmapi.api.v1.ChatRealtimeRequest.InitR\x04init\x124\n" +
+	"\amessage\x18\x02 \x01(\v2\x1a.llmapi.api.v1.ChatMessageR\amessage\x1aB\n" +
+	"\x04Init\x12:\n" +
+	"\vchat_params\x18\x01 \x01(\v2\x19.llmapi.api.v1.ChatParamsR\n" +
+	"chatParams\"^\n" +
+	"\x14ChatRealtimeResponse\x12F\n" +
+	"\x0fchat_completion\x18\x01 \x01(\v2\x1d.llmapi.api.v1.ChatCompletionR\x0echatCompletion\"p\n" +
+	"\fEmbedRequest\x12\x14\n" +
+	"\x05model\x18\x01 \x01(\tR\x05model\x12\x16\n" +
+	"\x06inputs\x18\x02 \x03(\tR\x06inputs\x12#\n" +
+	"\n" +
+	"dimensions\x18\x03 \x01(\x03H\x00R\n" +
+	"dimensions\x88\x01\x01B\r\n" +
+	"\v_dimensions\"\x90\x01\n" +
+	"\rEmbedResponse\x12\x14\n" +
+	"\x05model\x18\x01 \x01(\tR\x05model\x128\n" +
+	"\n" +
+	"embeddings\x18\x02 \x03(\v2\x18.llmapi.api.v1.EmbeddingR\n" +
+	"embeddings\x12/\n" +
+	"\x05usage\x18\x03 \x01(\v2\x19.llmapi.api.v1.ChageUsageR\x05usage\"P\n" +
+	"\x12CountTokensRequest\x12:\n" +
+	"\vchat_params\x18\x01 \x01(\v2\x19.llmapi.api.v1.ChatParamsR\n" +
+	"chatParams\"l\n" +
+	"\x13CountTokensResponse\x12\x14\n" +
+	"\x05model\x18\x01 \x01(\tR\x05model\x12!\n" +
+	"\finput_tokens\x18\x02 \x01(\x03R\vinputTokens\x12\x1c\n" +
+	"\testimated\x18\x03 \x01(\bR\testimated\"\xf2\x05\n" +
+	"\n" +
+	"ChatParams\x12\x14\n" +
+	"\x05model\x18\x01 \x01(\tR\x05model\x12-\n" +
+	"\x05tools\x18\x02 \x03(\v2\x17.llmapi.api.v1.ChatToolR\x05tools\x12\"\n" +
+	"\finstructions\x18\x03 \x01(\tR\finstructions\x126\n" +
+	"\bmessages\x18\x04 \x03(\v2\x1a.llmapi.api.v1.ChatMessageR\bmessages\x12\x14\n" +
+	"\x05voice\x18\x05 \x01(\tR\x05voice\x12%\n" +
+	"\vtemperature\x18\x06 \x01(\x02H\x00R\vtemperature\x88\x01\x01\x12\x18\n" +
+	"\x05top_p\x18\a \x01(\x02H\x01R\x04topP\x88\x01\x01\x12\x18\n" +
+	"\x05top_k\x18\b \x01(\x05H\x02R\x04topK\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"max_tokens\x18\t \x01(\x03H\x03R\tmaxTokens\x88\x01\x01\x12%\n" +
+	"\x0estop_sequences\x18\n" +
+	" \x03(\tR\rstopSequences\x12O\n" +
+	"\x0fresponse_format\x18\v \x01(\v2!.llmapi.api.v1.ChatResponseFormatH\x04R\x0eresponseFormat\x88\x01\x01\x12C\n" +
+	"\vtool_choice\x18\f \x01(\v2\x1d.llmapi.api.v1.ChatToolChoiceH\x05R\n" +
+	"toolChoice\x88\x01\x01\x123\n" +
+	"\x13parallel_tool_calls\x18\r \x01(\bH\x06R\x11parallelToolCalls\x88\x01\x01\x12?\n" +
+	"\treasoning\x18\x0e \x01(\v2\x1c.llmapi.api.v1.ChatReasoningH\aR\treasoning\x88\x01\x01B\x0e\n" +
+	"\f_temperatureB\b\n" +
+	"\x06_top_pB\b\n" +
+	"\x06_top_kB\r\n" +
+	"\v_max_tokensB\x12\n" +
+	"\x10_response_formatB\x0e\n" +
+	"\f_tool_choiceB\x16\n" +
+	"\x14_parallel_tool_callsB\f\n" +
+	"\n" +
+	"_reasoning\"i\n" +
+	"\vChatMessage\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04role\x18\x02 \x01(\tR\x04role\x126\n" +
+	"\bcontents\x18\x03 \x03(\v2\x1a.llmapi.api.v1.ChatContentR\bcontents\"\x8c\x01\n" +
+	"\bChatTool\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04desc\x18\x02 \x01(\tR\x04desc\x12\x16\n" +
+	"\x06params\x18\x03 \x01(\tR\x06params\x12@\n" +
+	"\rcache_control\x18\x04 \x01(\v2\x1b.llmapi.api.v1.CacheControlR\fcacheControl\"8\n" +
+	"\x0eChatToolChoice\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"\x91\x01\n" +
+	"\rChatReasoning\x12\x18\n" +
+	"\aenabled\x18\x01 \x01(\bR\aenabled\x12#\n" +
+	"\rbudget_tokens\x18\x02 \x01(\x03R\fbudgetTokens\x12\x16\n" +
+	"\x06effort\x18\x03 \x01(\tR\x06effort\x12)\n" +
+	"\x10include_thoughts\x18\x04 \x01(\bR\x0fincludeThoughts\"\x80\x01\n" +
+	"\x12ChatResponseFormat\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04desc\x18\x03 \x01(\tR\x04desc\x12\x16\n" +
+	"\x06schema\x18\x04 \x01(\tR\x06schema\x12\x16\n" +
+	"\x06strict\x18\x05 \x01(\bR\x06strict\"\xad\x05\n" +
+	"\vChatContent\x12@\n" +
+	"\rcache_control\x18\x01 \x01(\v2\x1b.llmapi.api.v1.CacheControlR\fcacheControl\x124\n" +
+	"\x04text\x18\x14 \x01(\v2\x1e.llmapi.api.v1.ChatContentTextH\x00R\x04text\x12C\n" +
+	"\treasoning\x18\x15 \x01(\v2#.llmapi.api.v1.ChatContentReasoningH\x00R\treasoning\x12=\n" +
+	"\arefusal\x18\x16 \x01(\v2!.llmapi.api.v1.ChatContentRefusalH\x00R\arefusal\x12A\n" +
+	"\ttool_call\x18\x17 \x01(\v2\".llmapi.api.v1.ChatContentToolCallH\x00R\btoolCall\x12G\n" +
+	"\vtool_result\x18\x18 \x01(\v2$.llmapi.api.v1.ChatContentToolResultH\x00R\n" +
+	"toolResult\x127\n" +
+	"\x05audio\x18\x19 \x01(\v2\x1f.llmapi.api.v1.ChatContentAudioH\x00R\x05audio\x12Y\n" +
+	"\x11realtime_response\x18\x1a \x01(\v2*.llmapi.api.v1.ChatContentRealtimeResponseH\x00R\x10realtimeResponse\x12A\n" +
+	"\timage_url\x18\x1b \x01(\v2\".llmapi.api.v1.ChatContentImageUrlH\x00R\bimageUrl\x124\n" +
+	"\x04file\x18\x1c \x01(\v2\x1e.llmapi.api.v1.ChatContentFileH\x00R\x04fileB\t\n" +
+	"\acontent\";\n" +
+	"\x0fChatContentText\x12\x14\n" +
+	"\x05delta\x18\x01 \x01(\bR\x05delta\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\"V\n" +
+	"\x14ChatContentReasoning\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x12*\n" +
+	"\x10thoughtSignature\x18\x02 \x01(\tR\x10thoughtSignature\"(\n" +
+	"\x12ChatContentRefusal\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\"m\n" +
+	"\x13ChatContentToolCall\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1c\n" +
+	"\targuments\x18\x03 \x01(\tR\targuments\x12\x14\n" +
+	"\x05index\x18\x04 \x01(\x05R\x05index\"S\n" +
+	"\x15ChatContentToolResult\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x16\n" +
+	"\x06result\x18\x03 \x01(\tR\x06result\"\x84\x01\n" +
+	"\x10ChatContentAudio\x12\x14\n" +
+	"\x05delta\x18\x01 \x01(\bR\x05delta\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\tR\x04data\x12\x16\n" +
+	"\x06format\x18\x03 \x01(\tR\x06format\x12\x1e\n" +
+	"\n" +
+	"transcript\x18\x04 \x01(\tR\n" +
+	"transcript\x12\x0e\n" +
+	"\x02id\x18\x05 \x01(\tR\x02id\"W\n" +
+	"\x13ChatContentImageUrl\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x16\n" +
+	"\x06detail\x18\x02 \x01(\tR\x06detail\x12\x16\n" +
+	"\x06format\x18\x03 \x01(\tR\x06format\"V\n" +
+	"\x0fChatContentFile\x12\x1b\n" +
+	"\tmime_type\x18\x01 \x01(\tR\bmimeType\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\tR\x04data\"\x1d\n" +
+	"\x1bChatContentRealtimeResponse\"\xd4\x01\n" +
+	"\n" +
+	"ChageUsage\x12#\n" +
+	"\rprompt_tokens\x18\x01 \x01(\x03R\fpromptTokens\x12+\n" +
+	"\x11completion_tokens\x18\x02 \x01(\x03R\x10completionTokens\x12!\n" +
+	"\ftotal_tokens\x18\x03 \x01(\x03R\vtotalTokens\x12#\n" +
+	"\rcached_tokens\x18\x04 \x01(\x03R\fcachedTokens\x12,\n" +
+	"\x12cache_write_tokens\x18\x05 \x01(\x03R\x10cacheWriteTokens\" \n" +
+	"\fCacheControl\x12\x10\n" +
+	"\x03ttl\x18\x01 \x01(\tR\x03ttl\"\xc8\x01\n" +
+	"\x0eChatCompletion\x12\x14\n" +
+	"\x05delta\x18\x01 \x01(\bR\x05delta\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model\x124\n" +
+	"\amessage\x18\x03 \x01(\v2\x1a.llmapi.api.v1.ChatMessageR\amessage\x12/\n" +
+	"\x05usage\x18\x04 \x01(\v2\x19.llmapi.api.v1.ChageUsageR\x05usage\x12#\n" +
+	"\rfinish_reason\x18\x05 \x01(\tR\ffinishReason\"9\n" +
+	"\tEmbedding\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x16\n" +
+	"\x06vector\x18\x02 \x03(\x02R\x06vector2\x99\x03\n" +
+	"\n" +
+	"ApiService\x12?\n" +
+	"\x04Chat\x12\x1a.llmapi.api.v1.ChatRequest\x1a\x1b.llmapi.api.v1.ChatResponse\x12S\n" +
+	"\n" +
+	"ChatStream\x12 .llmapi.api.v1.ChatStreamRequest\x1a!.llmapi.api.v1.ChatStreamResponse0\x01\x12[\n" +
+	"\fChatRealtime\x12\".llmapi.api.v1.ChatRealtimeRequest\x1a#.llmapi.api.v1.ChatRealtimeResponse(\x010\x01\x12B\n" +
+	"\x05Embed\x12\x1b.llmapi.api.v1.EmbedRequest\x1a\x1c.llmapi.api.v1.EmbedResponse\x12T\n" +
+	"\vCountTokens\x12!.llmapi.api.v1.CountTokensRequest\x1a\".llmapi.api.v1.CountTokensResponseB\x1fZ\x1dgithub.com/xucx/llmapi/api/v1b\x06proto3"
 
 var (
 	file_api_v1_api_proto_rawDescOnce sync.Once
-	file_api_v1_api_proto_rawDescData = file_api_v1_api_proto_rawDesc
+	file_api_v1_api_proto_rawDescData []byte
 )
 
 func file_api_v1_api_proto_rawDescGZIP() []byte {
 	file_api_v1_api_proto_rawDescOnce.Do(func() {
-		file_api_v1_api_proto_rawDescData = protoimpl.X.CompressGZIP(file_api_v1_api_proto_rawDescData)
+		file_api_v1_api_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_v1_api_proto_rawDesc), len(file_api_v1_api_proto_rawDesc)))
 	})
 	return file_api_v1_api_proto_rawDescData
 }
 
-var file_api_v1_api_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_api_v1_api_proto_msgTypes = make([]protoimpl.MessageInfo, 31)
 var file_api_v1_api_proto_goTypes = []any{
-	(*ChatRequest)(nil),              // 0: llmapi.api.v1.ChatRequest
-	(*ChatResponse)(nil),             // 1: llmapi.api.v1.ChatResponse
-	(*ChatStreamRequest)(nil),        // 2: llmapi.api.v1.ChatStreamRequest
-	(*ChatStreamResponse)(nil),       // 3: llmapi.api.v1.ChatStreamResponse
-	(*ChatRealtimeRequest)(nil),      // 4: llmapi.api.v1.ChatRealtimeRequest
-	(*ChatRealtimeResponse)(nil),     // 5: llmapi.api.v1.ChatRealtimeResponse
-	(*ChatParams)(nil),               // 6: llmapi.api.v1.ChatParams
-	(*ChatMessage)(nil),              // 7: llmapi.api.v1.ChatMessage
-	(*ChatTool)(nil),                 // 8: llmapi.api.v1.ChatTool
-	(*ChatContent)(nil),              // 9: llmapi.api.v1.ChatContent
-	(*ChatContentText)(nil),          // 10: llmapi.api.v1.ChatContentText
-	(*ChatContentReasoning)(nil),     // 11: llmapi.api.v1.ChatContentReasoning
-	(*ChatContentRefusal)(nil),       // 12: llmapi.api.v1.ChatContentRefusal
-	(*ChatContentToolCall)(nil),      // 13: llmapi.api.v1.ChatContentToolCall
-	(*ChatContentToolResult)(nil),    // 14: llmapi.api.v1.ChatContentToolResult
-	(*ChatContentAudio)(nil),         // 15: llmapi.api.v1.ChatContentAudio
-	(*ChageUsage)(nil),               // 16: llmapi.api.v1.ChageUsage
-	(*ChatCompletion)(nil),           // 17: llmapi.api.v1.ChatCompletion
-	(*ChatRealtimeRequest_Init)(nil), // 18: llmapi.api.v1.ChatRealtimeRequest.Init
+	(*ChatRequest)(nil),                 // 0: llmapi.api.v1.ChatRequest
+	(*ChatResponse)(nil),                // 1: llmapi.api.v1.ChatResponse
+	(*ChatStreamRequest)(nil),           // 2: llmapi.api.v1.ChatStreamRequest
+	(*ChatStreamResponse)(nil),          // 3: llmapi.api.v1.ChatStreamResponse
+	(*ChatRealtimeRequest)(nil),         // 4: llmapi.api.v1.ChatRealtimeRequest
+	(*ChatRealtimeResponse)(nil),        // 5: llmapi.api.v1.ChatRealtimeResponse
+	(*EmbedRequest)(nil),                // 6: llmapi.api.v1.EmbedRequest
+	(*EmbedResponse)(nil),               // 7: llmapi.api.v1.EmbedResponse
+	(*CountTokensRequest)(nil),          // 8: llmapi.api.v1.CountTokensRequest
+	(*CountTokensResponse)(nil),         // 9: llmapi.api.v1.CountTokensResponse
+	(*ChatParams)(nil),                  // 10: llmapi.api.v1.ChatParams
+	(*ChatMessage)(nil),                 // 11: llmapi.api.v1.ChatMessage
+	(*ChatTool)(nil),                    // 12: llmapi.api.v1.ChatTool
+	(*ChatToolChoice)(nil),              // 13: llmapi.api.v1.ChatToolChoice
+	(*ChatReasoning)(nil),               // 14: llmapi.api.v1.ChatReasoning
+	(*ChatResponseFormat)(nil),          // 15: llmapi.api.v1.ChatResponseFormat
+	(*ChatContent)(nil),                 // 16: llmapi.api.v1.ChatContent
+	(*ChatContentText)(nil),             // 17: llmapi.api.v1.ChatContentText
+	(*ChatContentReasoning)(nil),        // 18: llmapi.api.v1.ChatContentReasoning
+	(*ChatContentRefusal)(nil),          // 19: llmapi.api.v1.ChatContentRefusal
+	(*ChatContentToolCall)(nil),         // 20: llmapi.api.v1.ChatContentToolCall
+	(*ChatContentToolResult)(nil),       // 21: llmapi.api.v1.ChatContentToolResult
+	(*ChatContentAudio)(nil),            // 22: llmapi.api.v1.ChatContentAudio
+	(*ChatContentImageUrl)(nil),         // 23: llmapi.api.v1.ChatContentImageUrl
+	(*ChatContentFile)(nil),             // 24: llmapi.api.v1.ChatContentFile
+	(*ChatContentRealtimeResponse)(nil), // 25: llmapi.api.v1.ChatContentRealtimeResponse
+	(*ChageUsage)(nil),                  // 26: llmapi.api.v1.ChageUsage
+	(*CacheControl)(nil),                // 27: llmapi.api.v1.CacheControl
+	(*ChatCompletion)(nil),              // 28: llmapi.api.v1.ChatCompletion
+	(*Embedding)(nil),                   // 29: llmapi.api.v1.Embedding
+	(*ChatRealtimeRequest_Init)(nil),    // 30: llmapi.api.v1.ChatRealtimeRequest.Init
 }
 var file_api_v1_api_proto_depIdxs = []int32{
-	6,  // 0: llmapi.api.v1.ChatRequest.chat_params:type_name -> llmapi.api.v1.ChatParams
-	17, // 1: llmapi.api.v1.ChatResponse.chat_completion:type_name -> llmapi.api.v1.ChatCompletion
-	6,  // 2: llmapi.api.v1.ChatStreamRequest.chat_params:type_name -> llmapi.api.v1.ChatParams
-	17, // 3: llmapi.api.v1.ChatStreamResponse.chat_completion:type_name -> llmapi.api.v1.ChatCompletion
-	18, // 4: llmapi.api.v1.ChatRealtimeRequest.init:type_name -> llmapi.api.v1.ChatRealtimeRequest.Init
-	7,  // 5: llmapi.api.v1.ChatRealtimeRequest.message:type_name -> llmapi.api.v1.ChatMessage
-	17, // 6: llmapi.api.v1.ChatRealtimeResponse.chat_completion:type_name -> llmapi.api.v1.ChatCompletion
-	8,  // 7: llmapi.api.v1.ChatParams.tools:type_name -> llmapi.api.v1.ChatTool
-	7,  // 8: llmapi.api.v1.ChatParams.messages:type_name -> llmapi.api.v1.ChatMessage
-	9,  // 9: llmapi.api.v1.ChatMessage.contents:type_name -> llmapi.api.v1.ChatContent
-	10, // 10: llmapi.api.v1.ChatContent.text:type_name -> llmapi.api.v1.ChatContentText
-	11, // 11: llmapi.api.v1.ChatContent.reasoning:type_name -> llmapi.api.v1.ChatContentReasoning
-	12, // 12: llmapi.api.v1.ChatContent.refusal:type_name -> llmapi.api.v1.ChatContentRefusal
-	13, // 13: llmapi.api.v1.ChatContent.tool_call:type_name -> llmapi.api.v1.ChatContentToolCall
-	14, // 14: llmapi.api.v1.ChatContent.tool_result:type_name -> llmapi.api.v1.ChatContentToolResult
-	15, // 15: llmapi.api.v1.ChatContent.audio:type_name -> llmapi.api.v1.ChatContentAudio
-	7,  // 16: llmapi.api.v1.ChatCompletion.message:type_name -> llmapi.api.v1.ChatMessage
-	16, // 17: llmapi.api.v1.ChatCompletion.usage:type_name -> llmapi.api.v1.ChageUsage
-	6,  // 18: llmapi.api.v1.ChatRealtimeRequest.Init.chat_params:type_name -> llmapi.api.v1.ChatParams
-	0,  // 19: llmapi.api.v1.ApiService.Chat:input_type -> llmapi.api.v1.ChatRequest
-	2,  // 20: llmapi.api.v1.ApiService.ChatStream:input_type -> llmapi.api.v1.ChatStreamRequest
-	4,  // 21: llmapi.api.v1.ApiService.ChatRealtime:input_type -> llmapi.api.v1.ChatRealtimeRequest
-	1,  // 22: llmapi.api.v1.ApiService.Chat:output_type -> llmapi.api.v1.ChatResponse
-	3,  // 23: llmapi.api.v1.ApiService.ChatStream:output_type -> llmapi.api.v1.ChatStreamResponse
-	5,  // 24: llmapi.api.v1.ApiService.ChatRealtime:output_type -> llmapi.api.v1.ChatRealtimeResponse
-	22, // [22:25] is the sub-list for method output_type
-	19, // [19:22] is the sub-list for method input_type
-	19, // [19:19] is the sub-list for extension type_name
-	19, // [19:19] is the sub-list for extension extendee
-	0,  // [0:19] is the sub-list for field type_name
+	10, // 0: llmapi.api.v1.ChatRequest.chat_params:type_name -> llmapi.api.v1.ChatParams
+	28, // 1: llmapi.api.v1.ChatResponse.chat_completion:type_name -> llmapi.api.v1.ChatCompletion
+	10, // 2: llmapi.api.v1.ChatStreamRequest.chat_params:type_name -> llmapi.api.v1.ChatParams
+	28, // 3: llmapi.api.v1.ChatStreamResponse.chat_completion:type_name -> llmapi.api.v1.ChatCompletion
+	30, // 4: llmapi.api.v1.ChatRealtimeRequest.init:type_name -> llmapi.api.v1.ChatRealtimeRequest.Init
+	11, // 5: llmapi.api.v1.ChatRealtimeRequest.message:type_name -> llmapi.api.v1.ChatMessage
+	28, // 6: llmapi.api.v1.ChatRealtimeResponse.chat_completion:type_name -> llmapi.api.v1.ChatCompletion
+	29, // 7: llmapi.api.v1.EmbedResponse.embeddings:type_name -> llmapi.api.v1.Embedding
+	26, // 8: llmapi.api.v1.EmbedResponse.usage:type_name -> llmapi.api.v1.ChageUsage
+	10, // 9: llmapi.api.v1.CountTokensRequest.chat_params:type_name -> llmapi.api.v1.ChatParams
+	12, // 10: llmapi.api.v1.ChatParams.tools:type_name -> llmapi.api.v1.ChatTool
+	11, // 11: llmapi.api.v1.ChatParams.messages:type_name -> llmapi.api.v1.ChatMessage
+	15, // 12: llmapi.api.v1.ChatParams.response_format:type_name -> llmapi.api.v1.ChatResponseFormat
+	13, // 13: llmapi.api.v1.ChatParams.tool_choice:type_name -> llmapi.api.v1.ChatToolChoice
+	14, // 14: llmapi.api.v1.ChatParams.reasoning:type_name -> llmapi.api.v1.ChatReasoning
+	16, // 15: llmapi.api.v1.ChatMessage.contents:type_name -> llmapi.api.v1.ChatContent
+	27, // 16: llmapi.api.v1.ChatTool.cache_control:type_name -> llmapi.api.v1.CacheControl
+	27, // 17: llmapi.api.v1.ChatContent.cache_control:type_name -> llmapi.api.v1.CacheControl
+	17, // 18: llmapi.api.v1.ChatContent.text:type_name -> llmapi.api.v1.ChatContentText
+	18, // 19: llmapi.api.v1.ChatContent.reasoning:type_name -> llmapi.api.v1.ChatContentReasoning
+	19, // 20: llmapi.api.v1.ChatContent.refusal:type_name -> llmapi.api.v1.ChatContentRefusal
+	20, // 21: llmapi.api.v1.ChatContent.tool_call:type_name -> llmapi.api.v1.ChatContentToolCall
+	21, // 22: llmapi.api.v1.ChatContent.tool_result:type_name -> llmapi.api.v1.ChatContentToolResult
+	22, // 23: llmapi.api.v1.ChatContent.audio:type_name -> llmapi.api.v1.ChatContentAudio
+	25, // 24: llmapi.api.v1.ChatContent.realtime_response:type_name -> llmapi.api.v1.ChatContentRealtimeResponse
+	23, // 25: llmapi.api.v1.ChatContent.image_url:type_name -> llmapi.api.v1.ChatContentImageUrl
+	24, // 26: llmapi.api.v1.ChatContent.file:type_name -> llmapi.api.v1.ChatContentFile
+	11, // 27: llmapi.api.v1.ChatCompletion.message:type_name -> llmapi.api.v1.ChatMessage
+	26, // 28: llmapi.api.v1.ChatCompletion.usage:type_name -> llmapi.api.v1.ChageUsage
+	10, // 29: llmapi.api.v1.ChatRealtimeRequest.Init.chat_params:type_name -> llmapi.api.v1.ChatParams
+	0,  // 30: llmapi.api.v1.ApiService.Chat:input_type -> llmapi.api.v1.ChatRequest
+	2,  // 31: llmapi.api.v1.ApiService.ChatStream:input_type -> llmapi.api.v1.ChatStreamRequest
+	4,  // 32: llmapi.api.v1.ApiService.ChatRealtime:input_type -> llmapi.api.v1.ChatRealtimeRequest
+	6,  // 33: llmapi.api.v1.ApiService.Embed:input_type -> llmapi.api.v1.EmbedRequest
+	8,  // 34: llmapi.api.v1.ApiService.CountTokens:input_type -> llmapi.api.v1.CountTokensRequest
+	1,  // 35: llmapi.api.v1.ApiService.Chat:output_type -> llmapi.api.v1.ChatResponse
+	3,  // 36: llmapi.api.v1.ApiService.ChatStream:output_type -> llmapi.api.v1.ChatStreamResponse
+	5,  // 37: llmapi.api.v1.ApiService.ChatRealtime:output_type -> llmapi.api.v1.ChatRealtimeResponse
+	7,  // 38: llmapi.api.v1.ApiService.Embed:output_type -> llmapi.api.v1.EmbedResponse
+	9,  // 39: llmapi.api.v1.ApiService.CountTokens:output_type -> llmapi.api.v1.CountTokensResponse
+	35, // [35:40] is the sub-list for method output_type
+	30, // [30:35] is the sub-list for method input_type
+	30, // [30:30] is the sub-list for extension type_name
+	30, // [30:30] is the sub-list for extension extendee
+	0,  // [0:30] is the sub-list for field type_name
 }
 
 func init() { file_api_v1_api_proto_init() }
@@ -1380,21 +2262,26 @@ func file_api_v1_api_proto_init() {
 	if File_api_v1_api_proto != nil {
 		return
 	}
-	file_api_v1_api_proto_msgTypes[9].OneofWrappers = []any{
+	file_api_v1_api_proto_msgTypes[6].OneofWrappers = []any{}
+	file_api_v1_api_proto_msgTypes[10].OneofWrappers = []any{}
+	file_api_v1_api_proto_msgTypes[16].OneofWrappers = []any{
 		(*ChatContent_Text)(nil),
 		(*ChatContent_Reasoning)(nil),
 		(*ChatContent_Refusal)(nil),
 		(*ChatContent_ToolCall)(nil),
 		(*ChatContent_ToolResult)(nil),
 		(*ChatContent_Audio)(nil),
+		(*ChatContent_RealtimeResponse)(nil),
+		(*ChatContent_ImageUrl)(nil),
+		(*ChatContent_File)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
-			RawDescriptor: file_api_v1_api_proto_rawDesc,
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_v1_api_proto_rawDesc), len(file_api_v1_api_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   19,
+			NumMessages:   31,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -1403,7 +2290,6 @@ func file_api_v1_api_proto_init() {
 		MessageInfos:      file_api_v1_api_proto_msgTypes,
 	}.Build()
 	File_api_v1_api_proto = out.File
-	file_api_v1_api_proto_rawDesc = nil
 	file_api_v1_api_proto_goTypes = nil
 	file_api_v1_api_proto_depIdxs = nil
 }
@@ -1,6 +1,6 @@
 // Code generated by protoc-gen-go-grpc. DO NOT EDIT.
 // versions:
-// - protoc-gen-go-grpc v1.4.0
+// - protoc-gen-go-grpc v1.5.1
 // - protoc             (unknown)
 // source: api/v1/api.proto
 
@@ -15,13 +15,15 @@ import (
 
 // This is a compile-time assertion to ensure that this generated file
 // is compatible with the grpc package it is being compiled against.
-// Requires gRPC-Go v1.62.0 or later.
-const _ = grpc.SupportPackageIsVersion8
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
 
 const (
 	ApiService_Chat_FullMethodName         = "/llmapi.api.v1.ApiService/Chat"
 	ApiService_ChatStream_FullMethodName   = "/llmapi.api.v1.ApiService/ChatStream"
 	ApiService_ChatRealtime_FullMethodName = "/llmapi.api.v1.ApiService/ChatRealtime"
+	ApiService_Embed_FullMethodName        = "/llmapi.api.v1.ApiService/Embed"
+	ApiService_CountTokens_FullMethodName  = "/llmapi.api.v1.ApiService/CountTokens"
 )
 
 // ApiServiceClient is the client API for ApiService service.
@@ -29,8 +31,10 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ApiServiceClient interface {
 	Chat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (*ChatResponse, error)
-	ChatStream(ctx context.Context, in *ChatStreamRequest, opts ...grpc.CallOption) (ApiService_ChatStreamClient, error)
-	ChatRealtime(ctx context.Context, opts ...grpc.CallOption) (ApiService_ChatRealtimeClient, error)
+	ChatStream(ctx context.Context, in *ChatStreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChatStreamResponse], error)
+	ChatRealtime(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ChatRealtimeRequest, ChatRealtimeResponse], error)
+	Embed(ctx context.Context, in *EmbedRequest, opts ...grpc.CallOption) (*EmbedResponse, error)
+	CountTokens(ctx context.Context, in *CountTokensRequest, opts ...grpc.CallOption) (*CountTokensResponse, error)
 }
 
 type apiServiceClient struct {
@@ -51,13 +55,13 @@ func (c *apiServiceClient) Chat(ctx context.Context, in *ChatRequest, opts ...gr
 	return out, nil
 }
 
-func (c *apiServiceClient) ChatStream(ctx context.Context, in *ChatStreamRequest, opts ...grpc.CallOption) (ApiService_ChatStreamClient, error) {
+func (c *apiServiceClient) ChatStream(ctx context.Context, in *ChatStreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChatStreamResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	stream, err := c.cc.NewStream(ctx, &ApiService_ServiceDesc.Streams[0], ApiService_ChatStream_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &apiServiceChatStreamClient{ClientStream: stream}
+	x := &grpc.GenericClientStream[ChatStreamRequest, ChatStreamResponse]{ClientStream: stream}
 	if err := x.ClientStream.SendMsg(in); err != nil {
 		return nil, err
 	}
@@ -67,77 +71,76 @@ func (c *apiServiceClient) ChatStream(ctx context.Context, in *ChatStreamRequest
 	return x, nil
 }
 
-type ApiService_ChatStreamClient interface {
-	Recv() (*ChatStreamResponse, error)
-	grpc.ClientStream
-}
-
-type apiServiceChatStreamClient struct {
-	grpc.ClientStream
-}
-
-func (x *apiServiceChatStreamClient) Recv() (*ChatStreamResponse, error) {
-	m := new(ChatStreamResponse)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
-		return nil, err
-	}
-	return m, nil
-}
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ApiService_ChatStreamClient = grpc.ServerStreamingClient[ChatStreamResponse]
 
-func (c *apiServiceClient) ChatRealtime(ctx context.Context, opts ...grpc.CallOption) (ApiService_ChatRealtimeClient, error) {
+func (c *apiServiceClient) ChatRealtime(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ChatRealtimeRequest, ChatRealtimeResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	stream, err := c.cc.NewStream(ctx, &ApiService_ServiceDesc.Streams[1], ApiService_ChatRealtime_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &apiServiceChatRealtimeClient{ClientStream: stream}
+	x := &grpc.GenericClientStream[ChatRealtimeRequest, ChatRealtimeResponse]{ClientStream: stream}
 	return x, nil
 }
 
-type ApiService_ChatRealtimeClient interface {
-	Send(*ChatRealtimeRequest) error
-	Recv() (*ChatRealtimeResponse, error)
-	grpc.ClientStream
-}
-
-type apiServiceChatRealtimeClient struct {
-	grpc.ClientStream
-}
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ApiService_ChatRealtimeClient = grpc.BidiStreamingClient[ChatRealtimeRequest, ChatRealtimeResponse]
 
-func (x *apiServiceChatRealtimeClient) Send(m *ChatRealtimeRequest) error {
-	return x.ClientStream.SendMsg(m)
+func (c *apiServiceClient) Embed(ctx context.Context, in *EmbedRequest, opts ...grpc.CallOption) (*EmbedResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EmbedResponse)
+	err := c.cc.Invoke(ctx, ApiService_Embed_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func (x *apiServiceChatRealtimeClient) Recv() (*ChatRealtimeResponse, error) {
-	m := new(ChatRealtimeResponse)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
+func (c *apiServiceClient) CountTokens(ctx context.Context, in *CountTokensRequest, opts ...grpc.CallOption) (*CountTokensResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CountTokensResponse)
+	err := c.cc.Invoke(ctx, ApiService_CountTokens_FullMethodName, in, out, cOpts...)
+	if err != nil {
 		return nil, err
 	}
-	return m, nil
+	return out, nil
 }
 
 // ApiServiceServer is the server API for ApiService service.
 // All implementations should embed UnimplementedApiServiceServer
-// for forward compatibility
+// for forward compatibility.
 type ApiServiceServer interface {
 	Chat(context.Context, *ChatRequest) (*ChatResponse, error)
-	ChatStream(*ChatStreamRequest, ApiService_ChatStreamServer) error
-	ChatRealtime(ApiService_ChatRealtimeServer) error
+	ChatStream(*ChatStreamRequest, grpc.ServerStreamingServer[ChatStreamResponse]) error
+	ChatRealtime(grpc.BidiStreamingServer[ChatRealtimeRequest, ChatRealtimeResponse]) error
+	Embed(context.Context, *EmbedRequest) (*EmbedResponse, error)
+	CountTokens(context.Context, *CountTokensRequest) (*CountTokensResponse, error)
 }
 
-// UnimplementedApiServiceServer should be embedded to have forward compatible implementations.
-type UnimplementedApiServiceServer struct {
-}
+// UnimplementedApiServiceServer should be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedApiServiceServer struct{}
 
 func (UnimplementedApiServiceServer) Chat(context.Context, *ChatRequest) (*ChatResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Chat not implemented")
 }
-func (UnimplementedApiServiceServer) ChatStream(*ChatStreamRequest, ApiService_ChatStreamServer) error {
+func (UnimplementedApiServiceServer) ChatStream(*ChatStreamRequest, grpc.ServerStreamingServer[ChatStreamResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method ChatStream not implemented")
 }
-func (UnimplementedApiServiceServer) ChatRealtime(ApiService_ChatRealtimeServer) error {
+func (UnimplementedApiServiceServer) ChatRealtime(grpc.BidiStreamingServer[ChatRealtimeRequest, ChatRealtimeResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method ChatRealtime not implemented")
 }
+func (UnimplementedApiServiceServer) Embed(context.Context, *EmbedRequest) (*EmbedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Embed not implemented")
+}
+func (UnimplementedApiServiceServer) CountTokens(context.Context, *CountTokensRequest) (*CountTokensResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CountTokens not implemented")
+}
+func (UnimplementedApiServiceServer) testEmbeddedByValue() {}
 
 // UnsafeApiServiceServer may be embedded to opt out of forward compatibility for this service.
 // Use of this interface is not recommended, as added methods to ApiServiceServer will
@@ -147,6 +150,13 @@ type UnsafeApiServiceServer interface {
 }
 
 func RegisterApiServiceServer(s grpc.ServiceRegistrar, srv ApiServiceServer) {
+	// If the following call pancis, it indicates UnimplementedApiServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
 	s.RegisterService(&ApiService_ServiceDesc, srv)
 }
 
@@ -173,46 +183,53 @@ func _ApiService_ChatStream_Handler(srv interface{}, stream grpc.ServerStream) e
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(ApiServiceServer).ChatStream(m, &apiServiceChatStreamServer{ServerStream: stream})
-}
-
-type ApiService_ChatStreamServer interface {
-	Send(*ChatStreamResponse) error
-	grpc.ServerStream
+	return srv.(ApiServiceServer).ChatStream(m, &grpc.GenericServerStream[ChatStreamRequest, ChatStreamResponse]{ServerStream: stream})
 }
 
-type apiServiceChatStreamServer struct {
-	grpc.ServerStream
-}
-
-func (x *apiServiceChatStreamServer) Send(m *ChatStreamResponse) error {
-	return x.ServerStream.SendMsg(m)
-}
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ApiService_ChatStreamServer = grpc.ServerStreamingServer[ChatStreamResponse]
 
 func _ApiService_ChatRealtime_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(ApiServiceServer).ChatRealtime(&apiServiceChatRealtimeServer{ServerStream: stream})
+	return srv.(ApiServiceServer).ChatRealtime(&grpc.GenericServerStream[ChatRealtimeRequest, ChatRealtimeResponse]{ServerStream: stream})
 }
 
-type ApiService_ChatRealtimeServer interface {
-	Send(*ChatRealtimeResponse) error
-	Recv() (*ChatRealtimeRequest, error)
-	grpc.ServerStream
-}
-
-type apiServiceChatRealtimeServer struct {
-	grpc.ServerStream
-}
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ApiService_ChatRealtimeServer = grpc.BidiStreamingServer[ChatRealtimeRequest, ChatRealtimeResponse]
 
-func (x *apiServiceChatRealtimeServer) Send(m *ChatRealtimeResponse) error {
-	return x.ServerStream.SendMsg(m)
+func _ApiService_Embed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmbedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServiceServer).Embed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ApiService_Embed_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServiceServer).Embed(ctx, req.(*EmbedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (x *apiServiceChatRealtimeServer) Recv() (*ChatRealtimeRequest, error) {
-	m := new(ChatRealtimeRequest)
-	if err := x.ServerStream.RecvMsg(m); err != nil {
+func _ApiService_CountTokens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CountTokensRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return m, nil
+	if interceptor == nil {
+		return srv.(ApiServiceServer).CountTokens(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ApiService_CountTokens_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServiceServer).CountTokens(ctx, req.(*CountTokensRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
 // ApiService_ServiceDesc is the grpc.ServiceDesc for ApiService service.
@@ -226,6 +243,14 @@ var ApiService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Chat",
 			Handler:    _ApiService_Chat_Handler,
 		},
+		{
+			MethodName: "Embed",
+			Handler:    _ApiService_Embed_Handler,
+		},
+		{
+			MethodName: "CountTokens",
+			Handler:    _ApiService_CountTokens_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -0,0 +1,426 @@
+package llmapi
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xucx/llmapi/log"
+	"github.com/xucx/llmapi/types"
+)
+
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendDisk   = "disk"
+
+	DefaultCacheSize = 1000
+	DefaultCacheTTL  = time.Hour
+
+	// runes of each text chunk when a cached completion is replayed to a stream
+	cacheReplayChunkRunes = 16
+)
+
+type CacheStatus string
+
+const (
+	CacheStatusHit    CacheStatus = "hit"
+	CacheStatusMiss   CacheStatus = "miss"
+	CacheStatusBypass CacheStatus = "bypass"
+)
+
+// CacheConfig of the response cache, models opt in with ModelConfig.Cache
+type CacheConfig struct {
+	Backend string        `yaml:"backend"` // memory or disk, empty disables the cache
+	Size    int           `yaml:"size"`    // entries of the memory cache, default 1000
+	Dir     string        `yaml:"dir"`     // directory of the disk cache
+	TTL     time.Duration `yaml:"ttl"`     // default 1h
+}
+
+// Cache keeps completions by the hash of their request
+type Cache interface {
+	Get(key string) (*types.Completion, bool)
+	Set(key string, completion *types.Completion)
+}
+
+func NewCache(conf CacheConfig) (Cache, error) {
+	ttl := conf.TTL
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	switch conf.Backend {
+	case "":
+		return nil, nil
+	case CacheBackendMemory:
+		return NewMemoryCache(conf.Size, ttl), nil
+	case CacheBackendDisk:
+		return NewDiskCache(conf.Dir, ttl)
+	default:
+		return nil, fmt.Errorf("cache backend %s not support", conf.Backend)
+	}
+}
+
+type cacheBypassKey struct{}
+
+type cacheStatusFuncKey struct{}
+
+// WithCacheBypass makes Models.Generate skip the cache, the completion is not stored either
+func WithCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+// WithCacheStatusFunc gets the cache status of a cached model before anything is streamed
+func WithCacheStatusFunc(ctx context.Context, fn func(CacheStatus)) context.Context {
+	return context.WithValue(ctx, cacheStatusFuncKey{}, fn)
+}
+
+func reportCacheStatus(ctx context.Context, status CacheStatus) {
+	if fn, ok := ctx.Value(cacheStatusFuncKey{}).(func(CacheStatus)); ok {
+		fn(status)
+	}
+}
+
+// cacheKey hashes what decides the completion, message ids and streaming funcs are left out
+func cacheKey(model string, messages []*types.Message, opts *types.ChatOptions) (string, error) {
+	type message struct {
+		Role  types.MessageRole    `json:"role"`
+		Parts []*types.MessagePart `json:"parts"`
+	}
+
+	canonical := struct {
+		Model    string             `json:"model"`
+		Messages []message          `json:"messages"`
+		Options  *types.ChatOptions `json:"options"`
+	}{
+		Model:    model,
+		Messages: make([]message, 0, len(messages)),
+		Options:  opts,
+	}
+	for _, m := range messages {
+		canonical.Messages = append(canonical.Messages, message{Role: m.Role, Parts: m.Parts})
+	}
+
+	// streaming funcs are not encoded, maps like tool parameters are encoded with sorted keys
+	b, err := json.Marshal(canonical)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (m *Models) generateWithCache(ctx context.Context, md *Model, modelName string, messages []*types.Message, options ...types.ChatOption) (*types.Completion, error) {
+	if bypass, _ := ctx.Value(cacheBypassKey{}).(bool); bypass {
+		reportCacheStatus(ctx, CacheStatusBypass)
+		return m.generate(ctx, md, messages, options...)
+	}
+
+	opts := types.GetChatOptions(&types.ChatOptions{}, options...)
+	key, err := cacheKey(modelName, messages, opts)
+	if err != nil {
+		log.Warnw("llm cache key fail", "model", modelName, "error", err)
+		reportCacheStatus(ctx, CacheStatusBypass)
+		return m.generate(ctx, md, messages, options...)
+	}
+
+	if cached, ok := m.cache.Get(key); ok {
+		reportCacheStatus(ctx, CacheStatusHit)
+		// nothing was paid for this one
+		cached.Usage.Cost = 0
+		if err := replayCompletion(ctx, cached, opts); err != nil {
+			return nil, err
+		}
+		return cached, nil
+	}
+
+	reportCacheStatus(ctx, CacheStatusMiss)
+	completion, err := m.generate(ctx, md, messages, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if completion.FinishReason != types.FinishReasonTruncated {
+		m.cache.Set(key, completion)
+	}
+	return completion, nil
+}
+
+// replayCompletion streams a cached completion in chunks, like a provider would
+func replayCompletion(ctx context.Context, completion *types.Completion, opts *types.ChatOptions) error {
+	if opts.StreamingFunc == nil && opts.StreamingAccFunc == nil {
+		return nil
+	}
+
+	chunks := []*types.MessagePart{}
+	if completion.Message != nil {
+		for _, part := range completion.Message.Parts {
+			switch {
+			case part.Text != nil:
+				for _, text := range splitRunes(part.Text.Text, cacheReplayChunkRunes) {
+					chunks = append(chunks, &types.MessagePart{Text: &types.MessageText{Text: text, Delta: true}})
+				}
+			case part.Reasoning != nil:
+				texts := splitRunes(part.Reasoning.Text, cacheReplayChunkRunes)
+				if len(texts) == 0 {
+					// a signature without text
+					texts = []string{""}
+				}
+				for i, text := range texts {
+					reasoning := &types.MessageReasoning{Text: text}
+					// like gemini, the signature comes with the last chunk of its reasoning
+					if i == len(texts)-1 {
+						reasoning.ThoughtSignature = part.Reasoning.ThoughtSignature
+					}
+					chunks = append(chunks, &types.MessagePart{Reasoning: reasoning})
+				}
+			default:
+				chunks = append(chunks, part)
+			}
+		}
+	}
+
+	acc := &types.Message{}
+	if completion.Message != nil {
+		acc.ID = completion.Message.ID
+		acc.Role = completion.Message.Role
+	}
+
+	for i, part := range chunks {
+		acc.Parts = appendReplayPart(acc.Parts, part)
+
+		chunk := &types.Completion{
+			Delta:    true,
+			Model:    completion.Model,
+			Provider: completion.Provider,
+			Message:  &types.Message{ID: acc.ID, Role: acc.Role, Parts: []*types.MessagePart{part}},
+		}
+		// like providers, the last chunk carries the usage and the finish reason
+		if i == len(chunks)-1 {
+			chunk.Usage = completion.Usage
+			chunk.FinishReason = completion.FinishReason
+		}
+
+		if opts.StreamingFunc != nil {
+			if err := opts.StreamingFunc(ctx, chunk); err != nil {
+				return err
+			}
+		}
+
+		if opts.StreamingAccFunc != nil {
+			accCompletion := *chunk
+			accCompletion.Message = &types.Message{ID: acc.ID, Role: acc.Role, Parts: append([]*types.MessagePart{}, acc.Parts...)}
+			if err := opts.StreamingAccFunc(ctx, &accCompletion); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// appendReplayPart merges text into the last part of the same kind, a reasoning keeps its signature
+func appendReplayPart(parts []*types.MessagePart, part *types.MessagePart) []*types.MessagePart {
+	if len(parts) > 0 {
+		last := parts[len(parts)-1]
+		switch {
+		case part.Text != nil && last.Text != nil:
+			parts[len(parts)-1] = &types.MessagePart{Text: &types.MessageText{Text: last.Text.Text + part.Text.Text}}
+			return parts
+		case part.Reasoning != nil && last.Reasoning != nil && last.Reasoning.ThoughtSignature == "":
+			// a signature ends its reasoning, the next one is another part
+			parts[len(parts)-1] = &types.MessagePart{Reasoning: &types.MessageReasoning{
+				Text:             last.Reasoning.Text + part.Reasoning.Text,
+				ThoughtSignature: part.Reasoning.ThoughtSignature,
+			}}
+			return parts
+		}
+	}
+
+	if part.Text != nil {
+		return append(parts, &types.MessagePart{Text: &types.MessageText{Text: part.Text.Text}})
+	}
+	return append(parts, part)
+}
+
+func splitRunes(s string, n int) []string {
+	if s == "" {
+		return nil
+	}
+
+	chunks := []string{}
+	runes := []rune(s)
+	for len(runes) > n {
+		chunks = append(chunks, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return append(chunks, string(runes))
+}
+
+// MemoryCache is a lru cache of completions
+type MemoryCache struct {
+	size int
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	lru   *list.List // front is the most recently used
+	items map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key       string
+	data      []byte
+	expiresAt time.Time
+}
+
+func NewMemoryCache(size int, ttl time.Duration) *MemoryCache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+
+	return &MemoryCache{
+		size:  size,
+		ttl:   ttl,
+		lru:   list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+func (c *MemoryCache) Get(key string) (*types.Completion, bool) {
+	c.mu.Lock()
+	elem, ok := c.items[key]
+	if !ok {
+		c.mu.Unlock()
+		return nil, false
+	}
+
+	item := elem.Value.(*memoryCacheItem)
+	if time.Now().After(item.expiresAt) {
+		c.lru.Remove(elem)
+		delete(c.items, key)
+		c.mu.Unlock()
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	data := item.data
+	c.mu.Unlock()
+
+	completion := &types.Completion{}
+	if err := json.Unmarshal(data, completion); err != nil {
+		return nil, false
+	}
+	return completion, true
+}
+
+func (c *MemoryCache) Set(key string, completion *types.Completion) {
+	// completions are kept encoded, so a hit can not share memory with a caller
+	data, err := json.Marshal(completion)
+	if err != nil {
+		log.Warnw("llm cache encode fail", "error", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := &memoryCacheItem{key: key, data: data, expiresAt: time.Now().Add(c.ttl)}
+	if elem, ok := c.items[key]; ok {
+		elem.Value = item
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.lru.PushFront(item)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.items, oldest.Value.(*memoryCacheItem).key)
+	}
+}
+
+// DiskCache keeps each completion in a json file named by its key
+type DiskCache struct {
+	dir string
+	ttl time.Duration
+}
+
+type diskCacheItem struct {
+	ExpiresAt  time.Time         `json:"expiresAt"`
+	Completion *types.Completion `json:"completion"`
+}
+
+// NewDiskCache removes the expired files left in dir
+func NewDiskCache(dir string, ttl time.Duration) (*DiskCache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("disk cache needs a dir")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	c := &DiskCache{dir: dir, ttl: ttl}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if key, ok := strings.CutSuffix(entry.Name(), ".json"); ok {
+			c.Get(key)
+		}
+	}
+
+	return c, nil
+}
+
+func (c *DiskCache) file(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *DiskCache) Get(key string) (*types.Completion, bool) {
+	data, err := os.ReadFile(c.file(key))
+	if err != nil {
+		return nil, false
+	}
+
+	item := &diskCacheItem{}
+	if err := json.Unmarshal(data, item); err != nil || item.Completion == nil || time.Now().After(item.ExpiresAt) {
+		os.Remove(c.file(key))
+		return nil, false
+	}
+	return item.Completion, true
+}
+
+func (c *DiskCache) Set(key string, completion *types.Completion) {
+	data, err := json.Marshal(&diskCacheItem{ExpiresAt: time.Now().Add(c.ttl), Completion: completion})
+	if err != nil {
+		log.Warnw("llm cache encode fail", "error", err)
+		return
+	}
+
+	// write then rename, so a reader never sees half a file
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		log.Warnw("llm cache write fail", "error", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.file(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Warnw("llm cache write fail", "error", err)
+	}
+}
@@ -0,0 +1,163 @@
+package llmapi
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/xucx/llmapi/types"
+)
+
+func testMessages(texts ...string) []*types.Message {
+	messages := []*types.Message{}
+	for _, text := range texts {
+		messages = append(messages, types.NewTextMessage(types.MessageRoleUser, text))
+	}
+	return messages
+}
+
+// testParameters is a json schema decoded from raw, so its map keys come in any order
+func testParameters(t *testing.T, raw string) map[string]any {
+	t.Helper()
+
+	params := map[string]any{}
+	if err := json.Unmarshal([]byte(raw), &params); err != nil {
+		t.Fatal(err)
+	}
+	return params
+}
+
+func TestCacheKey(t *testing.T) {
+	key := func(model string, messages []*types.Message, options ...types.ChatOption) string {
+		k, err := cacheKey(model, messages, types.GetChatOptions(&types.ChatOptions{}, options...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	stream := func(context.Context, *types.Completion) error { return nil }
+
+	withID := testMessages("hi")
+	withID[0].ID = "msg-1"
+
+	base := key("m", testMessages("hi"), types.ChatWithTemperature(0.5))
+	cases := []struct {
+		name string
+		key  string
+		same bool
+	}{
+		{"message ids", key("m", withID, types.ChatWithTemperature(0.5)), true},
+		{"streaming funcs", key("m", testMessages("hi"), types.ChatWithTemperature(0.5), types.ChatWithStreamingFunc(stream)), true},
+		{"model", key("n", testMessages("hi"), types.ChatWithTemperature(0.5)), false},
+		{"messages", key("m", testMessages("hello"), types.ChatWithTemperature(0.5)), false},
+		{"temperature", key("m", testMessages("hi"), types.ChatWithTemperature(0.7)), false},
+	}
+	for _, c := range cases {
+		if same := c.key == base; same != c.same {
+			t.Errorf("%s: same key %v, want %v", c.name, same, c.same)
+		}
+	}
+
+	// maps are encoded with sorted keys
+	a := key("m", testMessages("hi"), types.ChatWithTools([]*types.Tool{types.NewFunctionTool("f", "", testParameters(t, `{"type":"object","properties":{"a":{},"b":{}}}`))}))
+	b := key("m", testMessages("hi"), types.ChatWithTools([]*types.Tool{types.NewFunctionTool("f", "", testParameters(t, `{"properties":{"b":{},"a":{}},"type":"object"}`))}))
+	if a != b {
+		t.Errorf("tool parameters in another order change the key")
+	}
+}
+
+func TestReplayCompletion(t *testing.T) {
+	completion := &types.Completion{
+		Model: "m",
+		Message: &types.Message{
+			ID:   "msg-1",
+			Role: types.MessageRoleAssistant,
+			Parts: []*types.MessagePart{
+				{Reasoning: &types.MessageReasoning{Text: "a reasoning longer than one chunk", ThoughtSignature: "sig-1"}},
+				// gemini sends signatures without text
+				{Reasoning: &types.MessageReasoning{ThoughtSignature: "sig-2"}},
+				{Text: &types.MessageText{Text: "an answer longer than one chunk"}},
+				{ToolCall: &types.MessageToolCall{ID: "call-1", Type: types.ToolTypeFunction, Function: &types.ToolCallFunction{Name: "f", Arguments: "{}"}}},
+			},
+		},
+		Usage:        types.CompletionUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		FinishReason: types.FinishReasonToolCalls,
+	}
+
+	chunks := []*types.Completion{}
+	var acc *types.Completion
+	opts := types.GetChatOptions(&types.ChatOptions{},
+		types.ChatWithStreamingFunc(func(ctx context.Context, c *types.Completion) error {
+			chunks = append(chunks, c)
+			return nil
+		}),
+		types.ChatWithStreamingAccFunc(func(ctx context.Context, c *types.Completion) error {
+			acc = c
+			return nil
+		}),
+	)
+	if err := replayCompletion(context.Background(), completion, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	signatures := []string{}
+	for _, c := range chunks[:len(chunks)-1] {
+		if c.FinishReason != "" || c.Usage.TotalTokens != 0 {
+			t.Errorf("chunk before the last with finish reason %q usage %d", c.FinishReason, c.Usage.TotalTokens)
+		}
+	}
+	for _, c := range chunks {
+		if r := c.Message.Parts[0].Reasoning; r != nil && r.ThoughtSignature != "" {
+			signatures = append(signatures, r.ThoughtSignature)
+		}
+	}
+	if want := []string{"sig-1", "sig-2"}; !reflect.DeepEqual(signatures, want) {
+		t.Errorf("signatures %v, want %v once each", signatures, want)
+	}
+
+	last := chunks[len(chunks)-1]
+	if last.FinishReason != types.FinishReasonToolCalls || last.Usage.TotalTokens != 15 {
+		t.Errorf("last chunk with finish reason %q usage %d", last.FinishReason, last.Usage.TotalTokens)
+	}
+
+	// the accumulated stream is the cached message
+	if acc.Message.ID != "msg-1" || len(acc.Message.Parts) != len(completion.Message.Parts) {
+		t.Fatalf("accumulated %+v", acc.Message)
+	}
+	for i, part := range completion.Message.Parts {
+		if !reflect.DeepEqual(acc.Message.Parts[i], part) {
+			t.Errorf("part %d: got %+v, want %+v", i, acc.Message.Parts[i], part)
+		}
+	}
+}
+
+func TestGenerateWithCache(t *testing.T) {
+	p := &testProvider{name: "a"}
+	m := newTestModels(t, p)
+	m.cache = NewMemoryCache(10, DefaultCacheTTL)
+	m.models["a"].Cache = true
+
+	statuses := []CacheStatus{}
+	ctx := WithCacheStatusFunc(context.Background(), func(status CacheStatus) { statuses = append(statuses, status) })
+
+	for range 2 {
+		completion, err := m.Generate(ctx, "a", testMessages("hi"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if completion.Message.Text() != "a" {
+			t.Errorf("completion %q, want a", completion.Message.Text())
+		}
+	}
+	if _, err := m.Generate(WithCacheBypass(ctx), "a", testMessages("hi")); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []CacheStatus{CacheStatusMiss, CacheStatusHit, CacheStatusBypass}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("statuses %v, want %v", statuses, want)
+	}
+	if got := p.calls.Load(); got != 2 {
+		t.Errorf("provider called %d times, want 2 without the hit", got)
+	}
+}
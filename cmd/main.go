@@ -8,6 +8,7 @@ import (
 	"syscall"
 
 	"github.com/xucx/llmapi/internal/server"
+	"github.com/xucx/llmapi/internal/server/api/middlewares"
 	"github.com/xucx/llmapi/internal/version"
 	"github.com/xucx/llmapi/log"
 
@@ -26,6 +27,19 @@ var (
 			return runServer(cmd.Context())
 		},
 	}
+
+	genKeyCmd = &cobra.Command{
+		Use:   "genkey",
+		Short: "generate an api key and the hash to put in keys config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := middlewares.GenerateKey()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("key:  %s\nhash: %s\n", key, middlewares.HashKey(key))
+			return nil
+		},
+	}
 )
 
 func runServer(ctx context.Context) error {
@@ -50,4 +64,6 @@ func init() {
 	flags := cmd.Flags()
 	flags.StringVarP(&configFile, "config", "c", "", "config file")
 
+	cmd.AddCommand(genKeyCmd)
+
 }
@@ -0,0 +1,223 @@
+package llmapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/xucx/llmapi/internal/tokenizer"
+	"github.com/xucx/llmapi/types"
+)
+
+const (
+	DefaultContextKeepTurns     = 4
+	DefaultContextSummaryTokens = 1024
+
+	contextSummaryInstructions = "Summarize the conversation below for the assistant who continues it. " +
+		"Keep the facts, decisions, open questions and the results of tool calls, leave out small talk. " +
+		"Reply with the summary only."
+	contextSummaryPrefix = "Summary of the earlier conversation:\n"
+)
+
+// ContextManager fits the messages of a request into budget prompt tokens, opts.ContextPolicy
+// is the policy of the request. Messages which still do not fit are rejected by Models.Generate
+// when the model has a context window.
+type ContextManager interface {
+	Fit(ctx context.Context, messages []*types.Message, budget int64, opts *types.ChatOptions) ([]*types.Message, error)
+}
+
+// RegisterContextManager adds a strategy, or replaces a builtin one, it is not safe to call
+// while requests are served
+func (m *Models) RegisterContextManager(strategy types.ContextStrategy, manager ContextManager) {
+	m.contextManagers[strategy] = manager
+}
+
+func (m *Models) builtinContextManagers() map[types.ContextStrategy]ContextManager {
+	return map[types.ContextStrategy]ContextManager{
+		types.ContextStrategyDropOldest:    dropOldestManager{},
+		types.ContextStrategySlidingWindow: slidingWindowManager{},
+		types.ContextStrategySummarize:     &summarizeManager{models: m},
+	}
+}
+
+type contextUsageKey struct{}
+
+// withContextUsage collects the usage of the calls the context managers of a request make
+func withContextUsage(ctx context.Context) (context.Context, *types.CompletionUsage) {
+	usage := &types.CompletionUsage{}
+	return context.WithValue(ctx, contextUsageKey{}, usage), usage
+}
+
+// ReportContextUsage adds the usage of a call a context manager made to fit the messages,
+// Models.Generate adds it to the usage of the completion
+func ReportContextUsage(ctx context.Context, usage types.CompletionUsage) {
+	if total, ok := ctx.Value(contextUsageKey{}).(*types.CompletionUsage); ok {
+		addUsage(total, usage)
+	}
+}
+
+// EstimateTokens is the local estimate of the prompt tokens of a request, it needs no call
+// so context managers can use it freely
+func EstimateTokens(messages []*types.Message, opts *types.ChatOptions) int64 {
+	return tokenizer.EstimateMessages(messages, opts)
+}
+
+// SplitTurns splits messages into the system messages and the turns, a turn is a user message
+// with the assistant and tool messages answering it
+func SplitTurns(messages []*types.Message) ([]*types.Message, [][]*types.Message) {
+	system := []*types.Message{}
+	turns := [][]*types.Message{}
+	for _, msg := range messages {
+		switch {
+		case msg.Role == types.MessageRoleSystem:
+			system = append(system, msg)
+		case msg.Role == types.MessageRoleUser || len(turns) == 0:
+			turns = append(turns, []*types.Message{msg})
+		default:
+			turns[len(turns)-1] = append(turns[len(turns)-1], msg)
+		}
+	}
+	return system, turns
+}
+
+func joinTurns(system []*types.Message, turns [][]*types.Message) []*types.Message {
+	messages := append([]*types.Message{}, system...)
+	for _, turn := range turns {
+		messages = append(messages, turn...)
+	}
+	return messages
+}
+
+func estimateTurn(turn []*types.Message) int64 {
+	var tokens int64
+	for _, msg := range turn {
+		tokens += tokenizer.EstimateMessage(msg)
+	}
+	return tokens
+}
+
+// dropOldestManager drops whole turns from the start, the last turn is always kept
+type dropOldestManager struct{}
+
+func (dropOldestManager) Fit(ctx context.Context, messages []*types.Message, budget int64, opts *types.ChatOptions) ([]*types.Message, error) {
+	system, turns := SplitTurns(messages)
+
+	tokens := EstimateTokens(system, opts)
+	for _, turn := range turns {
+		tokens += estimateTurn(turn)
+	}
+
+	for len(turns) > 1 && tokens > budget {
+		tokens -= estimateTurn(turns[0])
+		turns = turns[1:]
+	}
+
+	return joinTurns(system, turns), nil
+}
+
+// slidingWindowManager keeps the newest messages which fit, the window may start inside a turn,
+// then the user message of that turn is kept too. The last message is always kept.
+type slidingWindowManager struct{}
+
+func (slidingWindowManager) Fit(ctx context.Context, messages []*types.Message, budget int64, opts *types.ChatOptions) ([]*types.Message, error) {
+	system := []*types.Message{}
+	rest := []*types.Message{}
+	for _, msg := range messages {
+		if msg.Role == types.MessageRoleSystem {
+			system = append(system, msg)
+		} else {
+			rest = append(rest, msg)
+		}
+	}
+
+	tokens := EstimateTokens(system, opts)
+	start := len(rest)
+	for start > 0 {
+		// tool results stay with the assistant message calling them
+		unitStart := start - 1
+		for unitStart > 0 && rest[unitStart].Role == types.MessageRoleTool {
+			unitStart--
+		}
+
+		cost := estimateTurn(rest[unitStart:start])
+		if start < len(rest) && tokens+cost > budget {
+			break
+		}
+		tokens += cost
+		start = unitStart
+	}
+
+	window := rest[start:]
+	if start > 0 && len(window) > 0 && window[0].Role != types.MessageRoleUser {
+		for i := start - 1; i >= 0; i-- {
+			if rest[i].Role == types.MessageRoleUser {
+				window = append([]*types.Message{rest[i]}, window...)
+				break
+			}
+		}
+	}
+
+	return append(system, window...), nil
+}
+
+// summarizeManager replaces the turns before the newest KeepTurns with a system message
+// summarizing them, written by the summary model
+type summarizeManager struct {
+	models *Models
+}
+
+func (s *summarizeManager) Fit(ctx context.Context, messages []*types.Message, budget int64, opts *types.ChatOptions) ([]*types.Message, error) {
+	policy := opts.ContextPolicy
+	keep := DefaultContextKeepTurns
+	if policy.KeepTurns > 0 {
+		keep = policy.KeepTurns
+	}
+
+	system, turns := SplitTurns(messages)
+	if len(turns) <= keep {
+		return messages, nil
+	}
+	older, recent := turns[:len(turns)-keep], turns[len(turns)-keep:]
+
+	completion, err := s.models.Generate(ctx, policy.SummaryModel,
+		[]*types.Message{types.NewTextMessage(types.MessageRoleUser, transcript(joinTurns(nil, older)))},
+		types.ChatWithInstructions(contextSummaryInstructions),
+		types.ChatWithMaxTokens(DefaultContextSummaryTokens),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("summarize context with %s: %w", policy.SummaryModel, err)
+	}
+	ReportContextUsage(ctx, completion.Usage)
+
+	summary := types.NewTextMessage(types.MessageRoleSystem, contextSummaryPrefix+completionText(completion))
+	summarized := joinTurns(append(system, summary), recent)
+
+	// the newest turns alone may still be too large
+	return dropOldestManager{}.Fit(ctx, summarized, budget, opts)
+}
+
+// transcript is the text of messages for the summary model
+func transcript(messages []*types.Message) string {
+	b := strings.Builder{}
+	for _, msg := range messages {
+		for _, part := range msg.Parts {
+			switch {
+			case part.Text != nil:
+				fmt.Fprintf(&b, "%s: %s\n", msg.Role, part.Text.Text)
+			case part.Refusal != nil:
+				fmt.Fprintf(&b, "%s refused: %s\n", msg.Role, part.Refusal.Text)
+			case part.ImageURL != nil:
+				fmt.Fprintf(&b, "%s: [image]\n", msg.Role)
+			case part.File != nil:
+				fmt.Fprintf(&b, "%s: [file %s]\n", msg.Role, part.File.Name)
+			case part.Audio != nil && part.Audio.Transcript != "":
+				fmt.Fprintf(&b, "%s: %s\n", msg.Role, part.Audio.Transcript)
+			case part.ToolCall != nil && part.ToolCall.Function != nil:
+				fmt.Fprintf(&b, "%s called %s(%s)\n", msg.Role, part.ToolCall.Function.Name, part.ToolCall.Function.Arguments)
+			case part.ToolResult != nil:
+				fmt.Fprintf(&b, "result of %s: %s\n", part.ToolResult.Name, part.ToolResult.Result)
+			}
+		}
+	}
+	return b.String()
+}
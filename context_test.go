@@ -0,0 +1,113 @@
+package llmapi
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/xucx/llmapi/types"
+)
+
+func toolCallMessage(id string) *types.Message {
+	return &types.Message{Role: types.MessageRoleAssistant, Parts: []*types.MessagePart{
+		{ToolCall: &types.MessageToolCall{ID: id, Type: types.ToolTypeFunction, Function: &types.ToolCallFunction{Name: "f", Arguments: "{}"}}},
+	}}
+}
+
+func toolResultMessage(id string) *types.Message {
+	return &types.Message{Role: types.MessageRoleTool, Parts: []*types.MessagePart{
+		{ToolResult: &types.MessageToolResult{ID: id, Name: "f", Result: "done"}},
+	}}
+}
+
+// names are the texts, tool call ids and roles of messages
+func names(messages []*types.Message) string {
+	s := []string{}
+	for _, msg := range messages {
+		for _, part := range msg.Parts {
+			switch {
+			case part.Text != nil:
+				s = append(s, part.Text.Text)
+			case part.ToolCall != nil:
+				s = append(s, "call:"+part.ToolCall.ID)
+			case part.ToolResult != nil:
+				s = append(s, "result:"+part.ToolResult.ID)
+			}
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+func TestContextManagers(t *testing.T) {
+	system := types.NewTextMessage(types.MessageRoleSystem, "sys")
+	messages := []*types.Message{
+		system,
+		types.NewTextMessage(types.MessageRoleUser, "u1"),
+		toolCallMessage("c1"),
+		toolResultMessage("c1"),
+		types.NewTextMessage(types.MessageRoleAssistant, "a1"),
+		types.NewTextMessage(types.MessageRoleUser, "u2"),
+		toolCallMessage("c2"),
+		toolResultMessage("c2"),
+		toolCallMessage("c3"),
+		toolResultMessage("c3"),
+		types.NewTextMessage(types.MessageRoleAssistant, "a2"),
+	}
+	opts := &types.ChatOptions{}
+	// budget of the tokens of the messages from start on, with the system message
+	budget := func(start int) int64 {
+		return EstimateTokens(append([]*types.Message{system}, messages[start:]...), opts)
+	}
+
+	cases := []struct {
+		name    string
+		manager ContextManager
+		budget  int64
+		want    string
+	}{
+		{"drop oldest fits", dropOldestManager{}, budget(1), "sys u1 call:c1 result:c1 a1 u2 call:c2 result:c2 call:c3 result:c3 a2"},
+		// whole turns are dropped, their tool calls with their results
+		{"drop oldest turn", dropOldestManager{}, budget(5), "sys u2 call:c2 result:c2 call:c3 result:c3 a2"},
+		{"drop oldest keeps the last turn", dropOldestManager{}, 1, "sys u2 call:c2 result:c2 call:c3 result:c3 a2"},
+		{"sliding window", slidingWindowManager{}, budget(8), "sys u2 call:c3 result:c3 a2"},
+		// the window never starts at a tool result
+		{"sliding window inside a tool pair", slidingWindowManager{}, budget(9), "sys u2 a2"},
+		{"sliding window keeps the last message", slidingWindowManager{}, 1, "sys u2 a2"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fitted, err := c.manager.Fit(context.Background(), messages, c.budget, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := names(fitted); got != c.want {
+				t.Errorf("fitted %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeUsage(t *testing.T) {
+	a := &testProvider{name: "a", usage: types.CompletionUsage{PromptTokens: 5, CompletionTokens: 1, TotalTokens: 6}}
+	s := &testProvider{name: "summary", usage: types.CompletionUsage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110}}
+	m := newTestModels(t, a, s)
+	m.models["a"].ContextPolicy = &types.ContextPolicy{Strategy: types.ContextStrategySummarize, MaxTokens: 30, KeepTurns: 1, SummaryModel: "summary"}
+	m.models["summary"].Price = ModelPrice{Prompt: 1, Completion: 1}
+
+	messages := testMessages(strings.Repeat("hello world ", 10), strings.Repeat("hello world ", 10), "u3")
+	completion, err := m.Generate(context.Background(), "a", messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := names(a.messages), "Summary of the earlier conversation:\nsummary u3"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+	if completion.Usage.PromptTokens != 105 || completion.Usage.TotalTokens != 116 {
+		t.Errorf("usage %+v, want the summary usage added", completion.Usage)
+	}
+	if completion.Usage.Cost != 110.0/1e6 {
+		t.Errorf("cost %v, want the cost of the summary", completion.Usage.Cost)
+	}
+}
@@ -0,0 +1,290 @@
+package llmapi
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xucx/llmapi/internal/providers/provider"
+	"github.com/xucx/llmapi/log"
+	"github.com/xucx/llmapi/types"
+)
+
+const (
+	DefaultEjectionFailures = 5
+	DefaultEjectionDuration = 30 * time.Second
+
+	// ProviderType of a model group in ModelInfo
+	GroupProviderType = "group"
+)
+
+type GroupStrategy string
+
+const (
+	GroupStrategyRoundRobin     GroupStrategy = "round_robin"
+	GroupStrategyLeastInFlight  GroupStrategy = "least_in_flight"
+	GroupStrategyWeightedRandom GroupStrategy = "weighted_random"
+)
+
+// GroupConfig is one public model name backed by several deployments
+type GroupConfig struct {
+	Name        string             `yaml:"name"`
+	Strategy    GroupStrategy      `yaml:"strategy"` // default round_robin
+	Deployments []DeploymentConfig `yaml:"deployments"`
+	Ejection    EjectionConfig     `yaml:"ejection"`
+	Fallbacks   []string           `yaml:"fallbacks"`
+	Retry       RetryConfig        `yaml:"retry"`
+	Cache       bool               `yaml:"cache"`
+}
+
+type DeploymentConfig struct {
+	Model  string `yaml:"model"`  // model name or "<provider>/<model>"
+	Weight int    `yaml:"weight"` // default 1
+}
+
+// EjectionConfig takes a deployment out of the group after consecutive upstream failures
+type EjectionConfig struct {
+	ConsecutiveFailures int           `yaml:"consecutiveFailures"` // default 5
+	Duration            time.Duration `yaml:"duration"`            // default 30s
+}
+
+type modelGroup struct {
+	name        string
+	strategy    GroupStrategy
+	deployments []*deployment
+	ejection    EjectionConfig
+	fallbacks   []string
+	retry       RetryConfig
+	cache       bool
+
+	mu sync.Mutex
+}
+
+type deployment struct {
+	model    *Model
+	weight   int
+	inFlight atomic.Int64
+
+	// guarded by modelGroup.mu
+	current      int // smooth weighted round robin
+	failures     int
+	ejectedUntil time.Time
+}
+
+func (m *Models) newModelGroup(conf GroupConfig) (*modelGroup, error) {
+	if conf.Name == "" {
+		return nil, fmt.Errorf("model group name can not be empty")
+	}
+	if _, ok := m.models[conf.Name]; ok {
+		return nil, fmt.Errorf("model group %s conflicts with a model", conf.Name)
+	}
+	if len(conf.Deployments) == 0 {
+		return nil, fmt.Errorf("model group %s has no deployment", conf.Name)
+	}
+
+	g := &modelGroup{
+		name:      conf.Name,
+		strategy:  conf.Strategy,
+		ejection:  conf.Ejection,
+		fallbacks: conf.Fallbacks,
+		retry:     conf.Retry,
+		cache:     conf.Cache,
+	}
+
+	switch g.strategy {
+	case "":
+		g.strategy = GroupStrategyRoundRobin
+	case GroupStrategyRoundRobin, GroupStrategyLeastInFlight, GroupStrategyWeightedRandom:
+	default:
+		return nil, fmt.Errorf("model group %s strategy %s not support", conf.Name, conf.Strategy)
+	}
+
+	if g.ejection.ConsecutiveFailures <= 0 {
+		g.ejection.ConsecutiveFailures = DefaultEjectionFailures
+	}
+	if g.ejection.Duration <= 0 {
+		g.ejection.Duration = DefaultEjectionDuration
+	}
+
+	for _, d := range conf.Deployments {
+		md, err := m.GetModel(d.Model)
+		if err != nil {
+			return nil, fmt.Errorf("model group %s deployment: %w", conf.Name, err)
+		}
+
+		weight := d.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		g.deployments = append(g.deployments, &deployment{model: md, weight: weight})
+	}
+
+	return g, nil
+}
+
+// model picks a deployment and returns a model that reports its outcome back to the group
+func (g *modelGroup) model() *Model {
+	d := g.pick()
+
+	return &Model{
+		Name:          g.name,
+		Model:         d.model.Model,
+		Provider:      &deploymentProvider{Provider: d.model.Provider, group: g, deployment: d},
+		ProviderName:  d.model.ProviderName,
+		ProviderType:  d.model.ProviderType,
+		MaxToken:      d.model.MaxToken,
+		ContextWindow: d.model.ContextWindow,
+		Overflow:      d.model.Overflow,
+		ContextPolicy: d.model.ContextPolicy,
+		Price:         d.model.Price,
+		Fallbacks:     g.fallbacks,
+		Retry:         g.retry,
+		Cache:         g.cache,
+	}
+}
+
+// repick is the model of another attempt, a group model picks a deployment again
+func (m *Model) repick() *Model {
+	if p, ok := m.Provider.(*deploymentProvider); ok {
+		return p.group.model()
+	}
+	return m
+}
+
+func (g *modelGroup) pick() *deployment {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	healthy := make([]*deployment, 0, len(g.deployments))
+	for _, d := range g.deployments {
+		if now.After(d.ejectedUntil) {
+			healthy = append(healthy, d)
+		}
+	}
+	// fail open, a busy deployment is better than none
+	if len(healthy) == 0 {
+		healthy = g.deployments
+	}
+
+	switch g.strategy {
+	case GroupStrategyLeastInFlight:
+		var best *deployment
+		for _, d := range healthy {
+			// compare inFlight/weight without division
+			if best == nil || d.inFlight.Load()*int64(best.weight) < best.inFlight.Load()*int64(d.weight) {
+				best = d
+			}
+		}
+		return best
+	case GroupStrategyWeightedRandom:
+		total := 0
+		for _, d := range healthy {
+			total += d.weight
+		}
+		n := rand.N(total)
+		for _, d := range healthy {
+			if n < d.weight {
+				return d
+			}
+			n -= d.weight
+		}
+		return healthy[len(healthy)-1]
+	default:
+		// smooth weighted round robin, spreads heavy deployments instead of bursting them
+		total := 0
+		var best *deployment
+		for _, d := range healthy {
+			d.current += d.weight
+			total += d.weight
+			if best == nil || d.current > best.current {
+				best = d
+			}
+		}
+		best.current -= total
+		return best
+	}
+}
+
+func (g *modelGroup) report(d *deployment, err error) {
+	// client errors say nothing about the deployment health
+	if err != nil {
+		switch provider.ClassifyError(d.model.Provider, err) {
+		case ErrorClassOther, ErrorClassContextOverflow, ErrorClassInvalid:
+			return
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err == nil {
+		d.failures = 0
+		return
+	}
+
+	d.failures++
+	if d.failures >= g.ejection.ConsecutiveFailures {
+		d.failures = 0
+		d.ejectedUntil = time.Now().Add(g.ejection.Duration)
+		log.Warnw("model group deployment ejected", "group", g.name, "model", d.model.Name, "provider", d.model.ProviderName, "duration", g.ejection.Duration, "error", err)
+	}
+}
+
+// deploymentProvider tracks in flight requests and failures of a group deployment
+type deploymentProvider struct {
+	provider.Provider
+	group      *modelGroup
+	deployment *deployment
+}
+
+var (
+	_ provider.ErrorClassifier = (*deploymentProvider)(nil)
+	_ provider.Embedder        = (*deploymentProvider)(nil)
+	_ provider.TokenCounter    = (*deploymentProvider)(nil)
+)
+
+func (p *deploymentProvider) Generate(ctx context.Context, messages []*types.Message, options ...types.ChatOption) (*types.Completion, error) {
+	p.deployment.inFlight.Add(1)
+	defer p.deployment.inFlight.Add(-1)
+
+	completion, err := p.Provider.Generate(ctx, messages, options...)
+	p.group.report(p.deployment, err)
+	return completion, err
+}
+
+func (p *deploymentProvider) Realtime(ctx context.Context, messages []*types.Message, options ...types.RealTimeOption) (types.RealTimeSession, error) {
+	session, err := p.Provider.Realtime(ctx, messages, options...)
+	p.group.report(p.deployment, err)
+	return session, err
+}
+
+func (p *deploymentProvider) Embed(ctx context.Context, inputs []string, options ...types.EmbedOption) (*types.Embeddings, error) {
+	embedder, ok := p.Provider.(provider.Embedder)
+	if !ok {
+		return nil, provider.CapabilityError(p.deployment.model.ProviderName, "embeddings")
+	}
+
+	p.deployment.inFlight.Add(1)
+	defer p.deployment.inFlight.Add(-1)
+
+	embeddings, err := embedder.Embed(ctx, inputs, options...)
+	p.group.report(p.deployment, err)
+	return embeddings, err
+}
+
+// CountTokens is not a call the deployment serves, so it is not reported to the group
+func (p *deploymentProvider) CountTokens(ctx context.Context, messages []*types.Message, options ...types.ChatOption) (*types.TokenCount, error) {
+	counter, ok := p.Provider.(provider.TokenCounter)
+	if !ok {
+		return nil, provider.CapabilityError(p.deployment.model.ProviderName, "token counting")
+	}
+	return counter.CountTokens(ctx, messages, options...)
+}
+
+func (p *deploymentProvider) ClassifyError(err error) provider.ErrorClass {
+	return provider.ClassifyError(p.Provider, err)
+}
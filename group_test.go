@@ -0,0 +1,194 @@
+package llmapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xucx/llmapi/internal/providers/provider"
+	"github.com/xucx/llmapi/types"
+)
+
+var (
+	errTestServer = errors.New("upstream 500")
+	errTestClient = errors.New("upstream 400")
+)
+
+// testProvider answers with its name and usage, or fails with err
+type testProvider struct {
+	provider.ProviderNop
+	name  string
+	err   error
+	usage types.CompletionUsage
+	calls atomic.Int64
+
+	mu       sync.Mutex
+	messages []*types.Message // of the last call
+}
+
+func (p *testProvider) Generate(ctx context.Context, messages []*types.Message, options ...types.ChatOption) (*types.Completion, error) {
+	p.calls.Add(1)
+	p.mu.Lock()
+	p.messages = messages
+	p.mu.Unlock()
+
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &types.Completion{Message: types.NewTextMessage(types.MessageRoleAssistant, p.name), Usage: p.usage}, nil
+}
+
+func (p *testProvider) ClassifyError(err error) provider.ErrorClass {
+	if errors.Is(err, errTestServer) {
+		return provider.ErrorClassServer
+	}
+	return provider.ErrorClassOther
+}
+
+func newTestModels(t *testing.T, providers ...*testProvider) *Models {
+	t.Helper()
+
+	m := &Models{models: map[string]*Model{}, groups: map[string]*modelGroup{}}
+	m.contextManagers = m.builtinContextManagers()
+	for _, p := range providers {
+		m.models[p.name] = &Model{Name: p.name, Model: p.name, Provider: p, ProviderName: p.name}
+	}
+	return m
+}
+
+func addTestGroup(t *testing.T, m *Models, conf GroupConfig) *modelGroup {
+	t.Helper()
+
+	g, err := m.newModelGroup(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.groups[g.name] = g
+	return g
+}
+
+func TestGroupWeightedRoundRobin(t *testing.T) {
+	m := newTestModels(t, &testProvider{name: "a"}, &testProvider{name: "b"})
+	g := addTestGroup(t, m, GroupConfig{
+		Name:        "g",
+		Deployments: []DeploymentConfig{{Model: "a", Weight: 3}, {Model: "b", Weight: 1}},
+	})
+
+	picks := ""
+	for range 8 {
+		picks += g.pick().model.Name
+	}
+
+	// smooth weighted round robin spreads b instead of sending a in bursts of three
+	if want := "aabaaaba"; picks != want {
+		t.Errorf("picks %s, want %s", picks, want)
+	}
+}
+
+func TestGroupWeightedRandom(t *testing.T) {
+	m := newTestModels(t, &testProvider{name: "a"}, &testProvider{name: "b"})
+	g := addTestGroup(t, m, GroupConfig{
+		Name:        "g",
+		Strategy:    GroupStrategyWeightedRandom,
+		Deployments: []DeploymentConfig{{Model: "a", Weight: 3}, {Model: "b", Weight: 1}},
+	})
+
+	counts := map[string]int{}
+	for range 4000 {
+		counts[g.pick().model.Name]++
+	}
+	if counts["a"] < 2700 || counts["a"] > 3300 {
+		t.Errorf("a picked %d of 4000 times, want about 3000", counts["a"])
+	}
+}
+
+func TestGroupLeastInFlight(t *testing.T) {
+	m := newTestModels(t, &testProvider{name: "a"}, &testProvider{name: "b"})
+	g := addTestGroup(t, m, GroupConfig{
+		Name:        "g",
+		Strategy:    GroupStrategyLeastInFlight,
+		Deployments: []DeploymentConfig{{Model: "a"}, {Model: "b"}},
+	})
+
+	g.deployments[0].inFlight.Store(2)
+	g.deployments[1].inFlight.Store(1)
+	if got := g.pick().model.Name; got != "b" {
+		t.Errorf("picked %s, want b", got)
+	}
+}
+
+func TestGroupEjection(t *testing.T) {
+	a := &testProvider{name: "a"}
+	b := &testProvider{name: "b", err: errTestServer}
+	m := newTestModels(t, a, b)
+	g := addTestGroup(t, m, GroupConfig{
+		Name:        "g",
+		Deployments: []DeploymentConfig{{Model: "a"}, {Model: "b"}},
+		Ejection:    EjectionConfig{ConsecutiveFailures: 2, Duration: time.Minute},
+	})
+
+	ctx := context.Background()
+	messages := []*types.Message{types.NewTextMessage(types.MessageRoleUser, "hi")}
+	for range 4 {
+		_, _ = m.Generate(ctx, "g", messages)
+	}
+	if got := b.calls.Load(); got != 2 {
+		t.Fatalf("b called %d times before its ejection, want 2", got)
+	}
+
+	for range 4 {
+		if _, err := m.Generate(ctx, "g", messages); err != nil {
+			t.Fatalf("generate with b ejected: %v", err)
+		}
+	}
+	if got := b.calls.Load(); got != 2 {
+		t.Errorf("b called %d times while ejected, want 2", got)
+	}
+	if !time.Now().Before(g.deployments[1].ejectedUntil) {
+		t.Errorf("b is not ejected")
+	}
+}
+
+func TestGroupEjectionIgnoresClientErrors(t *testing.T) {
+	b := &testProvider{name: "b", err: errTestClient}
+	m := newTestModels(t, b)
+	g := addTestGroup(t, m, GroupConfig{
+		Name:        "g",
+		Deployments: []DeploymentConfig{{Model: "b"}},
+		Ejection:    EjectionConfig{ConsecutiveFailures: 1},
+	})
+
+	messages := []*types.Message{types.NewTextMessage(types.MessageRoleUser, "hi")}
+	for range 3 {
+		_, _ = m.Generate(context.Background(), "g", messages)
+	}
+	if !g.deployments[0].ejectedUntil.IsZero() {
+		t.Errorf("b ejected for client errors")
+	}
+}
+
+func TestGroupRetryPicksAnotherDeployment(t *testing.T) {
+	a := &testProvider{name: "a", err: errTestServer}
+	b := &testProvider{name: "b"}
+	m := newTestModels(t, a, b)
+	addTestGroup(t, m, GroupConfig{
+		Name:        "g",
+		Deployments: []DeploymentConfig{{Model: "a"}, {Model: "b"}},
+		Retry:       RetryConfig{MaxAttempts: 2, Backoff: time.Millisecond},
+	})
+
+	messages := []*types.Message{types.NewTextMessage(types.MessageRoleUser, "hi")}
+	completion, err := m.Generate(context.Background(), "g", messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := completion.Message.Text(); got != "b" {
+		t.Errorf("answered by %s, want b", got)
+	}
+	if a.calls.Load() != 1 || b.calls.Load() != 1 {
+		t.Errorf("a called %d and b %d times, want once each", a.calls.Load(), b.calls.Load())
+	}
+}
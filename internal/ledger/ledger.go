@@ -0,0 +1,325 @@
+package ledger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xucx/llmapi/log"
+)
+
+const (
+	SinkJsonl  = "jsonl"
+	SinkSqlite = "sqlite"
+	SinkStdout = "stdout"
+
+	StatusOk    = "ok"
+	StatusError = "error"
+
+	dayLayout = time.DateOnly
+)
+
+// Config of the ledger, an empty sink disables it
+type Config struct {
+	Sink string `yaml:"sink"` // jsonl, sqlite or stdout
+	File string `yaml:"file"` // jsonl file or sqlite database, records in it are loaded at start
+}
+
+// Record is one request served by the api
+type Record struct {
+	Time             time.Time `json:"time"`
+	Key              string    `json:"key,omitempty"` // key name, empty when the server runs without keys
+	Endpoint         string    `json:"endpoint"`
+	Model            string    `json:"model"` // model name the caller asked for
+	Provider         string    `json:"provider,omitempty"`
+	PromptTokens     int64     `json:"promptTokens"`
+	CompletionTokens int64     `json:"completionTokens"`
+	CachedTokens     int64     `json:"cachedTokens"`
+	CacheWriteTokens int64     `json:"cacheWriteTokens,omitempty"`
+	Cost             float64   `json:"cost"` // USD
+	LatencyMs        int64     `json:"latencyMs"`
+	Status           string    `json:"status"`
+	Code             string    `json:"code,omitempty"` // http status or grpc code of a failed request
+}
+
+// Sink keeps the records, any other store can be plugged by implementing it
+type Sink interface {
+	Write(record *Record) error
+	Close() error
+}
+
+type jsonlSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	c   io.Closer
+}
+
+func NewJsonlSink(w io.Writer) Sink {
+	return &jsonlSink{enc: json.NewEncoder(w)}
+}
+
+// NewFileSink appends records to a jsonl file
+func NewFileSink(file string) (Sink, error) {
+	fd, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlSink{enc: json.NewEncoder(fd), c: fd}, nil
+}
+
+func (s *jsonlSink) Write(record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(record)
+}
+
+func (s *jsonlSink) Close() error {
+	if s.c == nil {
+		return nil
+	}
+	return s.c.Close()
+}
+
+// Total is the usage of a group of records
+type Total struct {
+	Key              string  `json:"key,omitempty"`
+	Model            string  `json:"model,omitempty"`
+	Day              string  `json:"day,omitempty"`
+	Requests         int64   `json:"requests"`
+	Errors           int64   `json:"errors"`
+	PromptTokens     int64   `json:"promptTokens"`
+	CompletionTokens int64   `json:"completionTokens"`
+	CachedTokens     int64   `json:"cachedTokens"`
+	CacheWriteTokens int64   `json:"cacheWriteTokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func (t *Total) add(r *Record) {
+	t.Requests++
+	if r.Status != StatusOk {
+		t.Errors++
+	}
+	t.PromptTokens += r.PromptTokens
+	t.CompletionTokens += r.CompletionTokens
+	t.CachedTokens += r.CachedTokens
+	t.CacheWriteTokens += r.CacheWriteTokens
+	t.Cost += r.Cost
+}
+
+func (t *Total) merge(o *Total) {
+	t.Requests += o.Requests
+	t.Errors += o.Errors
+	t.PromptTokens += o.PromptTokens
+	t.CompletionTokens += o.CompletionTokens
+	t.CachedTokens += o.CachedTokens
+	t.CacheWriteTokens += o.CacheWriteTokens
+	t.Cost += o.Cost
+}
+
+type totalKey struct {
+	key   string
+	model string
+	day   string
+}
+
+// Ledger writes records to a sink and keeps daily totals in memory for budgets and reports
+type Ledger struct {
+	sink Sink
+
+	mu     sync.RWMutex
+	totals map[totalKey]*Total
+}
+
+func New(sink Sink) *Ledger {
+	return &Ledger{
+		sink:   sink,
+		totals: map[totalKey]*Total{},
+	}
+}
+
+// Open creates the ledger of the config, nil when it is disabled
+func Open(conf Config) (*Ledger, error) {
+	switch conf.Sink {
+	case "":
+		return nil, nil
+	case SinkStdout:
+		return New(NewJsonlSink(os.Stdout)), nil
+	case SinkJsonl:
+		if conf.File == "" {
+			return nil, errors.New("ledger jsonl sink needs a file")
+		}
+
+		l := New(nil)
+		if fd, err := os.Open(conf.File); err == nil {
+			err = l.Load(fd)
+			fd.Close()
+			if err != nil {
+				return nil, fmt.Errorf("load ledger file %s fail: %w", conf.File, err)
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		sink, err := NewFileSink(conf.File)
+		if err != nil {
+			return nil, err
+		}
+		l.sink = sink
+		return l, nil
+	case SinkSqlite:
+		if conf.File == "" {
+			return nil, errors.New("ledger sqlite sink needs a file")
+		}
+
+		sink, err := openSqliteSink(conf.File)
+		if err != nil {
+			return nil, fmt.Errorf("open ledger database %s fail: %w", conf.File, err)
+		}
+		l := New(sink)
+		if err := sink.load(l); err != nil {
+			sink.Close()
+			return nil, fmt.Errorf("load ledger database %s fail: %w", conf.File, err)
+		}
+		return l, nil
+	default:
+		return nil, fmt.Errorf("ledger sink %s not support", conf.Sink)
+	}
+}
+
+// Load adds the totals of jsonl records, so budgets survive a restart
+func (l *Ledger) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		record := &Record{}
+		if err := json.Unmarshal(line, record); err != nil {
+			return err
+		}
+		l.add(record)
+	}
+	return scanner.Err()
+}
+
+// Record keeps the record, a failed sink is logged and does not fail the request
+func (l *Ledger) Record(record *Record) {
+	l.add(record)
+
+	if l.sink == nil {
+		return
+	}
+	if err := l.sink.Write(record); err != nil {
+		log.Errorw("write ledger record fail", "error", err)
+	}
+}
+
+func (l *Ledger) add(record *Record) {
+	k := totalKey{key: record.Key, model: record.Model, day: record.Time.UTC().Format(dayLayout)}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	total, ok := l.totals[k]
+	if !ok {
+		total = &Total{Key: k.key, Model: k.model, Day: k.day}
+		l.totals[k] = total
+	}
+	total.add(record)
+}
+
+// MonthSpend is the cost of a key in the utc month of now
+func (l *Ledger) MonthSpend(key string, now time.Time) float64 {
+	month := now.UTC().Format("2006-01")
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	spend := 0.0
+	for k, total := range l.totals {
+		if k.key == key && strings.HasPrefix(k.day, month) {
+			spend += total.Cost
+		}
+	}
+	return spend
+}
+
+// Totals groups the daily totals of days in [from, to] by key, model and day,
+// a zero time is unbounded
+func (l *Ledger) Totals(from, to time.Time, groupBy []string) ([]*Total, error) {
+	byKey, byModel, byDay := false, false, false
+	for _, g := range groupBy {
+		switch g {
+		case "key":
+			byKey = true
+		case "model":
+			byModel = true
+		case "day":
+			byDay = true
+		default:
+			return nil, fmt.Errorf("group by %s not support", g)
+		}
+	}
+
+	fromDay, toDay := "", ""
+	if !from.IsZero() {
+		fromDay = from.UTC().Format(dayLayout)
+	}
+	if !to.IsZero() {
+		toDay = to.UTC().Format(dayLayout)
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	groups := map[totalKey]*Total{}
+	for k, total := range l.totals {
+		if (fromDay != "" && k.day < fromDay) || (toDay != "" && k.day > toDay) {
+			continue
+		}
+
+		g := totalKey{}
+		if byKey {
+			g.key = k.key
+		}
+		if byModel {
+			g.model = k.model
+		}
+		if byDay {
+			g.day = k.day
+		}
+
+		group, ok := groups[g]
+		if !ok {
+			group = &Total{Key: g.key, Model: g.model, Day: g.day}
+			groups[g] = group
+		}
+		group.merge(total)
+	}
+
+	ret := make([]*Total, 0, len(groups))
+	for _, group := range groups {
+		ret = append(ret, group)
+	}
+	slices.SortFunc(ret, func(a, b *Total) int {
+		return strings.Compare(a.Day+"\x00"+a.Key+"\x00"+a.Model, b.Day+"\x00"+b.Key+"\x00"+b.Model)
+	})
+	return ret, nil
+}
+
+func (l *Ledger) Close() error {
+	if l.sink == nil {
+		return nil
+	}
+	return l.sink.Close()
+}
@@ -0,0 +1,96 @@
+package ledger
+
+import (
+	"database/sql"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS records (
+	time               TEXT    NOT NULL,
+	key                TEXT    NOT NULL,
+	endpoint           TEXT    NOT NULL,
+	model              TEXT    NOT NULL,
+	provider           TEXT    NOT NULL,
+	prompt_tokens      INTEGER NOT NULL,
+	completion_tokens  INTEGER NOT NULL,
+	cached_tokens      INTEGER NOT NULL,
+	cache_write_tokens INTEGER NOT NULL,
+	cost               REAL    NOT NULL,
+	latency_ms         INTEGER NOT NULL,
+	status             TEXT    NOT NULL,
+	code               TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS records_time ON records (time);
+`
+
+const sqliteInsert = `INSERT INTO records (time, key, endpoint, model, provider, prompt_tokens, completion_tokens,
+	cached_tokens, cache_write_tokens, cost, latency_ms, status, code) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+const sqliteSelect = `SELECT time, key, endpoint, model, provider, prompt_tokens, completion_tokens,
+	cached_tokens, cache_write_tokens, cost, latency_ms, status, code FROM records`
+
+// sqliteSink keeps the records in a table of a sqlite database
+type sqliteSink struct {
+	db     *sql.DB
+	insert *sql.Stmt
+}
+
+// openSqliteSink opens or creates the sqlite database file, with its records table
+func openSqliteSink(file string) (*sqliteSink, error) {
+	db, err := sql.Open("sqlite3", file)
+	if err != nil {
+		return nil, err
+	}
+	// sqlite has a single writer
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	insert, err := db.Prepare(sqliteInsert)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteSink{db: db, insert: insert}, nil
+}
+
+func (s *sqliteSink) Write(record *Record) error {
+	_, err := s.insert.Exec(record.Time.UTC().Format(time.RFC3339Nano), record.Key, record.Endpoint, record.Model, record.Provider,
+		record.PromptTokens, record.CompletionTokens, record.CachedTokens, record.CacheWriteTokens,
+		record.Cost, record.LatencyMs, record.Status, record.Code)
+	return err
+}
+
+// load adds the totals of the records in the database to the ledger
+func (s *sqliteSink) load(l *Ledger) error {
+	rows, err := s.db.Query(sqliteSelect)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		record := &Record{}
+		var t string
+		if err := rows.Scan(&t, &record.Key, &record.Endpoint, &record.Model, &record.Provider,
+			&record.PromptTokens, &record.CompletionTokens, &record.CachedTokens, &record.CacheWriteTokens,
+			&record.Cost, &record.LatencyMs, &record.Status, &record.Code); err != nil {
+			return err
+		}
+		if record.Time, err = time.Parse(time.RFC3339Nano, t); err != nil {
+			return err
+		}
+		l.add(record)
+	}
+	return rows.Err()
+}
+
+func (s *sqliteSink) Close() error {
+	s.insert.Close()
+	return s.db.Close()
+}
@@ -0,0 +1,44 @@
+package ledger
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSqliteSink(t *testing.T) {
+	conf := Config{Sink: SinkSqlite, File: filepath.Join(t.TempDir(), "usage.db")}
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	l, err := Open(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Record(&Record{Time: now, Key: "a", Model: "gpt-4", PromptTokens: 10, CompletionTokens: 5, Cost: 0.5, Status: StatusOk})
+	l.Record(&Record{Time: now, Key: "a", Model: "gpt-4", Status: StatusError, Code: "502"})
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the records are loaded again at start
+	l, err = Open(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	totals, err := l.Totals(time.Time{}, time.Time{}, []string{"key", "model", "day"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(totals) != 1 {
+		t.Fatalf("%d totals, want 1", len(totals))
+	}
+	want := Total{Key: "a", Model: "gpt-4", Day: "2025-01-02", Requests: 2, Errors: 1, PromptTokens: 10, CompletionTokens: 5, Cost: 0.5}
+	if *totals[0] != want {
+		t.Errorf("total %+v, want %+v", *totals[0], want)
+	}
+	if spend := l.MonthSpend("a", now); spend != 0.5 {
+		t.Errorf("month spend %v, want 0.5", spend)
+	}
+}
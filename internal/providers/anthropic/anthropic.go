@@ -1,6 +1,8 @@
 package anthropic
 
 import (
+	"errors"
+
 	"github.com/xucx/llmapi/internal/providers/provider"
 
 	"github.com/anthropics/anthropic-sdk-go"
@@ -10,6 +12,18 @@ import (
 const (
 	ProviderName     = "anthropic"
 	DefaultChatModel = string(anthropic.ModelClaudeSonnet4_0)
+	DefaultMaxTokens = 8192
+
+	// thinking budget of reasoning without a budget or an effort
+	DefaultThinkingBudget = 8192
+	MinThinkingBudget     = 1024
+
+	// tool forced for json responses, see toResponseFormat
+	ResponseToolName = "json_response"
+
+	// decoded size limits of inline images and documents
+	MaxImageSize    = 5 << 20
+	MaxDocumentSize = 32 << 20
 )
 
 const (
@@ -19,7 +33,9 @@ const (
 )
 
 var (
-	_ provider.Provider = (*AnthropicProvider)(nil)
+	_ provider.Provider        = (*AnthropicProvider)(nil)
+	_ provider.ErrorClassifier = (*AnthropicProvider)(nil)
+	_ provider.TokenCounter    = (*AnthropicProvider)(nil)
 )
 
 type AnthropicProvider struct {
@@ -32,6 +48,7 @@ func NewAnthropicProvider(opts ...provider.ProviderOption) (provider.Provider, e
 
 	config := []option.RequestOption{
 		option.WithAPIKey(options.Sk),
+		option.WithHTTPClient(provider.HttpClient),
 	}
 
 	client := anthropic.NewClient(config...)
@@ -39,3 +56,11 @@ func NewAnthropicProvider(opts ...provider.ProviderOption) (provider.Provider, e
 		client: &client,
 	}, nil
 }
+
+func (p *AnthropicProvider) ClassifyError(err error) provider.ErrorClass {
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		return provider.ClassifyStatus(apiErr.StatusCode, apiErr.RawJSON())
+	}
+	return provider.ErrorClassOther
+}
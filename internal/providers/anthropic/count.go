@@ -0,0 +1,41 @@
+package anthropic
+
+import (
+	"context"
+
+	"github.com/xucx/llmapi/types"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// CountTokens uses the count_tokens endpoint, which takes the request without sampling params
+func (p *AnthropicProvider) CountTokens(ctx context.Context, messages []*types.Message, options ...types.ChatOption) (*types.TokenCount, error) {
+	opts := types.GetChatOptions(&types.ChatOptions{
+		Model: DefaultChatModel,
+	}, options...)
+
+	params, err := toChatParams(messages, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	countParams := anthropic.MessageCountTokensParams{
+		Messages:   params.Messages,
+		Model:      params.Model,
+		Thinking:   params.Thinking,
+		ToolChoice: params.ToolChoice,
+	}
+	if len(params.System) > 0 {
+		countParams.System = anthropic.MessageCountTokensParamsSystemUnion{OfTextBlockArray: params.System}
+	}
+	for _, tool := range params.Tools {
+		countParams.Tools = append(countParams.Tools, anthropic.MessageCountTokensToolUnionParam{OfTool: tool.OfTool})
+	}
+
+	rsp, err := p.client.Messages.CountTokens(ctx, countParams)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.TokenCount{Model: opts.Model, InputTokens: rsp.InputTokens}, nil
+}
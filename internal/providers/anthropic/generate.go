@@ -2,12 +2,16 @@ package anthropic
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"strings"
 
+	"github.com/xucx/llmapi/internal/providers/provider"
 	"github.com/xucx/llmapi/types"
 
 	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/packages/param"
 	"github.com/anthropics/anthropic-sdk-go/shared/constant"
 )
 
@@ -20,31 +24,92 @@ func (p *AnthropicProvider) Generate(ctx context.Context, messages []*types.Mess
 	if err != nil {
 		return nil, err
 	}
+	jsonResponse := isJSONResponse(opts)
 
-	var (
-		rsp *anthropic.Message
-	)
-
-	if opts.StreamingFunc == nil {
-		rsp, err = p.client.Messages.New(ctx, *params)
+	if opts.StreamingFunc == nil && opts.StreamingAccFunc == nil {
+		rsp, err := p.client.Messages.New(ctx, *params)
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		return nil, fmt.Errorf("anthropic generate streaming not impl")
+		return fromChatCompletion(rsp, false, jsonResponse)
 	}
 
-	return fromChatCompletion(rsp)
+	acc := &anthropic.Message{}
+	stream := p.client.Messages.NewStreaming(ctx, *params)
+	defer stream.Close()
+
+	for stream.Next() {
+		event := stream.Current()
+		if err := acc.Accumulate(event); err != nil {
+			return nil, err
+		}
+
+		if opts.StreamingFunc != nil {
+			chunkCompletion := fromChatStreamEvent(acc, &event, jsonResponse)
+			if chunkCompletion != nil {
+				if err := opts.StreamingFunc(ctx, chunkCompletion); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if opts.StreamingAccFunc != nil {
+			accCompletion, err := fromChatCompletion(acc, true, jsonResponse)
+			if err != nil {
+				return nil, err
+			}
+			if err := opts.StreamingAccFunc(ctx, accCompletion); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+
+	return fromChatCompletion(acc, false, jsonResponse)
 }
 
 func toChatParams(messages []*types.Message, opts *types.ChatOptions) (*anthropic.MessageNewParams, error) {
 	params := &anthropic.MessageNewParams{}
 
 	params.Model = anthropic.Model(opts.Model)
+
+	// max_tokens is required by anthropic
+	params.MaxTokens = DefaultMaxTokens
+	if opts.MaxTokens != nil {
+		params.MaxTokens = *opts.MaxTokens
+	}
+
 	if opts.Temperature != nil {
 		params.Temperature = anthropic.Float(float64(*opts.Temperature))
 	}
 
+	if opts.TopP != nil {
+		params.TopP = anthropic.Float(float64(*opts.TopP))
+	}
+
+	if opts.TopK != nil {
+		params.TopK = anthropic.Int(int64(*opts.TopK))
+	}
+
+	if len(opts.StopSequences) > 0 {
+		params.StopSequences = opts.StopSequences
+	}
+
+	if opts.AudioVoice != "" {
+		return nil, provider.CapabilityError(ProviderName, "audio voice")
+	}
+
+	if opts.Instructions != "" {
+		params.System = append(params.System, anthropic.TextBlockParam{Text: opts.Instructions})
+	}
+
+	if opts.Reasoning != nil {
+		toThinking(params, opts.Reasoning)
+	}
+
 	if err := toChatMessages(params, messages); err != nil {
 		return nil, err
 	}
@@ -53,9 +118,33 @@ func toChatParams(messages []*types.Message, opts *types.ChatOptions) (*anthropi
 		return nil, err
 	}
 
+	if err := toResponseFormat(params, opts); err != nil {
+		return nil, err
+	}
+
 	return params, nil
 }
 
+// toThinking sets extended thinking, whose thoughts are always returned
+func toThinking(params *anthropic.MessageNewParams, reasoning *types.Reasoning) {
+	if !reasoning.Enabled {
+		params.Thinking = anthropic.ThinkingConfigParamUnion{OfDisabled: &anthropic.ThinkingConfigDisabledParam{}}
+		return
+	}
+
+	budget := reasoning.Budget()
+	if budget <= 0 {
+		budget = DefaultThinkingBudget
+	}
+	budget = max(budget, MinThinkingBudget)
+	params.Thinking = anthropic.ThinkingConfigParamOfEnabled(budget)
+
+	// the budget is part of max_tokens
+	if params.MaxTokens <= budget {
+		params.MaxTokens = budget + DefaultMaxTokens
+	}
+}
+
 func toChatMessages(params *anthropic.MessageNewParams, messages []*types.Message) error {
 	for _, msg := range messages {
 		toMessage := anthropic.MessageParam{}
@@ -82,30 +171,32 @@ func toChatMessages(params *anthropic.MessageNewParams, messages []*types.Messag
 						Text: part.Text.Text,
 					},
 				}
+			case part.Reasoning != nil:
+				// anthropic only takes back thinking with its signature
+				if part.Reasoning.ThoughtSignature == "" {
+					continue
+				}
+				toPart = &anthropic.ContentBlockParamUnion{
+					OfThinking: &anthropic.ThinkingBlockParam{
+						Thinking:  part.Reasoning.Text,
+						Signature: part.Reasoning.ThoughtSignature,
+					},
+				}
 			case part.Refusal != nil:
 				// not support
+				continue
 			case part.ImageURL != nil:
-				toPart = &anthropic.ContentBlockParamUnion{
-					OfImage: &anthropic.ImageBlockParam{
-						Source: anthropic.ImageBlockParamSourceUnion{
-							OfURL: &anthropic.URLImageSourceParam{
-								URL: part.ImageURL.URL,
-							},
-						},
-					},
+				block, err := toImageBlock(part.ImageURL)
+				if err != nil {
+					return err
 				}
+				toPart = block
 			case part.File != nil:
-				toPart = &anthropic.ContentBlockParamUnion{
-					OfDocument: &anthropic.DocumentBlockParam{
-						Source: anthropic.DocumentBlockParamSourceUnion{
-							OfBase64: &anthropic.Base64PDFSourceParam{
-								Data:      part.File.Data,
-								MediaType: constant.ApplicationPDF(part.File.MIMEType), //?? check
-							},
-						},
-					},
+				block, err := toFileBlock(part.File)
+				if err != nil {
+					return err
 				}
-
+				toPart = block
 			case part.Audio != nil:
 				// not support
 				continue
@@ -114,11 +205,16 @@ func toChatMessages(params *anthropic.MessageNewParams, messages []*types.Messag
 				if part.ToolCall.Type != types.ToolTypeFunction || part.ToolCall.Function == nil {
 					continue
 				}
+				// input is the json object of the arguments, not a string
+				input := json.RawMessage(part.ToolCall.Function.Arguments)
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
 				toPart = &anthropic.ContentBlockParamUnion{
 					OfToolUse: &anthropic.ToolUseBlockParam{
 						ID:    part.ToolCall.ID,
 						Name:  part.ToolCall.Function.Name,
-						Input: part.ToolCall.Function.Arguments,
+						Input: input,
 					},
 				}
 
@@ -137,6 +233,15 @@ func toChatMessages(params *anthropic.MessageNewParams, messages []*types.Messag
 				}
 			}
 
+			if toPart == nil {
+				continue
+			}
+			if part.CacheControl != nil {
+				// thinking blocks can not be cached
+				if cacheControl := toPart.GetCacheControl(); cacheControl != nil {
+					*cacheControl = toCacheControl(part.CacheControl)
+				}
+			}
 			toMessage.Content = append(toMessage.Content, *toPart)
 		}
 
@@ -161,85 +266,370 @@ func toChatTools(params *anthropic.MessageNewParams, opts *types.ChatOptions) er
 			continue
 		}
 
-		inputSchema := anthropic.ToolInputSchemaParam{}
-		if v, ok := tool.Function.Parameters["properties"]; ok {
-			inputSchema.Properties = v
+		toTool := anthropic.ToolUnionParam{
+			OfTool: &anthropic.ToolParam{
+				Name:        tool.Function.Name,
+				Description: anthropic.String(tool.Function.Description),
+				InputSchema: toInputSchema(tool.Function.Parameters),
+			},
+		}
+		if tool.CacheControl != nil {
+			toTool.OfTool.CacheControl = toCacheControl(tool.CacheControl)
+		}
+
+		params.Tools = append(params.Tools, toTool)
+	}
+
+	// tool_choice is only accepted with tools
+	if len(params.Tools) == 0 || (opts.ToolChoice == nil && opts.ParallelToolCalls == nil) {
+		return nil
+	}
+
+	choice := opts.ToolChoice
+	if choice == nil {
+		choice = &types.ToolChoice{Type: types.ToolChoiceAuto}
+	}
+
+	disableParallel := param.Opt[bool]{}
+	if opts.ParallelToolCalls != nil {
+		disableParallel = anthropic.Bool(!*opts.ParallelToolCalls)
+	}
+
+	switch choice.Type {
+	case types.ToolChoiceAuto:
+		params.ToolChoice = anthropic.ToolChoiceUnionParam{OfAuto: &anthropic.ToolChoiceAutoParam{DisableParallelToolUse: disableParallel}}
+	case types.ToolChoiceNone:
+		params.ToolChoice = anthropic.ToolChoiceUnionParam{OfNone: &anthropic.ToolChoiceNoneParam{}}
+	case types.ToolChoiceRequired:
+		params.ToolChoice = anthropic.ToolChoiceUnionParam{OfAny: &anthropic.ToolChoiceAnyParam{DisableParallelToolUse: disableParallel}}
+	case types.ToolChoiceFunction:
+		params.ToolChoice = anthropic.ToolChoiceUnionParam{OfTool: &anthropic.ToolChoiceToolParam{Name: choice.Name, DisableParallelToolUse: disableParallel}}
+	default:
+		return fmt.Errorf("anthropic not support tool choice %s", choice.Type)
+	}
+
+	return nil
+}
+
+// toImageBlock sends data urls as base64 images with the type of their bytes, other urls as they are
+func toImageBlock(image *types.MessageImageURL) (*anthropic.ContentBlockParamUnion, error) {
+	mimeType, data, ok := types.ParseDataURL(image.URL)
+	if !ok {
+		return &anthropic.ContentBlockParamUnion{
+			OfImage: &anthropic.ImageBlockParam{
+				Source: anthropic.ImageBlockParamSourceUnion{
+					OfURL: &anthropic.URLImageSourceParam{URL: image.URL},
+				},
+			},
+		}, nil
+	}
+
+	b, err := provider.DecodeMedia(data, MaxImageSize)
+	if err != nil {
+		return nil, fmt.Errorf("image: %w", err)
+	}
+	return toBase64ImageBlock(provider.SniffMIMEType(mimeType, b), b)
+}
+
+func toBase64ImageBlock(mimeType string, data []byte) (*anthropic.ContentBlockParamUnion, error) {
+	switch mediaType := anthropic.Base64ImageSourceMediaType(mimeType); mediaType {
+	case anthropic.Base64ImageSourceMediaTypeImageJPEG,
+		anthropic.Base64ImageSourceMediaTypeImagePNG,
+		anthropic.Base64ImageSourceMediaTypeImageGIF,
+		anthropic.Base64ImageSourceMediaTypeImageWebP:
+		return &anthropic.ContentBlockParamUnion{
+			OfImage: &anthropic.ImageBlockParam{
+				Source: anthropic.ImageBlockParamSourceUnion{
+					OfBase64: &anthropic.Base64ImageSourceParam{
+						Data:      base64.StdEncoding.EncodeToString(data),
+						MediaType: mediaType,
+					},
+				},
+			},
+		}, nil
+	default:
+		return nil, provider.CapabilityError(ProviderName, "image type "+mimeType)
+	}
+}
+
+// toFileBlock sends pdfs and text files as documents, image files as images
+func toFileBlock(file *types.MessageFile) (*anthropic.ContentBlockParamUnion, error) {
+	b, err := provider.DecodeMedia(file.Data, MaxDocumentSize)
+	if err != nil {
+		return nil, fmt.Errorf("file %s: %w", file.Name, err)
+	}
+
+	mimeType := provider.SniffMIMEType(file.MIMEType, b)
+	document := &anthropic.DocumentBlockParam{}
+	switch {
+	case mimeType == "application/pdf":
+		document.Source = anthropic.DocumentBlockParamSourceUnion{
+			OfBase64: &anthropic.Base64PDFSourceParam{Data: base64.StdEncoding.EncodeToString(b)},
 		}
-		if v, ok := tool.Function.Parameters["type"]; ok {
+	case strings.HasPrefix(mimeType, "text/"):
+		document.Source = anthropic.DocumentBlockParamSourceUnion{
+			OfText: &anthropic.PlainTextSourceParam{Data: string(b)},
+		}
+	case strings.HasPrefix(mimeType, "image/"):
+		if len(b) > MaxImageSize {
+			return nil, fmt.Errorf("file %s: %w: %d bytes, limit %d", file.Name, provider.ErrMediaTooLarge, len(b), MaxImageSize)
+		}
+		return toBase64ImageBlock(mimeType, b)
+	default:
+		return nil, provider.CapabilityError(ProviderName, "file type "+mimeType)
+	}
+
+	if file.Name != "" {
+		document.Title = anthropic.String(file.Name)
+	}
+	return &anthropic.ContentBlockParamUnion{OfDocument: document}, nil
+}
+
+// toCacheControl is an ephemeral breakpoint, the sdk has no field for its ttl yet
+func toCacheControl(cacheControl *types.CacheControl) anthropic.CacheControlEphemeralParam {
+	param := anthropic.NewCacheControlEphemeralParam()
+	if cacheControl.TTL != "" {
+		param.SetExtraFields(map[string]any{"ttl": cacheControl.TTL})
+	}
+	return param
+}
+
+func toInputSchema(schema map[string]any) anthropic.ToolInputSchemaParam {
+	inputSchema := anthropic.ToolInputSchemaParam{}
+	for k, v := range schema {
+		switch k {
+		case "properties":
+			inputSchema.Properties = v
+		case "type":
 			if vv, ok := v.(string); ok {
 				inputSchema.Type = constant.Object(vv)
 			}
-		}
-		if v, ok := tool.Function.Parameters["required"]; ok {
-			if vv, ok := v.([]string); ok {
+		case "required":
+			switch vv := v.(type) {
+			case []string:
 				inputSchema.Required = vv
+			case []any:
+				// schemas decoded from json
+				for _, name := range vv {
+					if name, ok := name.(string); ok {
+						inputSchema.Required = append(inputSchema.Required, name)
+					}
+				}
 			}
+		default:
+			if inputSchema.ExtraFields == nil {
+				inputSchema.ExtraFields = map[string]any{}
+			}
+			inputSchema.ExtraFields[k] = v
 		}
+	}
+	return inputSchema
+}
 
-		toTool := anthropic.ToolUnionParam{
-			OfTool: &anthropic.ToolParam{
-				Name:        tool.Function.Name,
-				Description: anthropic.String(tool.Function.Description),
-				InputSchema: inputSchema,
-			},
+func isJSONResponse(opts *types.ChatOptions) bool {
+	return opts.ResponseFormat != nil &&
+		(opts.ResponseFormat.Type == types.ResponseFormatJSONObject || opts.ResponseFormat.Type == types.ResponseFormatJSONSchema)
+}
+
+// toResponseFormat emulates json output, which anthropic does not have, by forcing
+// the model to call a tool, the input of the call is returned as text
+func toResponseFormat(params *anthropic.MessageNewParams, opts *types.ChatOptions) error {
+	format := opts.ResponseFormat
+	if format == nil {
+		return nil
+	}
+
+	schema := map[string]any{"type": "object"}
+	switch format.Type {
+	case types.ResponseFormatText, "":
+		return nil
+	case types.ResponseFormatJSONObject:
+	case types.ResponseFormatJSONSchema:
+		if format.Schema != nil {
+			schema = format.Schema
 		}
+	default:
+		return fmt.Errorf("anthropic not support response format %s", format.Type)
+	}
 
-		params.Tools = append(params.Tools, toTool)
+	// the response is a forced tool call, which can not go with another tool choice or with thinking
+	if opts.ToolChoice != nil {
+		return provider.CapabilityError(ProviderName, "response format with tool choice")
+	}
+	if opts.Reasoning != nil && opts.Reasoning.Enabled {
+		return provider.CapabilityError(ProviderName, "response format with reasoning")
 	}
 
+	description := format.Description
+	if description == "" {
+		description = "Respond by calling this tool with the response as input."
+	}
+
+	params.Tools = append(params.Tools, anthropic.ToolUnionParam{
+		OfTool: &anthropic.ToolParam{
+			Name:        ResponseToolName,
+			Description: anthropic.String(description),
+			InputSchema: toInputSchema(schema),
+		},
+	})
+	params.ToolChoice = anthropic.ToolChoiceParamOfTool(ResponseToolName)
 	return nil
 }
 
-func fromChatCompletion(msg *anthropic.Message) (*types.Completion, error) {
+// fromChatStreamEvent converts one stream event to a delta completion, it returns nil
+// when the event carries nothing for the caller (ping, block stop, message stop...)
+func fromChatStreamEvent(acc *anthropic.Message, event *anthropic.MessageStreamEventUnion, jsonResponse bool) *types.Completion {
+	completion := &types.Completion{
+		Delta: true,
+		Model: string(acc.Model),
+		Message: &types.Message{
+			ID:   acc.ID,
+			Role: types.MessageRoleAssistant,
+		},
+	}
+
+	var part *types.MessagePart
+
+	switch variant := event.AsAny().(type) {
+	case anthropic.ContentBlockStartEvent:
+		// only tool use carries content at block start, text and thinking come with deltas
+		if variant.ContentBlock.Type == "tool_use" && !(jsonResponse && variant.ContentBlock.Name == ResponseToolName) {
+			part = &types.MessagePart{ToolCall: &types.MessageToolCall{
+				ID:   variant.ContentBlock.ID,
+				Type: types.ToolTypeFunction,
+				Function: &types.ToolCallFunction{
+					Name: variant.ContentBlock.Name,
+				},
+			}}
+		}
+	case anthropic.ContentBlockDeltaEvent:
+		switch delta := variant.Delta.AsAny().(type) {
+		case anthropic.TextDelta:
+			part = &types.MessagePart{Text: &types.MessageText{Text: delta.Text, Delta: true}}
+		case anthropic.ThinkingDelta:
+			part = &types.MessagePart{Reasoning: &types.MessageReasoning{Text: delta.Thinking}}
+		case anthropic.SignatureDelta:
+			part = &types.MessagePart{Reasoning: &types.MessageReasoning{ThoughtSignature: delta.Signature}}
+		case anthropic.InputJSONDelta:
+			if delta.PartialJSON == "" || len(acc.Content) == 0 {
+				break
+			}
+			block := acc.Content[len(acc.Content)-1]
+			if jsonResponse && block.Name == ResponseToolName {
+				part = &types.MessagePart{Text: &types.MessageText{Text: delta.PartialJSON, Delta: true}}
+				break
+			}
+			part = &types.MessagePart{ToolCall: &types.MessageToolCall{
+				ID:   block.ID,
+				Type: types.ToolTypeFunction,
+				Function: &types.ToolCallFunction{
+					Arguments: delta.PartialJSON,
+				},
+			}}
+		}
+	case anthropic.MessageDeltaEvent:
+		// the last delta of a message carries the final usage
+		completion.Usage = fromChatUsage(acc.Usage)
+		completion.FinishReason = fromStopReason(variant.Delta.StopReason, jsonResponse)
+		return completion
+	}
+
+	if part == nil {
+		return nil
+	}
+
+	completion.Message.Parts = append(completion.Message.Parts, part)
+	return completion
+}
+
+// input tokens of anthropic exclude the cache, which is counted in the prompt like other providers
+func fromChatUsage(usage anthropic.Usage) types.CompletionUsage {
+	promptTokens := usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens
+	return types.CompletionUsage{
+		CompletionTokens: usage.OutputTokens,
+		PromptTokens:     promptTokens,
+		TotalTokens:      promptTokens + usage.OutputTokens,
+		CachedTokens:     usage.CacheReadInputTokens,
+		CacheWriteTokens: usage.CacheCreationInputTokens,
+	}
+}
+
+func fromStopReason(reason anthropic.StopReason, jsonResponse bool) types.FinishReason {
+	switch reason {
+	case "":
+		return ""
+	case anthropic.StopReasonMaxTokens:
+		return types.FinishReasonLength
+	case anthropic.StopReasonToolUse:
+		// the forced tool of a json response is the answer
+		if jsonResponse {
+			return types.FinishReasonStop
+		}
+		return types.FinishReasonToolCalls
+	case anthropic.StopReasonRefusal:
+		return types.FinishReasonContentFilter
+	default:
+		return types.FinishReasonStop
+	}
+}
+
+func fromChatCompletion(msg *anthropic.Message, delta bool, jsonResponse bool) (*types.Completion, error) {
 
 	completion := &types.Completion{
+		Delta: delta,
 		Model: string(msg.Model),
 		Message: &types.Message{
+			ID:   msg.ID,
 			Role: types.MessageRoleAssistant,
 		},
-		Usage: types.CompletionUsage{
-			CompletionTokens: msg.Usage.OutputTokens,
-			PromptTokens:     msg.Usage.InputTokens,
-			TotalTokens:      msg.Usage.InputTokens + msg.Usage.OutputTokens,
-		},
+		Usage:        fromChatUsage(msg.Usage),
+		FinishReason: fromStopReason(msg.StopReason, jsonResponse),
 	}
 
 	var (
-		contentBuf   = strings.Builder{}
-		reasoningBuf = strings.Builder{}
+		reasonings = []*types.MessagePart{}
+		contentBuf = strings.Builder{}
+		toolCalls  = []*types.MessagePart{}
 	)
 
+	// read the union fields directly instead of AsAny, blocks accumulated from
+	// a stream are not re-serialized until their content_block_stop arrives
 	for _, c := range msg.Content {
-		switch variant := c.AsAny().(type) {
-		case anthropic.ThinkingBlock:
-			reasoningBuf.WriteString(variant.Thinking)
-		case anthropic.TextBlock:
-			contentBuf.WriteString(variant.Text)
-		case anthropic.ToolUseBlock:
-			completion.Message.Parts = append(completion.Message.Parts, &types.MessagePart{
+		switch c.Type {
+		case "thinking":
+			// a part for each block, the signature only holds for the thinking of its block
+			reasonings = append(reasonings, &types.MessagePart{Reasoning: &types.MessageReasoning{
+				Text:             c.Thinking,
+				ThoughtSignature: c.Signature,
+			}})
+		case "text":
+			contentBuf.WriteString(c.Text)
+		case "tool_use":
+			if jsonResponse && c.Name == ResponseToolName {
+				contentBuf.Write(c.Input)
+				continue
+			}
+			toolCalls = append(toolCalls, &types.MessagePart{
 				ToolCall: &types.MessageToolCall{
-					ID:   variant.ID,
-					Type: "function",
+					ID:   c.ID,
+					Type: types.ToolTypeFunction,
 					Function: &types.ToolCallFunction{
-						Name:      variant.Name,
-						Arguments: string(variant.Input),
+						Name:      c.Name,
+						Arguments: string(c.Input),
 					},
 				},
 			})
-
-		case anthropic.RedactedThinkingBlock:
+		case "redacted_thinking":
 			//
 		}
 	}
 
-	reasoning := reasoningBuf.String()
-	if reasoning != "" {
-		completion.Message.Parts = append(completion.Message.Parts, &types.MessagePart{Reasoning: &types.MessageReasoning{Text: reasoning}})
-	}
+	completion.Message.Parts = append(completion.Message.Parts, reasonings...)
 	content := contentBuf.String()
 	if content != "" {
 		completion.Message.Parts = append(completion.Message.Parts, &types.MessagePart{Text: &types.MessageText{Text: content}})
 	}
+	completion.Message.Parts = append(completion.Message.Parts, toolCalls...)
 
 	return completion, nil
 }
@@ -0,0 +1,180 @@
+package google
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/xucx/llmapi/log"
+	"github.com/xucx/llmapi/types"
+
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/genai"
+)
+
+const (
+	DefaultCacheTTL = 5 * time.Minute
+
+	// a cached content this close to its expiry is created again
+	cacheExpiryMargin = 10 * time.Second
+
+	// a prefix whose cached content can not be created, eg below the minimum size, is sent
+	// without a cache for a while instead of trying again on each request
+	cacheFailureBackoff = 10 * time.Minute
+)
+
+// cachedContents are the gemini cached contents created for the prefixes marked with a cache
+// control, so requests with the same prefix share one until it expires
+type cachedContents struct {
+	mu       sync.Mutex
+	entries  map[string]*genai.CachedContent // by the hash of model, prefix, system instruction and tools
+	failures map[string]time.Time            // until when a prefix is sent without a cache
+
+	// concurrent requests with the same prefix create one cached content
+	creates singleflight.Group
+}
+
+type createdContent struct {
+	cached      *genai.CachedContent
+	writeTokens int64
+}
+
+func newCachedContents() *cachedContents {
+	return &cachedContents{
+		entries:  map[string]*genai.CachedContent{},
+		failures: map[string]time.Time{},
+	}
+}
+
+// toCacheControl is the cache control of the marked contents, or of marked tools, nil without any
+func toCacheControl(contentsOpts *contentsOpt, tools []*types.Tool) *types.CacheControl {
+	if contentsOpts.cacheControl != nil {
+		return contentsOpts.cacheControl
+	}
+	for _, tool := range tools {
+		if tool.CacheControl != nil {
+			return tool.CacheControl
+		}
+	}
+	return nil
+}
+
+// use moves the first n contents, the system instruction and the tools of config into a cached
+// content, the contents left are returned with the tokens written when the cache was created now.
+// The request is sent as it is when the cache can not be created, eg below the minimum size.
+func (c *cachedContents) use(ctx context.Context, client *genai.Client, model string, contents []*genai.Content, n int, config *genai.GenerateContentConfig, cacheControl *types.CacheControl) ([]*genai.Content, int64) {
+	// clients usually mark the last turn, which is still sent, gemini needs contents in the request
+	n = min(n, len(contents)-1)
+	if n < 0 || n == 0 && config.SystemInstruction == nil && len(config.Tools) == 0 {
+		return contents, 0
+	}
+	prefix := contents[:n]
+
+	b, err := json.Marshal([]any{model, prefix, config.SystemInstruction, config.Tools, config.ToolConfig})
+	if err != nil {
+		return contents, 0
+	}
+	sum := sha256.Sum256(b)
+	key := hex.EncodeToString(sum[:])
+
+	now := time.Now()
+	var writeTokens int64
+
+	c.mu.Lock()
+	cached, ok := c.entries[key]
+	failedUntil := c.failures[key]
+	c.mu.Unlock()
+
+	if !ok || cached.ExpireTime.Before(now.Add(cacheExpiryMargin)) {
+		if now.Before(failedUntil) {
+			return contents, 0
+		}
+
+		creator := false
+		v, err, _ := c.creates.Do(key, func() (any, error) {
+			creator = true
+			// the cached content outlives the request which creates it
+			return c.create(context.WithoutCancel(ctx), client, key, model, prefix, config, cacheControl)
+		})
+		if err != nil {
+			return contents, 0
+		}
+
+		created := v.(*createdContent)
+		cached = created.cached
+		// the write is billed once, to the request which created it
+		if creator {
+			writeTokens = created.writeTokens
+		}
+	}
+
+	// a request using a cached content can not set what the cache holds
+	config.CachedContent = cached.Name
+	config.SystemInstruction = nil
+	config.Tools = nil
+	config.ToolConfig = nil
+
+	return contents[n:], writeTokens
+}
+
+// create creates the cached content of a prefix, or remembers that it failed
+func (c *cachedContents) create(ctx context.Context, client *genai.Client, key string, model string, prefix []*genai.Content, config *genai.GenerateContentConfig, cacheControl *types.CacheControl) (*createdContent, error) {
+	now := time.Now()
+
+	// created by a request which finished just before this one started waiting
+	c.mu.Lock()
+	cached, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && cached.ExpireTime.After(now.Add(cacheExpiryMargin)) {
+		return &createdContent{cached: cached}, nil
+	}
+
+	ttl := DefaultCacheTTL
+	if cacheControl.TTL != "" {
+		if d, err := time.ParseDuration(cacheControl.TTL); err == nil && d > 0 {
+			ttl = d
+		}
+	}
+
+	cached, err := client.Caches.Create(ctx, model, &genai.CreateCachedContentConfig{
+		TTL:               ttl,
+		Contents:          prefix,
+		SystemInstruction: config.SystemInstruction,
+		Tools:             config.Tools,
+		ToolConfig:        config.ToolConfig,
+	})
+	if err != nil {
+		log.Warnw("gemini cached content fail, send without cache", "model", model, "retry", cacheFailureBackoff, "error", err)
+		c.mu.Lock()
+		c.failures[key] = now.Add(cacheFailureBackoff)
+		c.mu.Unlock()
+		return nil, err
+	}
+	if cached.ExpireTime.IsZero() {
+		cached.ExpireTime = now.Add(ttl)
+	}
+
+	created := &createdContent{cached: cached}
+	if cached.UsageMetadata != nil {
+		created.writeTokens = int64(cached.UsageMetadata.TotalTokenCount)
+	}
+
+	c.mu.Lock()
+	for k, entry := range c.entries {
+		if entry.ExpireTime.Before(now) {
+			delete(c.entries, k)
+		}
+	}
+	for k, until := range c.failures {
+		if until.Before(now) {
+			delete(c.failures, k)
+		}
+	}
+	c.entries[key] = cached
+	c.mu.Unlock()
+
+	return created, nil
+}
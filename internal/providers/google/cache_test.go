@@ -0,0 +1,139 @@
+package google
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/xucx/llmapi/types"
+
+	"google.golang.org/genai"
+)
+
+// newTestCacheClient is a gemini client whose cached content creations are answered by handler
+func newTestCacheClient(t *testing.T, handler http.HandlerFunc) *genai.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:      "test",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: genai.HTTPOptions{BaseURL: srv.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func testContents(texts ...string) []*genai.Content {
+	contents := []*genai.Content{}
+	for _, text := range texts {
+		contents = append(contents, genai.NewContentFromText(text, genai.RoleUser))
+	}
+	return contents
+}
+
+func TestCachedContentsUse(t *testing.T) {
+	var creates atomic.Int32
+	var cached atomic.Int32
+	client := newTestCacheClient(t, func(w http.ResponseWriter, r *http.Request) {
+		creates.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		cached.Store(int32(strings.Count(string(body), `"role":"user"`)))
+		w.Write([]byte(`{"name":"cachedContents/1","usageMetadata":{"totalTokenCount":1000}}`))
+	})
+
+	cases := []struct {
+		name    string
+		texts   []string
+		marked  int // contents up to the marked message
+		creates int32
+		cached  int32
+		live    int
+	}{
+		{"marked prefix", []string{"a", "b", "c"}, 2, 1, 2, 1},
+		// the last turn is marked, it is still sent so the request is not empty
+		{"marked last message", []string{"d", "e"}, 2, 1, 1, 1},
+		// nothing is left to cache without instructions and tools
+		{"single marked message", []string{"f"}, 1, 0, 0, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			creates.Store(0)
+			config := &genai.GenerateContentConfig{}
+			live, writeTokens := newCachedContents().use(context.Background(), client, "m", testContents(c.texts...), c.marked, config, &types.CacheControl{})
+
+			if len(live) != c.live {
+				t.Errorf("%d contents sent live, want %d", len(live), c.live)
+			}
+			if creates.Load() != c.creates {
+				t.Fatalf("%d creates, want %d", creates.Load(), c.creates)
+			}
+			if c.creates == 0 {
+				return
+			}
+			if cached.Load() != c.cached {
+				t.Errorf("%d contents cached, want %d", cached.Load(), c.cached)
+			}
+			if writeTokens != 1000 || config.CachedContent != "cachedContents/1" {
+				t.Errorf("write tokens %d cached content %q", writeTokens, config.CachedContent)
+			}
+		})
+	}
+}
+
+func TestCachedContentsSharedCreate(t *testing.T) {
+	var creates atomic.Int32
+	client := newTestCacheClient(t, func(w http.ResponseWriter, r *http.Request) {
+		creates.Add(1)
+		w.Write([]byte(`{"name":"cachedContents/1","usageMetadata":{"totalTokenCount":1000}}`))
+	})
+
+	c := newCachedContents()
+	var writeTokens atomic.Int64
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, tokens := c.use(context.Background(), client, "m", testContents("a", "b"), 1, &genai.GenerateContentConfig{}, &types.CacheControl{})
+			writeTokens.Add(tokens)
+		}()
+	}
+	wg.Wait()
+
+	// concurrent creations may be merged or served from the entry, the write is billed once
+	if creates.Load() != 1 || writeTokens.Load() != 1000 {
+		t.Errorf("%d creates billed %d write tokens, want 1 and 1000", creates.Load(), writeTokens.Load())
+	}
+}
+
+func TestCachedContentsFailure(t *testing.T) {
+	var creates atomic.Int32
+	client := newTestCacheClient(t, func(w http.ResponseWriter, r *http.Request) {
+		creates.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":400,"message":"too small","status":"INVALID_ARGUMENT"}}`))
+	})
+
+	c := newCachedContents()
+	for range 3 {
+		config := &genai.GenerateContentConfig{}
+		live, writeTokens := c.use(context.Background(), client, "m", testContents("a", "b"), 1, config, &types.CacheControl{})
+		if len(live) != 2 || writeTokens != 0 || config.CachedContent != "" {
+			t.Fatalf("failed cache: %d contents live, write tokens %d, cached content %q", len(live), writeTokens, config.CachedContent)
+		}
+	}
+	if creates.Load() != 1 {
+		t.Errorf("%d creates, want 1 until the failure backoff ends", creates.Load())
+	}
+}
@@ -0,0 +1,37 @@
+package google
+
+import (
+	"context"
+
+	"github.com/xucx/llmapi/internal/tokenizer"
+	"github.com/xucx/llmapi/types"
+)
+
+// CountTokens counts the contents with gemini, the gemini api does not count a system
+// instruction or tools, so those are estimated locally
+func (p *GoogleProvider) CountTokens(ctx context.Context, messages []*types.Message, options ...types.ChatOption) (*types.TokenCount, error) {
+	opts := types.GetChatOptions(&types.ChatOptions{
+		Model: DefaultChatModel,
+	}, options...)
+
+	contents, _, err := toChatContents(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	count := &types.TokenCount{Model: opts.Model}
+	if len(contents) > 0 {
+		rsp, err := p.client.Models.CountTokens(ctx, opts.Model, contents, nil)
+		if err != nil {
+			return nil, err
+		}
+		count.InputTokens = int64(rsp.TotalTokens)
+	}
+
+	if opts.Instructions != "" {
+		count.InputTokens += tokenizer.Estimate(opts.Instructions)
+	}
+	count.InputTokens += tokenizer.EstimateTools(opts.Tools)
+
+	return count, nil
+}
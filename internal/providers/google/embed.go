@@ -0,0 +1,54 @@
+package google
+
+import (
+	"context"
+
+	"github.com/xucx/llmapi/types"
+
+	"google.golang.org/genai"
+)
+
+const (
+	DefaultEmbedModel = "gemini-embedding-001"
+	// inputs of one batch embed request
+	MaxEmbedBatch = 100
+)
+
+func (p *GoogleProvider) Embed(ctx context.Context, inputs []string, options ...types.EmbedOption) (*types.Embeddings, error) {
+	opts := types.GetEmbedOptions(&types.EmbedOptions{
+		Model: DefaultEmbedModel,
+	}, options...)
+
+	config := &genai.EmbedContentConfig{}
+	if opts.Dimensions != nil {
+		dimensions := int32(*opts.Dimensions)
+		config.OutputDimensionality = &dimensions
+	}
+
+	embeddings := &types.Embeddings{Model: opts.Model}
+	for start := 0; start < len(inputs); start += MaxEmbedBatch {
+		contents := []*genai.Content{}
+		for _, input := range inputs[start:min(start+MaxEmbedBatch, len(inputs))] {
+			contents = append(contents, genai.NewContentFromText(input, genai.RoleUser))
+		}
+
+		rsp, err := p.client.Models.EmbedContent(ctx, opts.Model, contents, config)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, embedding := range rsp.Embeddings {
+			// only vertex counts the tokens
+			if embedding.Statistics != nil {
+				embeddings.Usage.PromptTokens += int64(embedding.Statistics.TokenCount)
+			}
+			embeddings.Embeddings = append(embeddings.Embeddings, &types.Embedding{
+				Index:  start + i,
+				Vector: embedding.Values,
+			})
+		}
+	}
+	embeddings.Usage.TotalTokens = embeddings.Usage.PromptTokens
+
+	return embeddings, nil
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/xucx/llmapi/internal/providers/provider"
 	"github.com/xucx/llmapi/internal/utils"
 	"github.com/xucx/llmapi/log"
 	"github.com/xucx/llmapi/types"
@@ -30,6 +31,11 @@ func (p *GoogleProvider) Generate(ctx context.Context, messages []*types.Message
 		return nil, err
 	}
 
+	var cacheWriteTokens int64
+	if cacheControl := toCacheControl(contentsOpts, opts.Tools); cacheControl != nil {
+		contents, cacheWriteTokens = p.caches.use(ctx, p.client, opts.Model, contents, contentsOpts.cachedContents, config, cacheControl)
+	}
+
 	var (
 		rsp *genai.GenerateContentResponse
 	)
@@ -72,16 +78,23 @@ func (p *GoogleProvider) Generate(ctx context.Context, messages []*types.Message
 		}
 	}
 
-	return fromChatCompletion(rsp, false)
+	completion, err := fromChatCompletion(rsp, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// the cached tokens read by the call creating the cache were written by it
+	if cacheWriteTokens > 0 {
+		completion.Usage.CacheWriteTokens = cacheWriteTokens
+		completion.Usage.CachedTokens = max(completion.Usage.CachedTokens-cacheWriteTokens, 0)
+	}
+	return completion, nil
 }
 
 func toChatConfig(opts *types.ChatOptions, contentOpts *contentsOpt) (*genai.GenerateContentConfig, error) {
 	config := &genai.GenerateContentConfig{
 		ResponseModalities: []string{"TEXT"},
-		ThinkingConfig: &genai.ThinkingConfig{
-			IncludeThoughts: true,
-			ThinkingBudget:  utils.Ptr[int32](8192),
-		},
+		ThinkingConfig:     toThinkingConfig(opts.Reasoning),
 	}
 
 	if opts.Instructions != "" {
@@ -97,18 +110,53 @@ func toChatConfig(opts *types.ChatOptions, contentOpts *contentsOpt) (*genai.Gen
 		config.Temperature = opts.Temperature
 	}
 
-	for i, tool := range opts.Tools {
-		if tool.Type != "function" {
-			return nil, fmt.Errorf("tool [%d]: unsupported type %q, want 'function'", i, tool.Type)
+	if opts.TopP != nil {
+		config.TopP = opts.TopP
+	}
+
+	if opts.TopK != nil {
+		config.TopK = utils.Ptr(float32(*opts.TopK))
+	}
+
+	if opts.MaxTokens != nil {
+		config.MaxOutputTokens = int32(*opts.MaxTokens)
+	}
+
+	if len(opts.StopSequences) > 0 {
+		config.StopSequences = opts.StopSequences
+	}
+
+	if opts.ResponseFormat != nil {
+		switch opts.ResponseFormat.Type {
+		case types.ResponseFormatText, "":
+		case types.ResponseFormatJSONObject:
+			config.ResponseMIMEType = "application/json"
+		case types.ResponseFormatJSONSchema:
+			// json schema like tool parameters, instead of the openapi subset of ResponseSchema
+			config.ResponseMIMEType = "application/json"
+			config.ResponseJsonSchema = opts.ResponseFormat.Schema
+		default:
+			return nil, fmt.Errorf("google not support response format %s", opts.ResponseFormat.Type)
 		}
+	}
 
-		config.Tools = append(config.Tools, &genai.Tool{FunctionDeclarations: []*genai.FunctionDeclaration{
-			{
-				Name:                 tool.Function.Name,
-				Description:          tool.Function.Description,
-				ParametersJsonSchema: tool.Function.Parameters,
-			},
-		}})
+	tools, err := toTools(opts.Tools)
+	if err != nil {
+		return nil, err
+	}
+	config.Tools = tools
+
+	if opts.ToolChoice != nil && len(tools) > 0 {
+		toolConfig, err := toToolConfig(opts.ToolChoice)
+		if err != nil {
+			return nil, err
+		}
+		config.ToolConfig = toolConfig
+	}
+
+	// gemini decides itself how many functions it calls
+	if opts.ParallelToolCalls != nil && !*opts.ParallelToolCalls && len(tools) > 0 {
+		return nil, provider.CapabilityError(ProviderName, "disabling parallel tool calls")
 	}
 
 	if contentOpts.hasAudio {
@@ -131,8 +179,73 @@ func toChatConfig(opts *types.ChatOptions, contentOpts *contentsOpt) (*genai.Gen
 	return config, nil
 }
 
+func toTools(tools []*types.Tool) ([]*genai.Tool, error) {
+	toTools := []*genai.Tool{}
+	for i, tool := range tools {
+		if tool.Type != "function" {
+			return nil, fmt.Errorf("tool [%d]: unsupported type %q, want 'function'", i, tool.Type)
+		}
+
+		toTools = append(toTools, &genai.Tool{FunctionDeclarations: []*genai.FunctionDeclaration{
+			{
+				Name:                 tool.Function.Name,
+				Description:          tool.Function.Description,
+				ParametersJsonSchema: tool.Function.Parameters,
+			},
+		}})
+	}
+	return toTools, nil
+}
+
+func toThinkingConfig(reasoning *types.Reasoning) *genai.ThinkingConfig {
+	if reasoning == nil {
+		return &genai.ThinkingConfig{
+			IncludeThoughts: true,
+			ThinkingBudget:  utils.Ptr[int32](DefaultThinkingBudget),
+		}
+	}
+
+	// a budget of 0 is rejected by the pro models which can not turn thinking off,
+	// without a config they think as they do by default and the thoughts are left out
+	if !reasoning.Enabled {
+		return nil
+	}
+
+	// -1 lets the model decide
+	budget := int32(-1)
+	if b := reasoning.Budget(); b > 0 {
+		budget = int32(b)
+	}
+	return &genai.ThinkingConfig{
+		IncludeThoughts: reasoning.IncludeThoughts,
+		ThinkingBudget:  &budget,
+	}
+}
+
+func toToolConfig(choice *types.ToolChoice) (*genai.ToolConfig, error) {
+	config := &genai.FunctionCallingConfig{}
+	switch choice.Type {
+	case types.ToolChoiceAuto:
+		config.Mode = genai.FunctionCallingConfigModeAuto
+	case types.ToolChoiceNone:
+		config.Mode = genai.FunctionCallingConfigModeNone
+	case types.ToolChoiceRequired:
+		config.Mode = genai.FunctionCallingConfigModeAny
+	case types.ToolChoiceFunction:
+		config.Mode = genai.FunctionCallingConfigModeAny
+		config.AllowedFunctionNames = []string{choice.Name}
+	default:
+		return nil, fmt.Errorf("google not support tool choice %s", choice.Type)
+	}
+	return &genai.ToolConfig{FunctionCallingConfig: config}, nil
+}
+
 type contentsOpt struct {
 	hasAudio bool
+
+	// contents up to the last part with a cache control, and its ttl
+	cachedContents int
+	cacheControl   *types.CacheControl
 }
 
 func toChatContents(messages []*types.Message) ([]*genai.Content, *contentsOpt, error) {
@@ -173,19 +286,34 @@ func toChatContents(messages []*types.Message) ([]*genai.Content, *contentsOpt,
 			case part.Refusal != nil:
 				// not support
 			case part.ImageURL != nil:
+				// data urls are sent inline, other urls as file uris
+				mimeType, data, ok := types.ParseDataURL(part.ImageURL.URL)
+				if !ok {
+					toPart = &genai.Part{
+						FileData: &genai.FileData{
+							FileURI: part.ImageURL.URL,
+						},
+					}
+					break
+				}
+				b, err := provider.DecodeMedia(data, MaxInlineSize)
+				if err != nil {
+					return nil, nil, fmt.Errorf("image: %w", err)
+				}
 				toPart = &genai.Part{
-					FileData: &genai.FileData{
-						FileURI: part.ImageURL.URL,
+					InlineData: &genai.Blob{
+						MIMEType: provider.SniffMIMEType(mimeType, b),
+						Data:     b,
 					},
 				}
 			case part.File != nil:
-				data, err := base64.RawStdEncoding.DecodeString(part.File.Data)
+				data, err := provider.DecodeMedia(part.File.Data, MaxInlineSize)
 				if err != nil {
-					return nil, nil, err
+					return nil, nil, fmt.Errorf("file %s: %w", part.File.Name, err)
 				}
 				toPart = &genai.Part{
 					InlineData: &genai.Blob{
-						MIMEType:    part.File.MIMEType,
+						MIMEType:    provider.SniffMIMEType(part.File.MIMEType, data),
 						Data:        data,
 						DisplayName: part.File.Name,
 					},
@@ -245,6 +373,13 @@ func toChatContents(messages []*types.Message) ([]*genai.Content, *contentsOpt,
 
 		if len(content.Parts) > 0 {
 			contents = append(contents, content)
+
+			for _, part := range msg.Parts {
+				if part.CacheControl != nil {
+					opts.cachedContents = len(contents)
+					opts.cacheControl = part.CacheControl
+				}
+			}
 		}
 	}
 
@@ -407,21 +542,41 @@ func fromChatCompletion(rsp *genai.GenerateContentResponse, delta bool) (*types.
 	}
 
 	completion := &types.Completion{
-		Delta:   delta,
-		Model:   rsp.ModelVersion,
-		Message: message,
-		Usage:   types.CompletionUsage{},
+		Delta:        delta,
+		Model:        rsp.ModelVersion,
+		Message:      message,
+		Usage:        types.CompletionUsage{},
+		FinishReason: fromFinishReason(candidate.FinishReason, len(message.ToolCalls()) > 0),
 	}
 
 	if rsp.UsageMetadata != nil {
 		completion.Usage.CompletionTokens = int64(rsp.UsageMetadata.CandidatesTokenCount)
 		completion.Usage.PromptTokens = int64(rsp.UsageMetadata.PromptTokenCount)
 		completion.Usage.TotalTokens = int64(rsp.UsageMetadata.TotalTokenCount)
+		completion.Usage.CachedTokens = int64(rsp.UsageMetadata.CachedContentTokenCount)
 	}
 
 	return completion, nil
 }
 
+// gemini finishes with STOP when it calls tools
+func fromFinishReason(reason genai.FinishReason, hasToolCalls bool) types.FinishReason {
+	switch reason {
+	case "", genai.FinishReasonUnspecified:
+		return ""
+	case genai.FinishReasonMaxTokens:
+		return types.FinishReasonLength
+	case genai.FinishReasonSafety, genai.FinishReasonRecitation, genai.FinishReasonBlocklist,
+		genai.FinishReasonProhibitedContent, genai.FinishReasonSPII, genai.FinishReasonImageSafety:
+		return types.FinishReasonContentFilter
+	default:
+		if hasToolCalls {
+			return types.FinishReasonToolCalls
+		}
+		return types.FinishReasonStop
+	}
+}
+
 func ToVoice(in types.AudioVoiceType) (string, error) {
 	switch in {
 	case types.AudioVoiceWomen:
@@ -0,0 +1,31 @@
+package google
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xucx/llmapi/internal/utils"
+	"github.com/xucx/llmapi/types"
+
+	"google.golang.org/genai"
+)
+
+func TestToThinkingConfig(t *testing.T) {
+	cases := []struct {
+		name      string
+		reasoning *types.Reasoning
+		config    *genai.ThinkingConfig
+	}{
+		{"default", nil, &genai.ThinkingConfig{IncludeThoughts: true, ThinkingBudget: utils.Ptr[int32](DefaultThinkingBudget)}},
+		// gemini 2.5 pro rejects a budget of 0
+		{"disabled", &types.Reasoning{}, nil},
+		{"budget", &types.Reasoning{Enabled: true, BudgetTokens: 2048, IncludeThoughts: true}, &genai.ThinkingConfig{IncludeThoughts: true, ThinkingBudget: utils.Ptr[int32](2048)}},
+		{"dynamic", &types.Reasoning{Enabled: true}, &genai.ThinkingConfig{ThinkingBudget: utils.Ptr[int32](-1)}},
+	}
+
+	for _, c := range cases {
+		if got := toThinkingConfig(c.reasoning); !reflect.DeepEqual(got, c.config) {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.config)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package google
 
 import (
 	"context"
+	"errors"
 
 	"github.com/xucx/llmapi/internal/providers/provider"
 
@@ -11,6 +12,12 @@ import (
 const (
 	ProviderName     = "google"
 	DefaultChatModel = "gemini-2.5-flash"
+
+	// thinking of requests without reasoning options
+	DefaultThinkingBudget = 8192
+
+	// decoded size limit of inline images and files, the request limit of the api
+	MaxInlineSize = 20 << 20
 )
 
 const (
@@ -21,12 +28,16 @@ const (
 )
 
 var (
-	_ provider.Provider = (*GoogleProvider)(nil)
+	_ provider.Provider        = (*GoogleProvider)(nil)
+	_ provider.ErrorClassifier = (*GoogleProvider)(nil)
+	_ provider.Embedder        = (*GoogleProvider)(nil)
+	_ provider.TokenCounter    = (*GoogleProvider)(nil)
 )
 
 type GoogleProvider struct {
 	provider.ProviderNop
 	client *genai.Client
+	caches *cachedContents
 }
 
 // use gemini
@@ -34,7 +45,8 @@ func NewGoogleProvider(opts ...provider.ProviderOption) (provider.Provider, erro
 	options := provider.GetProviderOptions(opts...)
 
 	config := &genai.ClientConfig{
-		APIKey: options.Sk,
+		APIKey:     options.Sk,
+		HTTPClient: provider.HttpClient,
 	}
 
 	client, err := genai.NewClient(context.Background(), config)
@@ -42,5 +54,13 @@ func NewGoogleProvider(opts ...provider.ProviderOption) (provider.Provider, erro
 		return nil, err
 	}
 
-	return &GoogleProvider{client: client}, nil
+	return &GoogleProvider{client: client, caches: newCachedContents()}, nil
+}
+
+func (p *GoogleProvider) ClassifyError(err error) provider.ErrorClass {
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return provider.ClassifyStatus(apiErr.Code, apiErr.Message)
+	}
+	return provider.ErrorClassOther
 }
@@ -0,0 +1,346 @@
+package google
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/xucx/llmapi/types"
+
+	"github.com/google/uuid"
+	"go.uber.org/multierr"
+	"google.golang.org/genai"
+)
+
+const (
+	DefaultRealTimeModel = "gemini-live-2.5-flash-preview"
+
+	// pcm16 in the realtime api is 16-bit little-endian mono at 24kHz, same as openai
+	realtimeAudioMIMEType = "audio/pcm;rate=24000"
+)
+
+func (p *GoogleProvider) Realtime(ctx context.Context, messages []*types.Message, options ...types.RealTimeOption) (types.RealTimeSession, error) {
+	option := &types.RealTimeOptions{
+		Model: DefaultRealTimeModel,
+	}
+	for _, opt := range options {
+		option = opt(option)
+	}
+
+	config, err := toLiveConnectConfig(option)
+	if err != nil {
+		return nil, err
+	}
+
+	liveSession, err := p.client.Live.Connect(ctx, option.Model, config)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &ClientSession{session: liveSession}
+
+	//send messages
+	for _, msg := range messages {
+		if err := session.Send(ctx, msg); err != nil {
+			session.Close()
+			return nil, err
+		}
+	}
+
+	return session, nil
+}
+
+func toLiveConnectConfig(opts *types.RealTimeOptions) (*genai.LiveConnectConfig, error) {
+	config := &genai.LiveConnectConfig{
+		ResponseModalities:       []genai.Modality{genai.ModalityAudio},
+		InputAudioTranscription:  &genai.AudioTranscriptionConfig{},
+		OutputAudioTranscription: &genai.AudioTranscriptionConfig{},
+	}
+
+	if opts.Instructions != "" {
+		config.SystemInstruction = &genai.Content{
+			Parts: []*genai.Part{
+				{Text: opts.Instructions},
+			},
+		}
+	}
+
+	if opts.AudioVoice != nil {
+		voice, err := ToVoice(*opts.AudioVoice)
+		if err != nil {
+			return nil, err
+		}
+		config.SpeechConfig = &genai.SpeechConfig{
+			VoiceConfig: &genai.VoiceConfig{
+				PrebuiltVoiceConfig: &genai.PrebuiltVoiceConfig{
+					VoiceName: voice,
+				},
+			},
+		}
+	}
+
+	tools, err := toTools(opts.Tools)
+	if err != nil {
+		return nil, err
+	}
+	config.Tools = tools
+
+	return config, nil
+}
+
+type ClientSession struct {
+	session *genai.Session
+
+	// output text and transcript of the current turn, reported when the turn completes
+	text       strings.Builder
+	transcript strings.Builder
+
+	// completions of a server message which came with an input transcription
+	pending []*types.Completion
+}
+
+func (r *ClientSession) Send(ctx context.Context, msg *types.Message) error {
+
+	var (
+		allErr       error
+		content      = &genai.Content{}
+		turnComplete = false
+		toolResults  = []*genai.FunctionResponse{}
+		audioDeltas  = []*genai.Blob{}
+	)
+
+	switch msg.Role {
+	case types.MessageRoleUser:
+		content.Role = RoleUser
+		for _, part := range msg.Parts {
+			switch {
+			case part.Text != nil:
+				content.Parts = append(content.Parts, &genai.Part{Text: part.Text.Text})
+			case part.Audio != nil:
+				if part.Audio.Format != "pcm16" {
+					return fmt.Errorf("realtime audio format %s not support", part.Audio.Format)
+				}
+
+				data, err := base64.StdEncoding.DecodeString(part.Audio.Data)
+				if err != nil {
+					return err
+				}
+				blob := &genai.Blob{MIMEType: realtimeAudioMIMEType, Data: data}
+
+				if !part.Audio.Delta {
+					content.Parts = append(content.Parts, &genai.Part{InlineData: blob})
+				} else {
+					audioDeltas = append(audioDeltas, blob)
+				}
+			case part.RealtimeResponse != nil:
+				turnComplete = true
+			default:
+				return fmt.Errorf("unsupport realtime user message")
+			}
+		}
+	case types.MessageRoleAssistant:
+		content.Role = RoleModel
+		for _, part := range msg.Parts {
+			switch {
+			case part.Text != nil:
+				content.Parts = append(content.Parts, &genai.Part{Text: part.Text.Text})
+			case part.ToolCall != nil:
+				args := map[string]any{}
+				if err := json.Unmarshal([]byte(part.ToolCall.Function.Arguments), &args); err != nil {
+					return err
+				}
+				content.Parts = append(content.Parts, &genai.Part{FunctionCall: &genai.FunctionCall{
+					ID:   part.ToolCall.ID,
+					Name: part.ToolCall.Function.Name,
+					Args: args,
+				}})
+			default:
+				return fmt.Errorf("unsupport realtime assistant message")
+			}
+		}
+	case types.MessageRoleTool:
+		for _, part := range msg.Parts {
+			switch {
+			case part.ToolResult != nil:
+				toolResults = append(toolResults, &genai.FunctionResponse{
+					ID:       part.ToolResult.ID,
+					Name:     part.ToolResult.Name,
+					Response: map[string]any{"output": part.ToolResult.Result},
+				})
+			default:
+				return fmt.Errorf("unsupport realtime tool message")
+			}
+		}
+	case types.MessageRoleSystem:
+		return fmt.Errorf("realtime system message not support, use instructions")
+	default:
+		return fmt.Errorf("realtime unsupport role %s", msg.Role)
+	}
+
+	// a realtime response part asks the model to reply, as the turn is complete
+	if len(content.Parts) > 0 || turnComplete {
+		input := genai.LiveClientContentInput{TurnComplete: genai.Ptr(turnComplete)}
+		if len(content.Parts) > 0 {
+			input.Turns = []*genai.Content{content}
+		}
+		if err := r.session.SendClientContent(input); err != nil {
+			allErr = multierr.Append(allErr, err)
+		}
+	}
+
+	for _, blob := range audioDeltas {
+		if err := r.session.SendRealtimeInput(genai.LiveRealtimeInput{Audio: blob}); err != nil {
+			allErr = multierr.Append(allErr, err)
+		}
+	}
+
+	if len(toolResults) > 0 {
+		if err := r.session.SendToolResponse(genai.LiveToolResponseInput{FunctionResponses: toolResults}); err != nil {
+			allErr = multierr.Append(allErr, err)
+		}
+	}
+
+	return allErr
+}
+
+func (r *ClientSession) Recv(ctx context.Context) (*types.Completion, error) {
+	if len(r.pending) > 0 {
+		completion := r.pending[0]
+		r.pending = r.pending[1:]
+		return completion, nil
+	}
+
+	// Receive blocks on the websocket, closing the session is the only way to interrupt it
+	stop := context.AfterFunc(ctx, func() {
+		r.session.Close()
+	})
+	msg, err := r.session.Receive()
+	stop()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	completion := &types.Completion{
+		Message: &types.Message{
+			Role: types.MessageRoleAssistant,
+		},
+	}
+
+	if msg.UsageMetadata != nil {
+		completion.Usage.PromptTokens = int64(msg.UsageMetadata.PromptTokenCount)
+		completion.Usage.CompletionTokens = int64(msg.UsageMetadata.ResponseTokenCount)
+		completion.Usage.TotalTokens = int64(msg.UsageMetadata.TotalTokenCount)
+		completion.Usage.CachedTokens = int64(msg.UsageMetadata.CachedContentTokenCount)
+	}
+
+	var input *types.Completion
+
+	switch {
+	case msg.ServerContent != nil:
+		content := msg.ServerContent
+
+		// what the user said, as a user message before the output of the same server message
+		if content.InputTranscription != nil && content.InputTranscription.Text != "" {
+			input = &types.Completion{
+				Delta: true,
+				Message: &types.Message{
+					Role: types.MessageRoleUser,
+					Parts: []*types.MessagePart{{Audio: &types.MessageAudio{
+						Transcript: content.InputTranscription.Text,
+						Delta:      true,
+					}}},
+				},
+			}
+		}
+
+		if content.ModelTurn != nil {
+			for _, part := range content.ModelTurn.Parts {
+				switch {
+				case part.InlineData != nil && strings.HasPrefix(part.InlineData.MIMEType, "audio/"):
+					completion.Delta = true
+					completion.Message.Parts = append(completion.Message.Parts, &types.MessagePart{Audio: &types.MessageAudio{
+						Data:   base64.StdEncoding.EncodeToString(part.InlineData.Data),
+						Format: "pcm16",
+						Delta:  true,
+					}})
+				case part.Text != "" && !part.Thought:
+					r.text.WriteString(part.Text)
+					completion.Delta = true
+					completion.Message.Parts = append(completion.Message.Parts, &types.MessagePart{Text: &types.MessageText{
+						Text:  part.Text,
+						Delta: true,
+					}})
+				}
+			}
+		}
+
+		if content.OutputTranscription != nil && content.OutputTranscription.Text != "" {
+			r.transcript.WriteString(content.OutputTranscription.Text)
+			completion.Delta = true
+			completion.Message.Parts = append(completion.Message.Parts, &types.MessagePart{Audio: &types.MessageAudio{
+				Transcript: content.OutputTranscription.Text,
+				Delta:      true,
+			}})
+		}
+
+		// the full turn is reported like openai response.done, a turn cut off by the user
+		// speaking ends with what was generated until then
+		if content.TurnComplete || content.Interrupted {
+			completion.Delta = false
+			completion.FinishReason = types.FinishReasonStop
+			if content.Interrupted {
+				completion.FinishReason = types.FinishReasonInterrupted
+			}
+			completion.Message.Parts = []*types.MessagePart{}
+			if r.text.Len() > 0 {
+				completion.Message.Parts = append(completion.Message.Parts, &types.MessagePart{Text: &types.MessageText{
+					Text: r.text.String(),
+				}})
+			}
+			if r.transcript.Len() > 0 {
+				completion.Message.Parts = append(completion.Message.Parts, &types.MessagePart{Audio: &types.MessageAudio{
+					Transcript: r.transcript.String(),
+				}})
+			}
+			r.text.Reset()
+			r.transcript.Reset()
+		}
+	case msg.ToolCall != nil:
+		completion.FinishReason = types.FinishReasonToolCalls
+		for _, call := range msg.ToolCall.FunctionCalls {
+			args, err := json.Marshal(call.Args)
+			if err != nil {
+				return nil, err
+			}
+			id := call.ID
+			if id == "" {
+				id = uuid.NewString()
+			}
+			completion.Message.Parts = append(completion.Message.Parts, &types.MessagePart{ToolCall: &types.MessageToolCall{
+				ID:   id,
+				Type: types.ToolTypeFunction,
+				Function: &types.ToolCallFunction{
+					Name:      call.Name,
+					Arguments: string(args),
+				},
+			}})
+		}
+	default:
+		//
+	}
+
+	if input != nil {
+		r.pending = append(r.pending, completion)
+		return input, nil
+	}
+	return completion, nil
+}
+
+func (r *ClientSession) Close() {
+	r.session.Close()
+}
@@ -0,0 +1,28 @@
+package llmapi
+
+import (
+	"context"
+
+	apiv1 "github.com/xucx/llmapi/api/v1"
+	"github.com/xucx/llmapi/types"
+)
+
+func (p *LLMApiProvider) CountTokens(ctx context.Context, messages []*types.Message, options ...types.ChatOption) (*types.TokenCount, error) {
+	opts := types.GetChatOptions(&types.ChatOptions{}, options...)
+
+	chatParams, err := ChatOptionsToParams(messages, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := p.apiClient.CountTokens(ctx, &apiv1.CountTokensRequest{ChatParams: chatParams})
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.TokenCount{
+		Model:       rsp.Model,
+		InputTokens: rsp.InputTokens,
+		Estimated:   rsp.Estimated,
+	}, nil
+}
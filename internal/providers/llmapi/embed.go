@@ -0,0 +1,61 @@
+package llmapi
+
+import (
+	"context"
+
+	apiv1 "github.com/xucx/llmapi/api/v1"
+	"github.com/xucx/llmapi/types"
+)
+
+func (p *LLMApiProvider) Embed(ctx context.Context, inputs []string, options ...types.EmbedOption) (*types.Embeddings, error) {
+	opts := types.GetEmbedOptions(&types.EmbedOptions{}, options...)
+
+	rsp, err := p.apiClient.Embed(ctx, &apiv1.EmbedRequest{
+		Model:      opts.Model,
+		Inputs:     inputs,
+		Dimensions: opts.Dimensions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ToEmbeddings(rsp), nil
+}
+
+func ToEmbeddings(rsp *apiv1.EmbedResponse) *types.Embeddings {
+	embeddings := &types.Embeddings{Model: rsp.Model}
+	if rsp.Usage != nil {
+		embeddings.Usage = types.CompletionUsage{
+			PromptTokens: rsp.Usage.PromptTokens,
+			TotalTokens:  rsp.Usage.TotalTokens,
+		}
+	}
+
+	for _, e := range rsp.Embeddings {
+		embeddings.Embeddings = append(embeddings.Embeddings, &types.Embedding{
+			Index:  int(e.Index),
+			Vector: e.Vector,
+		})
+	}
+
+	return embeddings
+}
+
+func FromEmbeddings(embeddings *types.Embeddings) *apiv1.EmbedResponse {
+	rsp := &apiv1.EmbedResponse{
+		Model: embeddings.Model,
+		Usage: &apiv1.ChageUsage{
+			PromptTokens: embeddings.Usage.PromptTokens,
+			TotalTokens:  embeddings.Usage.TotalTokens,
+		},
+	}
+
+	for _, e := range embeddings.Embeddings {
+		rsp.Embeddings = append(rsp.Embeddings, &apiv1.Embedding{
+			Index:  int32(e.Index),
+			Vector: e.Vector,
+		})
+	}
+
+	return rsp
+}
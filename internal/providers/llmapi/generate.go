@@ -2,6 +2,7 @@ package llmapi
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -33,14 +34,20 @@ func (p *LLMApiProvider) Generate(ctx context.Context, messages []*types.Message
 			return nil, err
 		}
 
-		acc := accChatCompletion{
-			Completion: &v1.ChatCompletion{},
-		}
+		acc := newAccChatCompletion()
 		for {
 			rsp, err := stream.Recv()
 			if err != nil {
 				if errors.Is(err, io.EOF) {
-					return nil, errors.New("not finished")
+					// the remote closed before the final completion, return what we have
+					log.Warnw("proxy stream closed before completion finished")
+					completion, err := ToChatCompletion(acc.Completion)
+					if err != nil {
+						return nil, err
+					}
+					completion.Delta = false
+					completion.FinishReason = types.FinishReasonTruncated
+					return completion, nil
 				}
 				return nil, err
 			}
@@ -79,11 +86,24 @@ func (p *LLMApiProvider) Generate(ctx context.Context, messages []*types.Message
 	}
 }
 
-// Notice: we only merge reasoning and text now
+// accChatCompletion merges stream deltas into one completion
 type accChatCompletion struct {
 	Completion *v1.ChatCompletion
 	reasoning  string
+	signature  string
 	text       string
+	refusal    string
+	audio      *v1.ChatContentAudio
+	audioData  []byte
+	toolCalls  []*v1.ChatContentToolCall
+}
+
+func newAccChatCompletion() *accChatCompletion {
+	return &accChatCompletion{
+		Completion: &v1.ChatCompletion{
+			Message: &v1.ChatMessage{Role: "assistant"},
+		},
+	}
 }
 
 func (acc *accChatCompletion) add(c *v1.ChatCompletion) {
@@ -92,37 +112,128 @@ func (acc *accChatCompletion) add(c *v1.ChatCompletion) {
 	}
 
 	acc.Completion.Delta = true
-	acc.Completion.Model = c.Model
-	acc.Completion.Message = &v1.ChatMessage{
-		Role: "assistant",
+	if c.Model != "" {
+		acc.Completion.Model = c.Model
+	}
+	if c.FinishReason != "" {
+		acc.Completion.FinishReason = c.FinishReason
 	}
 
-	if c.Message != nil {
+	// providers report usage once or cumulatively, the latest non-empty one wins
+	if c.Usage != nil && (c.Usage.PromptTokens > 0 || c.Usage.CompletionTokens > 0 || c.Usage.TotalTokens > 0) {
+		acc.Completion.Usage = c.Usage
+	}
 
-		for _, c := range c.Message.Contents {
-			if reasoningContent := c.GetReasoning(); reasoningContent != nil {
-				acc.reasoning += reasoningContent.Text
-			}
+	if c.Message == nil {
+		return
+	}
 
-			if textContent := c.GetText(); textContent != nil {
-				acc.text += textContent.Text
+	if c.Message.Id != "" {
+		acc.Completion.Message.Id = c.Message.Id
+	}
+
+	for _, c := range c.Message.Contents {
+		switch content := c.Content.(type) {
+		case *v1.ChatContent_Reasoning:
+			acc.reasoning += content.Reasoning.Text
+			if content.Reasoning.ThoughtSignature != "" {
+				acc.signature = content.Reasoning.ThoughtSignature
 			}
+		case *v1.ChatContent_Text:
+			acc.text += content.Text.Text
+		case *v1.ChatContent_Refusal:
+			acc.refusal += content.Refusal.Text
+		case *v1.ChatContent_ToolCall:
+			acc.addToolCall(content.ToolCall)
+		case *v1.ChatContent_Audio:
+			acc.addAudio(content.Audio)
 		}
+	}
 
-		acc.Completion.Message.Id = c.Message.Id
-		acc.Completion.Message.Contents = []*v1.ChatContent{}
-		if acc.reasoning != "" {
-			acc.Completion.Message.Contents = append(acc.Completion.Message.Contents, &v1.ChatContent{Content: &v1.ChatContent_Reasoning{Reasoning: &v1.ChatContentReasoning{
-				Text: acc.reasoning,
-			}}})
+	acc.Completion.Message.Contents = []*v1.ChatContent{}
+	if acc.reasoning != "" || acc.signature != "" {
+		acc.Completion.Message.Contents = append(acc.Completion.Message.Contents, &v1.ChatContent{Content: &v1.ChatContent_Reasoning{Reasoning: &v1.ChatContentReasoning{
+			Text:             acc.reasoning,
+			ThoughtSignature: acc.signature,
+		}}})
+	}
+
+	if acc.text != "" {
+		acc.Completion.Message.Contents = append(acc.Completion.Message.Contents, &v1.ChatContent{Content: &v1.ChatContent_Text{Text: &v1.ChatContentText{
+			Text: acc.text,
+		}}})
+	}
+
+	if acc.refusal != "" {
+		acc.Completion.Message.Contents = append(acc.Completion.Message.Contents, &v1.ChatContent{Content: &v1.ChatContent_Refusal{Refusal: &v1.ChatContentRefusal{
+			Text: acc.refusal,
+		}}})
+	}
+
+	if acc.audio != nil {
+		audio := &v1.ChatContentAudio{
+			Format:     acc.audio.Format,
+			Transcript: acc.audio.Transcript,
+			Data:       acc.audio.Data,
+		}
+		if len(acc.audioData) > 0 {
+			audio.Data = base64.StdEncoding.EncodeToString(acc.audioData)
 		}
+		acc.Completion.Message.Contents = append(acc.Completion.Message.Contents, &v1.ChatContent{Content: &v1.ChatContent_Audio{Audio: audio}})
+	}
+
+	for _, toolCall := range acc.toolCalls {
+		acc.Completion.Message.Contents = append(acc.Completion.Message.Contents, &v1.ChatContent{Content: &v1.ChatContent_ToolCall{ToolCall: &v1.ChatContentToolCall{
+			Id:        toolCall.Id,
+			Name:      toolCall.Name,
+			Arguments: toolCall.Arguments,
+			Index:     toolCall.Index,
+		}}})
+	}
+}
 
-		if acc.text != "" {
-			acc.Completion.Message.Contents = append(acc.Completion.Message.Contents, &v1.ChatContent{Content: &v1.ChatContent_Text{Text: &v1.ChatContentText{
-				Text: acc.text,
-			}}})
+// addToolCall merges a tool call delta by id, or by index when the delta has no id
+func (acc *accChatCompletion) addToolCall(delta *v1.ChatContentToolCall) {
+	var call *v1.ChatContentToolCall
+	for i := len(acc.toolCalls) - 1; i >= 0; i-- {
+		if delta.Id != "" && acc.toolCalls[i].Id == delta.Id ||
+			delta.Id == "" && acc.toolCalls[i].Index == delta.Index {
+			call = acc.toolCalls[i]
+			break
 		}
 	}
+
+	if call == nil {
+		call = &v1.ChatContentToolCall{Id: delta.Id, Index: delta.Index}
+		acc.toolCalls = append(acc.toolCalls, call)
+	}
+
+	if call.Name == "" {
+		call.Name = delta.Name
+	}
+	call.Arguments += delta.Arguments
+}
+
+// addAudio joins audio chunks, each chunk is base64 encoded on its own so the raw bytes are joined
+func (acc *accChatCompletion) addAudio(delta *v1.ChatContentAudio) {
+	if acc.audio == nil {
+		acc.audio = &v1.ChatContentAudio{}
+	}
+
+	if acc.audio.Format == "" {
+		acc.audio.Format = delta.Format
+	}
+	acc.audio.Transcript += delta.Transcript
+
+	if delta.Data == "" {
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(delta.Data)
+	if err != nil {
+		log.Warnw("proxy stream audio chunk not base64, drop it", "err", err)
+		return
+	}
+	acc.audioData = append(acc.audioData, data...)
 }
 
 func ToChatCompletion(from *apiv1.ChatCompletion) (*types.Completion, error) {
@@ -136,13 +247,16 @@ func ToChatCompletion(from *apiv1.ChatCompletion) (*types.Completion, error) {
 		usage.PromptTokens = from.Usage.PromptTokens
 		usage.CompletionTokens = from.Usage.CompletionTokens
 		usage.TotalTokens = from.Usage.TotalTokens
+		usage.CachedTokens = from.Usage.CachedTokens
+		usage.CacheWriteTokens = from.Usage.CacheWriteTokens
 	}
 
 	return &types.Completion{
-		Delta:   from.Delta,
-		Model:   from.Model,
-		Message: message,
-		Usage:   usage,
+		Delta:        from.Delta,
+		Model:        from.Model,
+		Message:      message,
+		Usage:        usage,
+		FinishReason: types.FinishReason(from.FinishReason),
 	}, nil
 }
 
@@ -164,7 +278,10 @@ func FromChatCompletion(completion *types.Completion) (*apiv1.ChatCompletion, er
 			PromptTokens:     completion.Usage.PromptTokens,
 			CompletionTokens: completion.Usage.CompletionTokens,
 			TotalTokens:      completion.Usage.TotalTokens,
+			CachedTokens:     completion.Usage.CachedTokens,
+			CacheWriteTokens: completion.Usage.CacheWriteTokens,
 		},
+		FinishReason: string(completion.FinishReason),
 	}, nil
 }
 
@@ -187,6 +304,7 @@ func ToMessage(from *apiv1.ChatMessage) (*types.Message, error) {
 	}
 
 	for _, p := range from.Contents {
+		parts := len(to.Parts)
 		if text := p.GetText(); text != nil {
 			to.Parts = append(to.Parts, &types.MessagePart{Text: &types.MessageText{
 				Text: text.Text,
@@ -196,10 +314,15 @@ func ToMessage(from *apiv1.ChatMessage) (*types.Message, error) {
 				Text:             reasoning.Text,
 				ThoughtSignature: reasoning.ThoughtSignature,
 			}})
+		} else if refusal := p.GetRefusal(); refusal != nil {
+			to.Parts = append(to.Parts, &types.MessagePart{Refusal: &types.MessageRefusal{
+				Text: refusal.Text,
+			}})
 		} else if toolCall := p.GetToolCall(); toolCall != nil {
 			to.Parts = append(to.Parts, &types.MessagePart{ToolCall: &types.MessageToolCall{
-				ID:   toolCall.Id,
-				Type: "function",
+				ID:    toolCall.Id,
+				Type:  "function",
+				Index: int(toolCall.Index),
 				Function: &types.ToolCallFunction{
 					Name:      toolCall.Name,
 					Arguments: toolCall.Arguments,
@@ -213,14 +336,33 @@ func ToMessage(from *apiv1.ChatMessage) (*types.Message, error) {
 			}})
 		} else if audio := p.GetAudio(); audio != nil {
 			to.Parts = append(to.Parts, &types.MessagePart{Audio: &types.MessageAudio{
+				ID:         audio.Id,
 				Delta:      audio.Delta,
 				Data:       audio.Data,
 				Format:     audio.Format,
 				Transcript: audio.Transcript,
 			}})
+		} else if realtimeResponse := p.GetRealtimeResponse(); realtimeResponse != nil {
+			to.Parts = append(to.Parts, &types.MessagePart{RealtimeResponse: &types.MessageRealtimeResponse{}})
+		} else if imageURL := p.GetImageUrl(); imageURL != nil {
+			to.Parts = append(to.Parts, &types.MessagePart{ImageURL: &types.MessageImageURL{
+				URL:    imageURL.Url,
+				Detail: imageURL.Detail,
+				Format: imageURL.Format,
+			}})
+		} else if file := p.GetFile(); file != nil {
+			to.Parts = append(to.Parts, &types.MessagePart{File: &types.MessageFile{
+				MIMEType: file.MimeType,
+				Name:     file.Name,
+				Data:     file.Data,
+			}})
 		} else {
 			//
 		}
+
+		if p.CacheControl != nil && len(to.Parts) > parts {
+			to.Parts[len(to.Parts)-1].CacheControl = ToCacheControl(p.CacheControl)
+		}
 	}
 
 	return to, nil
@@ -233,6 +375,7 @@ func FromMessage(from *types.Message) (*apiv1.ChatMessage, error) {
 	}
 
 	for _, part := range from.Parts {
+		contents := len(to.Contents)
 		switch {
 		case part.Text != nil:
 			to.Contents = append(to.Contents, &apiv1.ChatContent{Content: &apiv1.ChatContent_Text{
@@ -259,6 +402,7 @@ func FromMessage(from *types.Message) (*apiv1.ChatMessage, error) {
 					Id:        part.ToolCall.ID,
 					Name:      part.ToolCall.Function.Name,
 					Arguments: part.ToolCall.Function.Arguments,
+					Index:     int32(part.ToolCall.Index),
 				},
 			}})
 		case part.ToolResult != nil:
@@ -269,14 +413,62 @@ func FromMessage(from *types.Message) (*apiv1.ChatMessage, error) {
 					Result: part.ToolResult.Result,
 				},
 			}})
+		case part.Audio != nil:
+			to.Contents = append(to.Contents, &apiv1.ChatContent{Content: &apiv1.ChatContent_Audio{
+				Audio: &apiv1.ChatContentAudio{
+					Id:         part.Audio.ID,
+					Delta:      part.Audio.Delta,
+					Data:       part.Audio.Data,
+					Format:     part.Audio.Format,
+					Transcript: part.Audio.Transcript,
+				},
+			}})
+		case part.RealtimeResponse != nil:
+			to.Contents = append(to.Contents, &apiv1.ChatContent{Content: &apiv1.ChatContent_RealtimeResponse{
+				RealtimeResponse: &apiv1.ChatContentRealtimeResponse{},
+			}})
+		case part.ImageURL != nil:
+			to.Contents = append(to.Contents, &apiv1.ChatContent{Content: &apiv1.ChatContent_ImageUrl{
+				ImageUrl: &apiv1.ChatContentImageUrl{
+					Url:    part.ImageURL.URL,
+					Detail: part.ImageURL.Detail,
+					Format: part.ImageURL.Format,
+				},
+			}})
+		case part.File != nil:
+			to.Contents = append(to.Contents, &apiv1.ChatContent{Content: &apiv1.ChatContent_File{
+				File: &apiv1.ChatContentFile{
+					MimeType: part.File.MIMEType,
+					Name:     part.File.Name,
+					Data:     part.File.Data,
+				},
+			}})
 		default:
 			//
 		}
+
+		if part.CacheControl != nil && len(to.Contents) > contents {
+			to.Contents[len(to.Contents)-1].CacheControl = FromCacheControl(part.CacheControl)
+		}
 	}
 
 	return to, nil
 }
 
+func ToCacheControl(from *apiv1.CacheControl) *types.CacheControl {
+	if from == nil {
+		return nil
+	}
+	return &types.CacheControl{TTL: from.Ttl}
+}
+
+func FromCacheControl(from *types.CacheControl) *apiv1.CacheControl {
+	if from == nil {
+		return nil
+	}
+	return &apiv1.CacheControl{Ttl: from.TTL}
+}
+
 func ChatParamsToOptions(req *apiv1.ChatParams) (*types.ChatOptions, error) {
 	tools, err := ToChatTools(req.Tools)
 	if err != nil {
@@ -284,14 +476,66 @@ func ChatParamsToOptions(req *apiv1.ChatParams) (*types.ChatOptions, error) {
 	}
 
 	options := &types.ChatOptions{
-		Model:        req.Model,
-		Instructions: req.Instructions,
-		Tools:        tools,
-		AudioVoice:   types.AudioVoiceType(req.Voice),
+		Model:             req.Model,
+		Instructions:      req.Instructions,
+		Tools:             tools,
+		Temperature:       req.Temperature,
+		TopP:              req.TopP,
+		MaxTokens:         req.MaxTokens,
+		StopSequences:     req.StopSequences,
+		ParallelToolCalls: req.ParallelToolCalls,
+		AudioVoice:        types.AudioVoiceType(req.Voice),
 	}
+
+	if req.TopK != nil {
+		topK := int(*req.TopK)
+		options.TopK = &topK
+	}
+
+	if req.Reasoning != nil {
+		options.Reasoning = &types.Reasoning{
+			Enabled:         req.Reasoning.Enabled,
+			BudgetTokens:    req.Reasoning.BudgetTokens,
+			Effort:          types.ReasoningEffort(req.Reasoning.Effort),
+			IncludeThoughts: req.Reasoning.IncludeThoughts,
+		}
+	}
+
+	if req.ToolChoice != nil {
+		options.ToolChoice = &types.ToolChoice{
+			Type: types.ToolChoiceType(req.ToolChoice.Type),
+			Name: req.ToolChoice.Name,
+		}
+	}
+
+	if req.ResponseFormat != nil {
+		format, err := ToResponseFormat(req.ResponseFormat)
+		if err != nil {
+			return nil, err
+		}
+		options.ResponseFormat = format
+	}
+
 	return options, nil
 }
 
+func ToResponseFormat(format *apiv1.ChatResponseFormat) (*types.ResponseFormat, error) {
+	to := &types.ResponseFormat{
+		Type:        types.ResponseFormatType(format.Type),
+		Name:        format.Name,
+		Description: format.Desc,
+		Strict:      format.Strict,
+	}
+
+	if format.Schema != "" {
+		if err := json.Unmarshal([]byte(format.Schema), &to.Schema); err != nil {
+			return nil, err
+		}
+	}
+
+	return to, nil
+}
+
 func ToChatTools(tools []*apiv1.ChatTool) ([]*types.Tool, error) {
 	all := []*types.Tool{}
 	for _, t := range tools {
@@ -307,6 +551,7 @@ func ToChatTools(tools []*apiv1.ChatTool) ([]*types.Tool, error) {
 				Description: t.Desc,
 				Parameters:  params,
 			},
+			CacheControl: ToCacheControl(t.CacheControl),
 		})
 	}
 
@@ -337,27 +582,109 @@ func ToTurnDetection(turnDetection string) (types.TurnDetectionType, error) {
 
 func ChatOptionsToParams(messages []*types.Message, opts *types.ChatOptions) (*apiv1.ChatParams, error) {
 	chatParams := &apiv1.ChatParams{
-		Model:    opts.Model,
-		Messages: []*apiv1.ChatMessage{},
-		Tools:    []*apiv1.ChatTool{},
+		Model:             opts.Model,
+		Instructions:      opts.Instructions,
+		Voice:             string(opts.AudioVoice),
+		Temperature:       opts.Temperature,
+		TopP:              opts.TopP,
+		MaxTokens:         opts.MaxTokens,
+		StopSequences:     opts.StopSequences,
+		ParallelToolCalls: opts.ParallelToolCalls,
+	}
+
+	if opts.Reasoning != nil {
+		chatParams.Reasoning = &apiv1.ChatReasoning{
+			Enabled:         opts.Reasoning.Enabled,
+			BudgetTokens:    opts.Reasoning.BudgetTokens,
+			Effort:          string(opts.Reasoning.Effort),
+			IncludeThoughts: opts.Reasoning.IncludeThoughts,
+		}
 	}
 
+	if opts.ToolChoice != nil {
+		chatParams.ToolChoice = &apiv1.ChatToolChoice{
+			Type: string(opts.ToolChoice.Type),
+			Name: opts.ToolChoice.Name,
+		}
+	}
+
+	if opts.TopK != nil {
+		topK := int32(*opts.TopK)
+		chatParams.TopK = &topK
+	}
+
+	if opts.ResponseFormat != nil {
+		format, err := fromResponseFormat(opts.ResponseFormat)
+		if err != nil {
+			return nil, err
+		}
+		chatParams.ResponseFormat = format
+	}
+
+	if err := toChatParamsMessagesAndTools(chatParams, messages, opts.Tools); err != nil {
+		return nil, err
+	}
+
+	return chatParams, nil
+}
+
+func fromResponseFormat(format *types.ResponseFormat) (*apiv1.ChatResponseFormat, error) {
+	to := &apiv1.ChatResponseFormat{
+		Type:   string(format.Type),
+		Name:   format.Name,
+		Desc:   format.Description,
+		Strict: format.Strict,
+	}
+
+	if format.Schema != nil {
+		schema, err := json.Marshal(format.Schema)
+		if err != nil {
+			return nil, err
+		}
+		to.Schema = string(schema)
+	}
+
+	return to, nil
+}
+
+func RealTimeOptionsToParams(messages []*types.Message, opts *types.RealTimeOptions) (*apiv1.ChatParams, error) {
+	chatParams := &apiv1.ChatParams{
+		Model:        opts.Model,
+		Instructions: opts.Instructions,
+	}
+
+	if opts.AudioVoice != nil {
+		chatParams.Voice = string(*opts.AudioVoice)
+	}
+
+	if err := toChatParamsMessagesAndTools(chatParams, messages, opts.Tools); err != nil {
+		return nil, err
+	}
+
+	return chatParams, nil
+}
+
+func toChatParamsMessagesAndTools(chatParams *apiv1.ChatParams, messages []*types.Message, tools []*types.Tool) error {
+	chatParams.Messages = []*apiv1.ChatMessage{}
+	chatParams.Tools = []*apiv1.ChatTool{}
+
 	for _, msg := range messages {
 		m, err := FromMessage(msg)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		chatParams.Messages = append(chatParams.Messages, m)
 	}
 
-	for _, tool := range opts.Tools {
+	for _, tool := range tools {
 		params, _ := json.Marshal(tool.Function.Parameters)
 		chatParams.Tools = append(chatParams.Tools, &apiv1.ChatTool{
-			Name:   tool.Function.Name,
-			Desc:   tool.Function.Description,
-			Params: string(params),
+			Name:         tool.Function.Name,
+			Desc:         tool.Function.Description,
+			Params:       string(params),
+			CacheControl: FromCacheControl(tool.CacheControl),
 		})
 	}
 
-	return chatParams, nil
+	return nil
 }
@@ -0,0 +1,102 @@
+package llmapi
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	v1 "github.com/xucx/llmapi/api/v1"
+	"github.com/xucx/llmapi/types"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	msg := &types.Message{
+		ID:   "msg-1",
+		Role: types.MessageRoleAssistant,
+		Parts: []*types.MessagePart{
+			{Reasoning: &types.MessageReasoning{Text: "thinking", ThoughtSignature: "sig"}},
+			{Text: &types.MessageText{Text: "hello"}, CacheControl: &types.CacheControl{TTL: "1h"}},
+			{Refusal: &types.MessageRefusal{Text: "no"}},
+			{ImageURL: &types.MessageImageURL{URL: "data:image/png;base64,iVBORw0KGgo=", Detail: "low"}},
+			{File: &types.MessageFile{MIMEType: "application/pdf", Name: "a.pdf", Data: "JVBERi0="}},
+			{Audio: &types.MessageAudio{ID: "audio-1", Data: "AAAA", Format: "pcm16", Transcript: "hi"}},
+			{ToolCall: &types.MessageToolCall{ID: "call-1", Type: types.ToolTypeFunction, Index: 1, Function: &types.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}}},
+			{ToolResult: &types.MessageToolResult{ID: "call-1", Name: "get_weather", Result: "sunny"}},
+		},
+	}
+
+	from, err := FromMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := ToMessage(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(to.Parts) != len(msg.Parts) {
+		t.Fatalf("got %d parts, want %d", len(to.Parts), len(msg.Parts))
+	}
+	for i := range msg.Parts {
+		if !reflect.DeepEqual(to.Parts[i], msg.Parts[i]) {
+			t.Errorf("part %d: got %+v, want %+v", i, to.Parts[i], msg.Parts[i])
+		}
+	}
+}
+
+func deltaCompletion(contents ...*v1.ChatContent) *v1.ChatCompletion {
+	return &v1.ChatCompletion{Delta: true, Message: &v1.ChatMessage{Contents: contents}}
+}
+
+func TestAccChatCompletion(t *testing.T) {
+	acc := newAccChatCompletion()
+	chunks := []*v1.ChatCompletion{
+		deltaCompletion(&v1.ChatContent{Content: &v1.ChatContent_Reasoning{Reasoning: &v1.ChatContentReasoning{Text: "let me "}}}),
+		deltaCompletion(&v1.ChatContent{Content: &v1.ChatContent_Reasoning{Reasoning: &v1.ChatContentReasoning{Text: "think", ThoughtSignature: "sig"}}}),
+		deltaCompletion(&v1.ChatContent{Content: &v1.ChatContent_Text{Text: &v1.ChatContentText{Text: "Hello, "}}}),
+		deltaCompletion(&v1.ChatContent{Content: &v1.ChatContent_Text{Text: &v1.ChatContentText{Text: "world"}}}),
+		deltaCompletion(&v1.ChatContent{Content: &v1.ChatContent_Refusal{Refusal: &v1.ChatContentRefusal{Text: "no"}}}),
+		deltaCompletion(&v1.ChatContent{Content: &v1.ChatContent_Audio{Audio: &v1.ChatContentAudio{Format: "pcm16", Data: base64.StdEncoding.EncodeToString([]byte("ab")), Transcript: "he"}}}),
+		deltaCompletion(&v1.ChatContent{Content: &v1.ChatContent_Audio{Audio: &v1.ChatContentAudio{Data: base64.StdEncoding.EncodeToString([]byte("c")), Transcript: "llo"}}}),
+		// the first delta of a tool call has its id, the next ones only its index
+		deltaCompletion(&v1.ChatContent{Content: &v1.ChatContent_ToolCall{ToolCall: &v1.ChatContentToolCall{Id: "call-1", Name: "get_weather", Index: 0}}}),
+		deltaCompletion(&v1.ChatContent{Content: &v1.ChatContent_ToolCall{ToolCall: &v1.ChatContentToolCall{Index: 0, Arguments: `{"city":`}}}),
+		deltaCompletion(&v1.ChatContent{Content: &v1.ChatContent_ToolCall{ToolCall: &v1.ChatContentToolCall{Id: "call-2", Name: "get_time", Index: 1, Arguments: "{}"}}}),
+		deltaCompletion(&v1.ChatContent{Content: &v1.ChatContent_ToolCall{ToolCall: &v1.ChatContentToolCall{Index: 0, Arguments: `"Paris"}`}}}),
+		{Delta: true, Model: "gpt-4", FinishReason: "tool_calls", Usage: &v1.ChageUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}},
+		// a usage without tokens does not replace the reported one
+		{Delta: true, Usage: &v1.ChageUsage{}},
+	}
+	for _, chunk := range chunks {
+		acc.add(chunk)
+	}
+
+	completion, err := ToChatCompletion(acc.Completion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if completion.Model != "gpt-4" || completion.FinishReason != types.FinishReasonToolCalls {
+		t.Errorf("model %s finish reason %s", completion.Model, completion.FinishReason)
+	}
+	if completion.Usage.TotalTokens != 15 {
+		t.Errorf("total tokens %d, want 15", completion.Usage.TotalTokens)
+	}
+
+	want := []*types.MessagePart{
+		{Reasoning: &types.MessageReasoning{Text: "let me think", ThoughtSignature: "sig"}},
+		{Text: &types.MessageText{Text: "Hello, world"}},
+		{Refusal: &types.MessageRefusal{Text: "no"}},
+		{Audio: &types.MessageAudio{Format: "pcm16", Data: base64.StdEncoding.EncodeToString([]byte("abc")), Transcript: "hello"}},
+		{ToolCall: &types.MessageToolCall{ID: "call-1", Type: types.ToolTypeFunction, Index: 0, Function: &types.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}}},
+		{ToolCall: &types.MessageToolCall{ID: "call-2", Type: types.ToolTypeFunction, Index: 1, Function: &types.ToolCallFunction{Name: "get_time", Arguments: "{}"}}},
+	}
+	if len(completion.Message.Parts) != len(want) {
+		t.Fatalf("got %d parts, want %d", len(completion.Message.Parts), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(completion.Message.Parts[i], want[i]) {
+			t.Errorf("part %d: got %+v, want %+v", i, completion.Message.Parts[i], want[i])
+		}
+	}
+}
@@ -6,8 +6,11 @@ import (
 	v1 "github.com/xucx/llmapi/api/v1"
 	"github.com/xucx/llmapi/internal/providers/provider"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -15,7 +18,10 @@ const (
 )
 
 var (
-	_ provider.Provider = (*LLMApiProvider)(nil)
+	_ provider.Provider        = (*LLMApiProvider)(nil)
+	_ provider.ErrorClassifier = (*LLMApiProvider)(nil)
+	_ provider.Embedder        = (*LLMApiProvider)(nil)
+	_ provider.TokenCounter    = (*LLMApiProvider)(nil)
 )
 
 type LLMApiProvider struct {
@@ -30,6 +36,7 @@ func NewLLMApiProvider(opts ...provider.ProviderOption) (provider.Provider, erro
 		grpc.WithPerRPCCredentials(&tokenAuth{
 			token: options.Sk,
 		}),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
 	}
 
 	if options.Insecure {
@@ -59,3 +66,27 @@ func (t *tokenAuth) GetRequestMetadata(ctx context.Context, uri ...string) (map[
 func (t *tokenAuth) RequireTransportSecurity() bool {
 	return false
 }
+
+func (p *LLMApiProvider) ClassifyError(err error) provider.ErrorClass {
+	st, ok := status.FromError(err)
+	if !ok {
+		return provider.ErrorClassOther
+	}
+
+	switch st.Code() {
+	case codes.ResourceExhausted:
+		return provider.ErrorClassRateLimit
+	case codes.DeadlineExceeded:
+		return provider.ErrorClassTimeout
+	case codes.Unavailable:
+		return provider.ErrorClassNetwork
+	case codes.Internal, codes.Unknown:
+		return provider.ErrorClassServer
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return provider.ErrorClassAuth
+	case codes.InvalidArgument, codes.NotFound:
+		return provider.ErrorClassInvalid
+	default:
+		return provider.ErrorClassOther
+	}
+}
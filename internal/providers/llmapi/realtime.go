@@ -0,0 +1,71 @@
+package llmapi
+
+import (
+	"context"
+
+	v1 "github.com/xucx/llmapi/api/v1"
+	"github.com/xucx/llmapi/types"
+
+	"google.golang.org/grpc"
+)
+
+func (p *LLMApiProvider) Realtime(ctx context.Context, messages []*types.Message, options ...types.RealTimeOption) (types.RealTimeSession, error) {
+	option := &types.RealTimeOptions{}
+	for _, opt := range options {
+		option = opt(option)
+	}
+
+	chatParams, err := RealTimeOptionsToParams(messages, option)
+	if err != nil {
+		return nil, err
+	}
+
+	// the stream lives until the session is closed
+	streamCtx, cancel := context.WithCancel(ctx)
+	stream, err := p.apiClient.ChatRealtime(streamCtx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// the init frame carries the options and the history messages
+	if err := stream.Send(&v1.ChatRealtimeRequest{
+		Init: &v1.ChatRealtimeRequest_Init{ChatParams: chatParams},
+	}); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &ClientSession{stream: stream, cancel: cancel}, nil
+}
+
+type ClientSession struct {
+	stream grpc.BidiStreamingClient[v1.ChatRealtimeRequest, v1.ChatRealtimeResponse]
+	cancel context.CancelFunc
+}
+
+func (r *ClientSession) Send(ctx context.Context, msg *types.Message) error {
+	message, err := FromMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	return r.stream.Send(&v1.ChatRealtimeRequest{Message: message})
+}
+
+func (r *ClientSession) Recv(ctx context.Context) (*types.Completion, error) {
+	// grpc stream has no per call context, cancel the stream like a closed websocket
+	stop := context.AfterFunc(ctx, r.cancel)
+	rsp, err := r.stream.Recv()
+	stop()
+	if err != nil {
+		return nil, err
+	}
+
+	return ToChatCompletion(rsp.ChatCompletion)
+}
+
+func (r *ClientSession) Close() {
+	r.stream.CloseSend()
+	r.cancel()
+}
@@ -0,0 +1,55 @@
+package openai
+
+import (
+	"context"
+
+	"github.com/xucx/llmapi/types"
+
+	"github.com/openai/openai-go/v2"
+)
+
+const (
+	DefaultEmbedModel = string(openai.EmbeddingModelTextEmbedding3Small)
+	// inputs of one embeddings request
+	MaxEmbedBatch = 2048
+)
+
+func (p *OpenaiProvider) Embed(ctx context.Context, inputs []string, options ...types.EmbedOption) (*types.Embeddings, error) {
+	opts := types.GetEmbedOptions(&types.EmbedOptions{
+		Model: DefaultEmbedModel,
+	}, options...)
+
+	embeddings := &types.Embeddings{Model: opts.Model}
+	for start := 0; start < len(inputs); start += MaxEmbedBatch {
+		batch := inputs[start:min(start+MaxEmbedBatch, len(inputs))]
+
+		params := openai.EmbeddingNewParams{
+			Model: openai.EmbeddingModel(opts.Model),
+			Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: batch},
+		}
+		if opts.Dimensions != nil {
+			params.Dimensions = openai.Int(*opts.Dimensions)
+		}
+
+		rsp, err := p.client.Embeddings.New(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+
+		embeddings.Model = rsp.Model
+		embeddings.Usage.PromptTokens += rsp.Usage.PromptTokens
+		embeddings.Usage.TotalTokens += rsp.Usage.TotalTokens
+		for _, data := range rsp.Data {
+			vector := make([]float32, len(data.Embedding))
+			for i, v := range data.Embedding {
+				vector[i] = float32(v)
+			}
+			embeddings.Embeddings = append(embeddings.Embeddings, &types.Embedding{
+				Index:  start + int(data.Index),
+				Vector: vector,
+			})
+		}
+	}
+
+	return embeddings, nil
+}
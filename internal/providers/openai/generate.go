@@ -2,9 +2,11 @@ package openai
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 
+	"github.com/xucx/llmapi/internal/providers/provider"
 	"github.com/xucx/llmapi/types"
 
 	"github.com/openai/openai-go/v2"
@@ -104,10 +106,14 @@ func toMessages(opts *types.ChatOptions, messages []*types.Message) ([]openai.Ch
 						},
 					})
 				case part.ImageURL != nil:
+					url, err := toImageURL(part.ImageURL.URL)
+					if err != nil {
+						return nil, nil, err
+					}
 					parts = append(parts, openai.ChatCompletionContentPartUnionParam{
 						OfImageURL: &openai.ChatCompletionContentPartImageParam{
 							ImageURL: openai.ChatCompletionContentPartImageImageURLParam{
-								URL:    part.ImageURL.URL,
+								URL:    url,
 								Detail: part.ImageURL.Detail,
 							},
 						},
@@ -123,10 +129,14 @@ func toMessages(opts *types.ChatOptions, messages []*types.Message) ([]openai.Ch
 						},
 					})
 				case part.File != nil:
+					fileData, err := toFileData(part.File)
+					if err != nil {
+						return nil, nil, err
+					}
 					parts = append(parts, openai.ChatCompletionContentPartUnionParam{
 						OfFile: &openai.ChatCompletionContentPartFileParam{
 							File: openai.ChatCompletionContentPartFileFileParam{
-								FileData: param.Opt[string]{Value: part.File.Data},
+								FileData: param.Opt[string]{Value: fileData},
 								Filename: param.Opt[string]{Value: part.File.Name},
 							},
 						},
@@ -197,6 +207,29 @@ func toMessages(opts *types.ChatOptions, messages []*types.Message) ([]openai.Ch
 	return openaiMsgs, messageOpts, nil
 }
 
+// toImageURL checks the size of data urls and gives them the type of their bytes, other urls are sent as they are
+func toImageURL(url string) (string, error) {
+	mimeType, data, ok := types.ParseDataURL(url)
+	if !ok {
+		return url, nil
+	}
+
+	b, err := provider.DecodeMedia(data, MaxImageSize)
+	if err != nil {
+		return "", fmt.Errorf("image: %w", err)
+	}
+	return types.DataURL(provider.SniffMIMEType(mimeType, b), base64.StdEncoding.EncodeToString(b)), nil
+}
+
+// toFileData is the data url of a file, the api does not take bare base64
+func toFileData(file *types.MessageFile) (string, error) {
+	b, err := provider.DecodeMedia(file.Data, MaxFileSize)
+	if err != nil {
+		return "", fmt.Errorf("file %s: %w", file.Name, err)
+	}
+	return types.DataURL(provider.SniffMIMEType(file.MIMEType, b), base64.StdEncoding.EncodeToString(b)), nil
+}
+
 func toParams(opts *types.ChatOptions, messages []*types.Message) (*openai.ChatCompletionNewParams, error) {
 	openaiMessages, messageOpts, err := toMessages(opts, messages)
 	if err != nil {
@@ -218,6 +251,32 @@ func toParams(opts *types.ChatOptions, messages []*types.Message) (*openai.ChatC
 		openaiPramas.Temperature = openai.Float(float64(*opts.Temperature))
 	}
 
+	if opts.TopP != nil {
+		openaiPramas.TopP = openai.Float(float64(*opts.TopP))
+	}
+
+	if opts.TopK != nil {
+		return nil, provider.CapabilityError(ProviderName, "top_k")
+	}
+
+	if opts.MaxTokens != nil {
+		openaiPramas.MaxCompletionTokens = openai.Int(*opts.MaxTokens)
+	}
+
+	if len(opts.StopSequences) > 0 {
+		openaiPramas.Stop = openai.ChatCompletionNewParamsStopUnion{
+			OfStringArray: opts.StopSequences,
+		}
+	}
+
+	if opts.ResponseFormat != nil {
+		responseFormat, err := toResponseFormat(opts.ResponseFormat)
+		if err != nil {
+			return nil, err
+		}
+		openaiPramas.ResponseFormat = responseFormat
+	}
+
 	for _, tool := range opts.Tools {
 		if tool.Type != types.ToolTypeFunction || tool.Function == nil {
 			return nil, errors.New("openai only support function tool for now")
@@ -230,6 +289,31 @@ func toParams(opts *types.ChatOptions, messages []*types.Message) (*openai.ChatC
 		}))
 	}
 
+	// chat completions take an effort level only, and return no thoughts
+	if opts.Reasoning != nil {
+		effort := opts.Reasoning.EffortLevel()
+		if !opts.Reasoning.Enabled {
+			effort = types.ReasoningEffortMinimal
+		}
+		if effort != "" {
+			openaiPramas.ReasoningEffort = shared.ReasoningEffort(effort)
+		}
+	}
+
+	// tool_choice and parallel_tool_calls are only accepted with tools
+	if len(opts.Tools) > 0 {
+		if opts.ToolChoice != nil {
+			toolChoice, err := toToolChoice(opts.ToolChoice)
+			if err != nil {
+				return nil, err
+			}
+			openaiPramas.ToolChoice = toolChoice
+		}
+		if opts.ParallelToolCalls != nil {
+			openaiPramas.ParallelToolCalls = openai.Bool(*opts.ParallelToolCalls)
+		}
+	}
+
 	if messageOpts.hasAudio {
 		openaiPramas.Audio = openai.ChatCompletionAudioParam{
 			Format: openai.ChatCompletionAudioParamFormatMP3,
@@ -248,6 +332,42 @@ func toParams(opts *types.ChatOptions, messages []*types.Message) (*openai.ChatC
 	return openaiPramas, nil
 }
 
+func toToolChoice(choice *types.ToolChoice) (openai.ChatCompletionToolChoiceOptionUnionParam, error) {
+	switch choice.Type {
+	case types.ToolChoiceAuto, types.ToolChoiceNone, types.ToolChoiceRequired:
+		return openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: openai.String(string(choice.Type))}, nil
+	case types.ToolChoiceFunction:
+		return openai.ToolChoiceOptionFunctionToolChoice(openai.ChatCompletionNamedToolChoiceFunctionParam{Name: choice.Name}), nil
+	default:
+		return openai.ChatCompletionToolChoiceOptionUnionParam{}, fmt.Errorf("openai not support tool choice %s", choice.Type)
+	}
+}
+
+func toResponseFormat(format *types.ResponseFormat) (openai.ChatCompletionNewParamsResponseFormatUnion, error) {
+	switch format.Type {
+	case types.ResponseFormatText, "":
+		return openai.ChatCompletionNewParamsResponseFormatUnion{OfText: &shared.ResponseFormatTextParam{}}, nil
+	case types.ResponseFormatJSONObject:
+		return openai.ChatCompletionNewParamsResponseFormatUnion{OfJSONObject: &shared.ResponseFormatJSONObjectParam{}}, nil
+	case types.ResponseFormatJSONSchema:
+		schema := shared.ResponseFormatJSONSchemaJSONSchemaParam{
+			Name:   format.Name,
+			Schema: format.Schema,
+			Strict: openai.Bool(format.Strict),
+		}
+		// the name is required by openai
+		if schema.Name == "" {
+			schema.Name = "response"
+		}
+		if format.Description != "" {
+			schema.Description = openai.String(format.Description)
+		}
+		return openai.ChatCompletionNewParamsResponseFormatUnion{OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{JSONSchema: schema}}, nil
+	default:
+		return openai.ChatCompletionNewParamsResponseFormatUnion{}, fmt.Errorf("openai not support response format %s", format.Type)
+	}
+}
+
 func fromComplateChunk(completion *openai.ChatCompletionChunk, thinkChunks []string) (*types.Completion, error) {
 	if len(completion.Choices) < 1 {
 		return nil, fmt.Errorf("completion no choices")
@@ -264,7 +384,7 @@ func fromComplateChunk(completion *openai.ChatCompletionChunk, thinkChunks []str
 	}
 
 	if choice.Delta.Refusal != "" {
-		message.Parts = append(message.Parts, &types.MessagePart{Refusal: &types.MessageRefusal{Text: choice.Delta.Content}})
+		message.Parts = append(message.Parts, &types.MessagePart{Refusal: &types.MessageRefusal{Text: choice.Delta.Refusal}})
 	}
 
 	if choice.Delta.Content != "" {
@@ -275,12 +395,14 @@ func fromComplateChunk(completion *openai.ChatCompletionChunk, thinkChunks []str
 	}
 
 	for _, toolCall := range choice.Delta.ToolCalls {
-		if toolCall.Type != "function" {
+		// only the first delta of a call carries the type, the rest are argument fragments
+		if toolCall.Type != "" && toolCall.Type != "function" {
 			continue
 		}
 		message.Parts = append(message.Parts, &types.MessagePart{ToolCall: &types.MessageToolCall{
-			ID:   toolCall.ID,
-			Type: types.ToolTypeFunction,
+			ID:    toolCall.ID,
+			Type:  types.ToolTypeFunction,
+			Index: int(toolCall.Index),
 			Function: &types.ToolCallFunction{
 				Name:      toolCall.Function.Name,
 				Arguments: toolCall.Function.Arguments,
@@ -296,7 +418,9 @@ func fromComplateChunk(completion *openai.ChatCompletionChunk, thinkChunks []str
 			CompletionTokens: completion.Usage.CompletionTokens,
 			PromptTokens:     completion.Usage.PromptTokens,
 			TotalTokens:      completion.Usage.TotalTokens,
+			CachedTokens:     completion.Usage.PromptTokensDetails.CachedTokens,
 		},
+		FinishReason: fromFinishReason(choice.FinishReason),
 	}, nil
 }
 
@@ -367,6 +491,23 @@ func fromComplate(completion *openai.ChatCompletion, thinks []thinkExtraItem, de
 			CompletionTokens: completion.Usage.CompletionTokens,
 			PromptTokens:     completion.Usage.PromptTokens,
 			TotalTokens:      completion.Usage.TotalTokens,
+			CachedTokens:     completion.Usage.PromptTokensDetails.CachedTokens,
 		},
+		FinishReason: fromFinishReason(choice.FinishReason),
 	}, nil
 }
+
+func fromFinishReason(reason string) types.FinishReason {
+	switch reason {
+	case "":
+		return ""
+	case "length":
+		return types.FinishReasonLength
+	case "tool_calls", "function_call":
+		return types.FinishReasonToolCalls
+	case "content_filter":
+		return types.FinishReasonContentFilter
+	default:
+		return types.FinishReasonStop
+	}
+}
@@ -2,6 +2,7 @@ package openai
 
 import (
 	"bytes"
+	"errors"
 	"strings"
 	"sync"
 
@@ -14,10 +15,16 @@ import (
 const (
 	ProviderName   = "openai"
 	DefaultBaseUrl = "https://api.openai.com/v1"
+
+	// decoded size limits of inline images and files
+	MaxImageSize = 20 << 20
+	MaxFileSize  = 32 << 20
 )
 
 var (
-	_ provider.Provider = (*OpenaiProvider)(nil)
+	_ provider.Provider        = (*OpenaiProvider)(nil)
+	_ provider.ErrorClassifier = (*OpenaiProvider)(nil)
+	_ provider.Embedder        = (*OpenaiProvider)(nil)
 )
 
 type OpenaiProvider struct {
@@ -31,6 +38,7 @@ func NewOpenaiProvider(opts ...provider.ProviderOption) (provider.Provider, erro
 	options := provider.GetProviderOptions(opts...)
 	openaiOpts := []option.RequestOption{
 		option.WithAPIKey(options.Sk),
+		option.WithHTTPClient(provider.HttpClient),
 	}
 
 	if options.Url == "" {
@@ -55,3 +63,11 @@ func NewOpenaiProvider(opts ...provider.ProviderOption) (provider.Provider, erro
 		},
 	}, nil
 }
+
+func (p *OpenaiProvider) ClassifyError(err error) provider.ErrorClass {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		return provider.ClassifyStatus(apiErr.StatusCode, apiErr.Code+" "+apiErr.Message)
+	}
+	return provider.ErrorClassOther
+}
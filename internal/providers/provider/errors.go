@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrorClass groups upstream errors by how a caller may react to them
+type ErrorClass string
+
+const (
+	ErrorClassRateLimit       ErrorClass = "rate_limit"
+	ErrorClassServer          ErrorClass = "server"
+	ErrorClassTimeout         ErrorClass = "timeout"
+	ErrorClassNetwork         ErrorClass = "network"
+	ErrorClassContextOverflow ErrorClass = "context_overflow"
+	ErrorClassAuth            ErrorClass = "auth"    // the provider rejected the credentials of the gateway
+	ErrorClassInvalid         ErrorClass = "invalid" // the provider rejected the request itself
+	ErrorClassOther           ErrorClass = "other"
+)
+
+// ErrorClassifier is implemented by providers that know the error types of their sdk
+type ErrorClassifier interface {
+	ClassifyError(err error) ErrorClass
+}
+
+// ClassifyError asks the provider first, then falls back to transport level errors
+func ClassifyError(p Provider, err error) ErrorClass {
+	if err == nil {
+		return ErrorClassOther
+	}
+
+	if classifier, ok := p.(ErrorClassifier); ok {
+		if class := classifier.ClassifyError(err); class != ErrorClassOther {
+			return class
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassNetwork
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorClassNetwork
+	}
+
+	return ErrorClassOther
+}
+
+// ClassifyStatus maps an http status and its error message to an error class
+func ClassifyStatus(status int, message string) ErrorClass {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return ErrorClassRateLimit
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return ErrorClassTimeout
+	case status >= http.StatusInternalServerError:
+		// anthropic reports overload with 529
+		return ErrorClassServer
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrorClassAuth
+	case status == http.StatusBadRequest || status == http.StatusRequestEntityTooLarge:
+		if isContextOverflow(message) {
+			return ErrorClassContextOverflow
+		}
+		return ErrorClassInvalid
+	case status == http.StatusNotFound || status == http.StatusUnprocessableEntity:
+		return ErrorClassInvalid
+	}
+
+	return ErrorClassOther
+}
+
+func isContextOverflow(message string) bool {
+	message = strings.ToLower(message)
+	for _, pattern := range []string{
+		"context_length_exceeded",              // openai
+		"maximum context length",               // openai
+		"prompt is too long",                   // anthropic
+		"exceeds the maximum number of tokens", // google
+		"input token count",                    // google
+	} {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
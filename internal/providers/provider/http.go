@@ -0,0 +1,12 @@
+package provider
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// HttpClient is used by the sdk clients of providers, it sends the trace context of a call upstream
+var HttpClient = &http.Client{
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+}
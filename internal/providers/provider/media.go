@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrMediaTooLarge is returned for inline images and files above the limit of a provider
+var ErrMediaTooLarge = errors.New("media too large")
+
+// DecodeMedia decodes inline base64 data, with or without padding, up to maxSize bytes, 0 means no limit
+func DecodeMedia(data string, maxSize int) ([]byte, error) {
+	data = strings.TrimRight(strings.TrimSpace(data), "=")
+	if maxSize > 0 && base64.RawStdEncoding.DecodedLen(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrMediaTooLarge, base64.RawStdEncoding.DecodedLen(len(data)), maxSize)
+	}
+
+	b, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil {
+		// some clients send url safe base64
+		if b, urlErr := base64.RawURLEncoding.DecodeString(data); urlErr == nil {
+			return b, nil
+		}
+		return nil, fmt.Errorf("invalid base64 media: %w", err)
+	}
+	return b, nil
+}
+
+// SniffMIMEType is the media type of data, the bytes of images and pdfs win over the declared
+// type, which providers reject when it does not match them. Other data keeps a declared type.
+func SniffMIMEType(declared string, data []byte) string {
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if strings.HasPrefix(sniffed, "image/") || sniffed == "application/pdf" {
+		return sniffed
+	}
+
+	if declared != "" && declared != "application/octet-stream" {
+		if mediaType, _, err := mime.ParseMediaType(declared); err == nil {
+			return mediaType
+		}
+	}
+	return sniffed
+}
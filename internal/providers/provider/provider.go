@@ -2,11 +2,19 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/xucx/llmapi/types"
 )
 
+// ErrCapability is returned when a provider can not honour an option or a message part
+var ErrCapability = errors.New("capability not supported")
+
+func CapabilityError(provider, capability string) error {
+	return fmt.Errorf("%w: %s does not support %s", ErrCapability, provider, capability)
+}
+
 type ProviderOptions struct {
 	Url      string
 	Insecure bool
@@ -21,6 +29,16 @@ type Provider interface {
 	Realtime(ctx context.Context, messages []*types.Message, options ...types.RealTimeOption) (types.RealTimeSession, error)
 }
 
+// Embedder is implemented by providers with embedding models
+type Embedder interface {
+	Embed(ctx context.Context, inputs []string, options ...types.EmbedOption) (*types.Embeddings, error)
+}
+
+// TokenCounter is implemented by providers which count the prompt tokens of a chat request
+type TokenCounter interface {
+	CountTokens(ctx context.Context, messages []*types.Message, options ...types.ChatOption) (*types.TokenCount, error)
+}
+
 func WithOptions(options *ProviderOptions) ProviderOption {
 	return func(opts *ProviderOptions) *ProviderOptions {
 		return options
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"path"
+	"slices"
+	"time"
+)
+
+// endpoints a key can be allowed to use
+const (
+	EndpointOpenai   = "openai"
+	EndpointClaude   = "claude"
+	EndpointGrpc     = "grpc"
+	EndpointRealtime = "realtime"
+	EndpointAdmin    = "admin" // never allowed by an empty endpoint list
+)
+
+// Identity is the api key a request is authenticated with
+type Identity struct {
+	Name          string
+	Models        []string // model names or globs, empty allows all
+	Endpoints     []string // empty allows all
+	ExpiresAt     time.Time
+	MonthlyBudget float64
+}
+
+func (i *Identity) Expired(now time.Time) bool {
+	return !i.ExpiresAt.IsZero() && now.After(i.ExpiresAt)
+}
+
+func (i *Identity) AllowEndpoint(endpoint string) bool {
+	if endpoint == EndpointAdmin {
+		return slices.Contains(i.Endpoints, endpoint)
+	}
+	return len(i.Endpoints) == 0 || slices.Contains(i.Endpoints, endpoint)
+}
+
+func (i *Identity) AllowModel(model string) bool {
+	if len(i.Models) == 0 {
+		return true
+	}
+
+	for _, pattern := range i.Models {
+		if ok, _ := path.Match(pattern, model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns nil when the server runs without keys
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+	return identity
+}
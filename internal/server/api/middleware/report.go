@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"slices"
+)
+
+// ErrorReporter receives the error of a failed chat call, model is the model name the caller asked for
+type ErrorReporter func(model string, err error)
+
+// FirstTokenReporter is called when the first chunk of a stream is sent to the caller
+type FirstTokenReporter func(model string)
+
+type errorReportersKey struct{}
+
+type firstTokenReportersKey struct{}
+
+func WithErrorReporter(ctx context.Context, reporter ErrorReporter) context.Context {
+	reporters, _ := ctx.Value(errorReportersKey{}).([]ErrorReporter)
+	return context.WithValue(ctx, errorReportersKey{}, append(slices.Clip(reporters), reporter))
+}
+
+func ReportError(ctx context.Context, model string, err error) {
+	reporters, _ := ctx.Value(errorReportersKey{}).([]ErrorReporter)
+	for _, reporter := range reporters {
+		reporter(model, err)
+	}
+}
+
+func WithFirstTokenReporter(ctx context.Context, reporter FirstTokenReporter) context.Context {
+	reporters, _ := ctx.Value(firstTokenReportersKey{}).([]FirstTokenReporter)
+	return context.WithValue(ctx, firstTokenReportersKey{}, append(slices.Clip(reporters), reporter))
+}
+
+func ReportFirstToken(ctx context.Context, model string) {
+	reporters, _ := ctx.Value(firstTokenReportersKey{}).([]FirstTokenReporter)
+	for _, reporter := range reporters {
+		reporter(model)
+	}
+}
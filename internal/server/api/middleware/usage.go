@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"context"
+	"slices"
+
+	"github.com/xucx/llmapi/types"
+)
+
+// UsageReporter receives each completion served in a request,
+// model is the model name the caller asked for
+type UsageReporter func(model string, completion *types.Completion)
+
+type usageReportersKey struct{}
+
+// WithUsageReporter adds a reporter to the request context, reporters added before keep working
+func WithUsageReporter(ctx context.Context, reporter UsageReporter) context.Context {
+	reporters, _ := ctx.Value(usageReportersKey{}).([]UsageReporter)
+	return context.WithValue(ctx, usageReportersKey{}, append(slices.Clip(reporters), reporter))
+}
+
+// ReportUsage is called by the api handlers after a completion is done
+func ReportUsage(ctx context.Context, model string, completion *types.Completion) {
+	reporters, _ := ctx.Value(usageReportersKey{}).([]UsageReporter)
+	for _, reporter := range reporters {
+		reporter(model, completion)
+	}
+}
@@ -3,9 +3,13 @@ package middlewares
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	apiv1 "github.com/xucx/llmapi/api/v1"
 	"github.com/xucx/llmapi/internal/server/api/middleware"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -18,18 +22,44 @@ const (
 	DefaultAuthHeaderType = "Bearer"
 )
 
+var (
+	ErrKeyExpired         = errors.New("key expired")
+	ErrEndpointNotAllowed = errors.New("endpoint not allowed")
+	ErrModelNotAllowed    = errors.New("model not allowed")
+)
+
 type HttpTokenGetter func(echo.Context) (string, error)
 
+// HttpEndpointGetter tells which endpoint, openai, claude or admin, a http request is for
+type HttpEndpointGetter func(echo.Context) string
+
 type AuthOpts struct {
-	Tokens          []string
-	HttpTokenGetter HttpTokenGetter
+	KeyStore           KeyStore // nil allows every request
+	HttpTokenGetter    HttpTokenGetter
+	HttpEndpointGetter HttpEndpointGetter
 }
 
 type AuthOpt func(*AuthOpts)
 
+// AuthWithTokens allows plain tokens with every scope, use AuthWithKeyStore for scoped keys
 func AuthWithTokens(tokens []string) AuthOpt {
 	return func(opts *AuthOpts) {
-		opts.Tokens = tokens
+		if len(tokens) == 0 {
+			return
+		}
+
+		keys := []KeyConfig{}
+		for i, token := range tokens {
+			keys = append(keys, KeyConfig{Name: fmt.Sprintf("token-%d", i), Hash: HashKey(token)})
+		}
+		store, _ := NewMemoryKeyStore(keys)
+		opts.KeyStore = store
+	}
+}
+
+func AuthWithKeyStore(store KeyStore) AuthOpt {
+	return func(opts *AuthOpts) {
+		opts.KeyStore = store
 	}
 }
 
@@ -39,6 +69,12 @@ func AuthWithHttpTokenGetter(getter HttpTokenGetter) AuthOpt {
 	}
 }
 
+func AuthWithHttpEndpointGetter(getter HttpEndpointGetter) AuthOpt {
+	return func(opts *AuthOpts) {
+		opts.HttpEndpointGetter = getter
+	}
+}
+
 type Auth struct {
 	middleware.NopMiddleware
 	opts *AuthOpts
@@ -46,7 +82,8 @@ type Auth struct {
 
 func NewAuth(opts ...AuthOpt) *Auth {
 	option := &AuthOpts{
-		HttpTokenGetter: DefaultAuthHttpHeaderGetter,
+		HttpTokenGetter:    DefaultAuthHttpHeaderGetter,
+		HttpEndpointGetter: DefaultAuthHttpEndpointGetter,
 	}
 
 	for _, opt := range opts {
@@ -58,76 +95,146 @@ func NewAuth(opts ...AuthOpt) *Auth {
 
 func (a *Auth) Unary() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (response interface{}, err error) {
-		if err := a.checkGrpcAuth(ctx); err != nil {
+		if a.opts.KeyStore == nil {
+			return handler(ctx, req)
+		}
+
+		identity, err := a.checkGrpcAuth(ctx)
+		if err != nil {
 			return nil, status.Errorf(codes.Unauthenticated, "Unauthenticated: %v", err)
 		}
-		return handler(ctx, req)
+
+		if err := checkScope(identity, middleware.EndpointGrpc, chatParamsModel(req)); err != nil {
+			return nil, status.Errorf(codes.PermissionDenied, "Permission Denied: %v", err)
+		}
+
+		return handler(middleware.WithIdentity(ctx, identity), req)
 	}
 }
 
 func (a *Auth) Stream() grpc.StreamServerInterceptor {
 	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		if err := a.checkGrpcAuth(stream.Context()); err != nil {
+		if a.opts.KeyStore == nil {
+			return handler(srv, stream)
+		}
+
+		identity, err := a.checkGrpcAuth(stream.Context())
+		if err != nil {
 			return status.Errorf(codes.Unauthenticated, "Unauthenticated: %v", err)
 		}
 
-		return handler(srv, stream)
+		endpoint := middleware.EndpointGrpc
+		if info.FullMethod == apiv1.ApiService_ChatRealtime_FullMethodName {
+			endpoint = middleware.EndpointRealtime
+		}
+
+		if err := checkScope(identity, endpoint, ""); err != nil {
+			return status.Errorf(codes.PermissionDenied, "Permission Denied: %v", err)
+		}
+
+		// the model comes with the first message
+		return handler(srv, &serverStream{
+			ServerStream: stream,
+			ctx:          middleware.WithIdentity(stream.Context(), identity),
+			onFirst: func(m any) error {
+				if err := checkScope(identity, endpoint, chatParamsModel(m)); err != nil {
+					return status.Errorf(codes.PermissionDenied, "Permission Denied: %v", err)
+				}
+				return nil
+			},
+		})
 	}
 }
 
 func (a *Auth) Http() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			if err := a.checkHttpAuth(c); err != nil {
+			if a.opts.KeyStore == nil {
+				return next(c)
+			}
+
+			identity, err := a.checkHttpAuth(c)
+			if err != nil {
 				return echo.ErrUnauthorized
 			}
+
+			model, _, err := httpChatRequest(c)
+			if err != nil {
+				return err
+			}
+
+			if err := checkScope(identity, a.opts.HttpEndpointGetter(c), model); err != nil {
+				return echo.NewHTTPError(http.StatusForbidden, err.Error())
+			}
+
+			c.SetRequest(c.Request().WithContext(middleware.WithIdentity(c.Request().Context(), identity)))
 			return next(c)
 		}
 	}
 }
 
-func (a *Auth) checkGrpcAuth(ctx context.Context) error {
+func (a *Auth) checkGrpcAuth(ctx context.Context) (*middleware.Identity, error) {
+	token, err := GrpcTokenFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		return errors.New("authorization not found")
+	return a.checkToken(token)
+}
+
+func (a *Auth) checkHttpAuth(ctx echo.Context) (*middleware.Identity, error) {
+
+	token, err := a.opts.HttpTokenGetter(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	tokens := md.Get(DefaultAuthHeader)
-	if len(tokens) == 0 {
-		return errors.New("authorization not found")
+	return a.checkToken(token)
+}
+
+func (a *Auth) checkToken(token string) (*middleware.Identity, error) {
+	identity, err := a.opts.KeyStore.Lookup(token)
+	if err != nil {
+		return nil, err
 	}
 
-	ts := strings.Fields(tokens[0])
-	if len(ts) != 2 || ts[0] != DefaultAuthHeaderType {
-		return errors.New("wrong token")
+	if identity.Expired(time.Now()) {
+		return nil, ErrKeyExpired
 	}
 
-	return a.checkToken(ts[1])
+	return identity, nil
 }
 
-func (a *Auth) checkHttpAuth(ctx echo.Context) error {
+// checkScope checks the endpoint, and the model when it is known
+func checkScope(identity *middleware.Identity, endpoint, model string) error {
+	if !identity.AllowEndpoint(endpoint) {
+		return fmt.Errorf("%w: %s", ErrEndpointNotAllowed, endpoint)
+	}
 
-	token, err := a.opts.HttpTokenGetter(ctx)
-	if err != nil {
-		return err
+	if model != "" && !identity.AllowModel(model) {
+		return fmt.Errorf("%w: %s", ErrModelNotAllowed, model)
 	}
 
-	return a.checkToken(token)
+	return nil
 }
 
-func (a *Auth) checkToken(token string) error {
-	if len(a.opts.Tokens) == 0 {
-		return nil
+func GrpcTokenFromContext(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", errors.New("authorization not found")
 	}
 
-	for _, t := range a.opts.Tokens {
-		if token == t {
-			return nil
-		}
+	tokens := md.Get(DefaultAuthHeader)
+	if len(tokens) == 0 {
+		return "", errors.New("authorization not found")
 	}
 
-	return errors.New("wrong token")
+	ts := strings.Fields(tokens[0])
+	if len(ts) != 2 || ts[0] != DefaultAuthHeaderType {
+		return "", errors.New("wrong token")
+	}
+
+	return ts[1], nil
 }
 
 func DefaultAuthHttpHeaderGetter(ctx echo.Context) (string, error) {
@@ -141,3 +248,13 @@ func DefaultAuthHttpHeaderGetter(ctx echo.Context) (string, error) {
 
 	return "", errors.New("authorization not found")
 }
+
+func DefaultAuthHttpEndpointGetter(ctx echo.Context) string {
+	if strings.Contains(ctx.Request().URL.Path, "/claude/") {
+		return middleware.EndpointClaude
+	}
+	if strings.Contains(ctx.Request().URL.Path, "/admin/") || ctx.Request().URL.Path == "/metrics" {
+		return middleware.EndpointAdmin
+	}
+	return middleware.EndpointOpenai
+}
@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"github.com/labstack/echo/v4"
+	echomiddleware "github.com/labstack/echo/v4/middleware"
+	"github.com/xucx/llmapi/internal/server/api/middleware"
+)
+
+// DefaultBodyLimit fits the largest inline images and documents of the providers in base64
+const DefaultBodyLimit = "64M"
+
+// BodyLimit rejects http requests with a larger body with 413, before any middleware reads it
+type BodyLimit struct {
+	middleware.NopMiddleware
+	limit string
+}
+
+// NewBodyLimit takes a limit like 64M or 2G, empty means DefaultBodyLimit
+func NewBodyLimit(limit string) *BodyLimit {
+	if limit == "" {
+		limit = DefaultBodyLimit
+	}
+	return &BodyLimit{limit: limit}
+}
+
+func (b *BodyLimit) Http() echo.MiddlewareFunc {
+	return echomiddleware.BodyLimit(b.limit)
+}
@@ -0,0 +1,79 @@
+package middlewares
+
+import (
+	"context"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xucx/llmapi"
+	"github.com/xucx/llmapi/internal/server/api/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// CacheHeader asks to bypass the response cache with the value bypass,
+// and tells whether a response of a cached model was a hit or a miss
+const CacheHeader = "X-Llmapi-Cache"
+
+// Cache passes the cache header of requests to llmapi.Models, and sets the
+// cache status of the response
+type Cache struct {
+	middleware.NopMiddleware
+}
+
+func NewCache() *Cache {
+	return &Cache{}
+}
+
+func (*Cache) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (response interface{}, err error) {
+		ctx = withGrpcCache(ctx, func(md metadata.MD) {
+			_ = grpc.SetHeader(ctx, md)
+		})
+		return handler(ctx, req)
+	}
+}
+
+func (*Cache) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := withGrpcCache(stream.Context(), func(md metadata.MD) {
+			_ = stream.SetHeader(md)
+		})
+		return handler(srv, &serverStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+func (*Cache) Http() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := req.Context()
+			if isCacheBypass(req.Header.Get(CacheHeader)) {
+				ctx = llmapi.WithCacheBypass(ctx)
+			}
+			ctx = llmapi.WithCacheStatusFunc(ctx, func(status llmapi.CacheStatus) {
+				// the status is known before the first chunk, a committed response means it is too late
+				if !c.Response().Committed {
+					c.Response().Header().Set(CacheHeader, string(status))
+				}
+			})
+			c.SetRequest(req.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+func withGrpcCache(ctx context.Context, setHeader func(metadata.MD)) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(CacheHeader); len(values) > 0 && isCacheBypass(values[0]) {
+		ctx = llmapi.WithCacheBypass(ctx)
+	}
+
+	return llmapi.WithCacheStatusFunc(ctx, func(status llmapi.CacheStatus) {
+		setHeader(metadata.Pairs(CacheHeader, string(status)))
+	})
+}
+
+func isCacheBypass(value string) bool {
+	return strings.EqualFold(strings.TrimSpace(value), string(llmapi.CacheStatusBypass))
+}
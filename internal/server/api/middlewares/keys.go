@@ -0,0 +1,191 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/xucx/llmapi/internal/server/api/middleware"
+	"github.com/xucx/llmapi/log"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	keyPrefix = "sk-"
+
+	// a changed keys file is picked up within this interval
+	keysFileCheckInterval = 5 * time.Second
+)
+
+var (
+	ErrKeyNotFound = errors.New("key not found")
+)
+
+type KeyConfig struct {
+	Name          string    `yaml:"name"`
+	Hash          string    `yaml:"hash"`          // hex sha256 of the secret, see HashKey
+	Models        []string  `yaml:"models"`        // model names or globs like "gpt-4*" and "openai/*", empty allows all
+	Endpoints     []string  `yaml:"endpoints"`     // openai, claude, grpc, realtime, admin, empty allows all but admin
+	ExpiresAt     time.Time `yaml:"expiresAt"`     // zero never expires
+	MonthlyBudget float64   `yaml:"monthlyBudget"` // USD, zero is unlimited
+}
+
+// KeyStore resolves a secret from a request to its identity
+type KeyStore interface {
+	Lookup(secret string) (*middleware.Identity, error)
+}
+
+// HashKey is how secrets are kept in a key store, keys are random so no salt is needed
+func HashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateKey returns a new random secret
+func GenerateKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return keyPrefix + hex.EncodeToString(b), nil
+}
+
+type MemoryKeyStore struct {
+	keys map[string]*middleware.Identity // by hash
+}
+
+func NewMemoryKeyStore(keys []KeyConfig) (*MemoryKeyStore, error) {
+	store := &MemoryKeyStore{keys: map[string]*middleware.Identity{}}
+	for _, k := range keys {
+		if k.Name == "" || k.Hash == "" {
+			return nil, errors.New("key name and hash can not be empty")
+		}
+
+		hash, err := hex.DecodeString(k.Hash)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("key %s hash is not a hex sha256", k.Name)
+		}
+
+		for _, endpoint := range k.Endpoints {
+			switch endpoint {
+			case middleware.EndpointOpenai, middleware.EndpointClaude, middleware.EndpointGrpc, middleware.EndpointRealtime, middleware.EndpointAdmin:
+			default:
+				return nil, fmt.Errorf("key %s endpoint %s not support", k.Name, endpoint)
+			}
+		}
+
+		store.keys[hex.EncodeToString(hash)] = &middleware.Identity{
+			Name:          k.Name,
+			Models:        k.Models,
+			Endpoints:     k.Endpoints,
+			ExpiresAt:     k.ExpiresAt,
+			MonthlyBudget: k.MonthlyBudget,
+		}
+	}
+
+	return store, nil
+}
+
+func (s *MemoryKeyStore) Len() int {
+	return len(s.keys)
+}
+
+// Lookup looks the hash up in a map, so no secret is compared byte by byte
+func (s *MemoryKeyStore) Lookup(secret string) (*middleware.Identity, error) {
+	if identity, ok := s.keys[HashKey(secret)]; ok {
+		return identity, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+// FileKeyStore reads keys from a yaml file and reloads it when it changes
+type FileKeyStore struct {
+	file string
+
+	mu      sync.Mutex
+	store   *MemoryKeyStore
+	modTime time.Time
+	checked time.Time
+}
+
+func NewFileKeyStore(file string) (*FileKeyStore, error) {
+	s := &FileKeyStore{file: file}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileKeyStore) Lookup(secret string) (*middleware.Identity, error) {
+	s.mu.Lock()
+	if time.Since(s.checked) > keysFileCheckInterval {
+		// a broken file keeps the keys loaded before
+		if err := s.reload(); err != nil {
+			log.Errorw("reload keys file fail", "file", s.file, "error", err)
+		}
+	}
+	store := s.store
+	s.mu.Unlock()
+
+	return store.Lookup(secret)
+}
+
+func (s *FileKeyStore) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reload()
+}
+
+func (s *FileKeyStore) reload() error {
+	s.checked = time.Now()
+
+	info, err := os.Stat(s.file)
+	if err != nil {
+		return err
+	}
+	if s.store != nil && info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	fd, err := os.ReadFile(s.file)
+	if err != nil {
+		return err
+	}
+
+	keys := []KeyConfig{}
+	if err := yaml.Unmarshal(fd, &keys); err != nil {
+		return err
+	}
+
+	store, err := NewMemoryKeyStore(keys)
+	if err != nil {
+		return err
+	}
+
+	s.store = store
+	s.modTime = info.ModTime()
+	log.Infow("keys file loaded", "file", s.file, "keys", store.Len())
+	return nil
+}
+
+// MultiKeyStore looks the secret up in each store in order
+type MultiKeyStore []KeyStore
+
+func (m MultiKeyStore) Lookup(secret string) (*middleware.Identity, error) {
+	for _, store := range m {
+		identity, err := store.Lookup(secret)
+		if err == nil {
+			return identity, nil
+		}
+		if !errors.Is(err, ErrKeyNotFound) {
+			return nil, err
+		}
+	}
+	return nil, ErrKeyNotFound
+}
@@ -37,7 +37,13 @@ func (*Logger) Http() echo.MiddlewareFunc {
 		LogFormValues:    nil,
 		HandleError:      true, // forwards error to the global error handler, so it can decide appropriate status code
 		LogValuesFunc: func(c echo.Context, v echomiddleware.RequestLoggerValues) error {
+			key := ""
+			if identity := middleware.IdentityFromContext(c.Request().Context()); identity != nil {
+				key = identity.Name
+			}
+
 			log.Infow("http reqeust",
+				"key", key,
 
 				"method", v.Method,
 				"uri", v.URI,
@@ -0,0 +1,242 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/xucx/llmapi"
+	apiv1 "github.com/xucx/llmapi/api/v1"
+	"github.com/xucx/llmapi/internal/server/api/middleware"
+	"github.com/xucx/llmapi/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	metricsNamespace = "llmapi"
+
+	// model label of requests which never reach a model, eg unauthenticated or unknown ones
+	metricsUnknownModel = "unknown"
+)
+
+var (
+	// llm calls take seconds to minutes
+	latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+	firstTokenBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30}
+)
+
+// Metrics exports prometheus metrics of http and grpc requests
+type Metrics struct {
+	middleware.NopMiddleware
+	registry *prometheus.Registry
+
+	requests         *prometheus.CounterVec
+	latency          *prometheus.HistogramVec
+	firstToken       *prometheus.HistogramVec
+	tokens           *prometheus.CounterVec
+	streams          *prometheus.GaugeVec
+	realtimeSessions prometheus.Gauge
+	upstreamErrors   *prometheus.CounterVec
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_total",
+			Help:      "Requests by route, model, provider and status.",
+		}, []string{"route", "model", "provider", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Request latency by route, model, provider and status.",
+			Buckets:   latencyBuckets,
+		}, []string{"route", "model", "provider", "status"}),
+		firstToken: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "time_to_first_token_seconds",
+			Help:      "Time from the request to the first streamed chunk.",
+			Buckets:   firstTokenBuckets,
+		}, []string{"route", "model"}),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "tokens_total",
+			Help:      "Tokens of completions by model, provider and type, type is prompt, completion, cached or cache_write.",
+		}, []string{"model", "provider", "type"}),
+		streams: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "streams_in_flight",
+			Help:      "Streaming requests in flight by route.",
+		}, []string{"route"}),
+		realtimeSessions: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "realtime_sessions",
+			Help:      "Open realtime sessions.",
+		}),
+		upstreamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "upstream_errors_total",
+			Help:      "Errors returned by providers after retries and fallbacks, by model, provider and class.",
+		}, []string{"model", "provider", "class"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.latency,
+		m.firstToken,
+		m.tokens,
+		m.streams,
+		m.realtimeSessions,
+		m.upstreamErrors,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the prometheus text format
+func (m *Metrics) Handler() echo.HandlerFunc {
+	return echo.WrapHandler(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
+
+func (m *Metrics) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (response interface{}, err error) {
+		call := m.newCall(ctx, info.FullMethod)
+		rsp, err := handler(call.ctx, req)
+		call.done(status.Code(err).String())
+		return rsp, err
+	}
+}
+
+func (m *Metrics) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if info.FullMethod == apiv1.ApiService_ChatRealtime_FullMethodName {
+			m.realtimeSessions.Inc()
+			defer m.realtimeSessions.Dec()
+		} else {
+			m.streams.WithLabelValues(info.FullMethod).Inc()
+			defer m.streams.WithLabelValues(info.FullMethod).Dec()
+		}
+
+		call := m.newCall(stream.Context(), info.FullMethod)
+		err := handler(srv, &serverStream{
+			ServerStream: stream,
+			ctx:          call.ctx,
+		})
+		call.done(status.Code(err).String())
+		return err
+	}
+}
+
+func (m *Metrics) Http() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			_, stream, err := httpChatRequest(c)
+			if err != nil {
+				return err
+			}
+
+			// the route pattern, so paths of unknown routes do not blow up the labels
+			route := c.Path()
+			if stream {
+				m.streams.WithLabelValues(route).Inc()
+				defer m.streams.WithLabelValues(route).Dec()
+			}
+
+			call := m.newCall(c.Request().Context(), route)
+			c.SetRequest(c.Request().WithContext(call.ctx))
+
+			err = next(c)
+
+			code := c.Response().Status
+			var httpErr *echo.HTTPError
+			if errors.As(err, &httpErr) {
+				code = httpErr.Code
+			} else if err != nil {
+				code = http.StatusInternalServerError
+			}
+			call.done(strconv.Itoa(code))
+			return err
+		}
+	}
+}
+
+// metricsCall collects the labels of a request from the reports of the handler, the model
+// is only taken from the reports, the model of a request body is not checked yet and
+// would let any caller add series
+type metricsCall struct {
+	metrics *Metrics
+	ctx     context.Context
+	route   string
+	start   time.Time
+
+	mu       sync.Mutex
+	model    string
+	provider string
+}
+
+func (m *Metrics) newCall(ctx context.Context, route string) *metricsCall {
+	call := &metricsCall{
+		metrics: m,
+		route:   route,
+		start:   time.Now(),
+		model:   metricsUnknownModel,
+	}
+
+	ctx = middleware.WithUsageReporter(ctx, call.reportUsage)
+	ctx = middleware.WithErrorReporter(ctx, call.reportError)
+	ctx = middleware.WithFirstTokenReporter(ctx, call.reportFirstToken)
+	call.ctx = ctx
+	return call
+}
+
+func (c *metricsCall) reportUsage(model string, completion *types.Completion) {
+	c.mu.Lock()
+	c.model = model
+	c.provider = completion.Provider
+	c.mu.Unlock()
+
+	tokens := c.metrics.tokens
+	tokens.WithLabelValues(model, completion.Provider, "prompt").Add(float64(completion.Usage.PromptTokens))
+	tokens.WithLabelValues(model, completion.Provider, "completion").Add(float64(completion.Usage.CompletionTokens))
+	tokens.WithLabelValues(model, completion.Provider, "cached").Add(float64(completion.Usage.CachedTokens))
+	tokens.WithLabelValues(model, completion.Provider, "cache_write").Add(float64(completion.Usage.CacheWriteTokens))
+}
+
+func (c *metricsCall) reportError(model string, err error) {
+	var upstreamErr *llmapi.UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		return
+	}
+
+	c.mu.Lock()
+	c.model = model
+	c.provider = upstreamErr.Provider
+	c.mu.Unlock()
+
+	c.metrics.upstreamErrors.WithLabelValues(model, upstreamErr.Provider, string(upstreamErr.Class)).Inc()
+}
+
+func (c *metricsCall) reportFirstToken(model string) {
+	c.metrics.firstToken.WithLabelValues(c.route, model).Observe(time.Since(c.start).Seconds())
+}
+
+func (c *metricsCall) done(status string) {
+	c.mu.Lock()
+	model, provider := c.model, c.provider
+	c.mu.Unlock()
+
+	c.metrics.requests.WithLabelValues(c.route, model, provider, status).Inc()
+	c.metrics.latency.WithLabelValues(c.route, model, provider, status).Observe(time.Since(c.start).Seconds())
+}
@@ -0,0 +1,421 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xucx/llmapi/internal/server/api/middleware"
+	"github.com/xucx/llmapi/types"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	RetryAfterHeader = "Retry-After"
+
+	rateLimitWindow = time.Minute
+)
+
+var (
+	ErrRequestsPerMinute = errors.New("requests per minute exceeded")
+	ErrTokensPerMinute   = errors.New("tokens per minute exceeded")
+	ErrConcurrentStreams = errors.New("concurrent streams exceeded")
+)
+
+// Quota limits a key or a model, zero means unlimited
+type Quota struct {
+	RPM               int   `yaml:"rpm"`               // requests per minute
+	TPM               int64 `yaml:"tpm"`               // tokens per minute, charged with the usage after each call
+	ConcurrentStreams int   `yaml:"concurrentStreams"` // streaming requests in flight
+}
+
+func (q Quota) unlimited() bool {
+	return q.RPM <= 0 && q.TPM <= 0 && q.ConcurrentStreams <= 0
+}
+
+type RateLimitConfig struct {
+	Key    Quota            `yaml:"key"`    // quota of each key, or of each token when the server runs without keys
+	Keys   map[string]Quota `yaml:"keys"`   // quota by key name, overrides Key
+	Model  Quota            `yaml:"model"`  // quota of each model, shared by all keys
+	Models map[string]Quota `yaml:"models"` // quota by model name, overrides Model
+}
+
+type RateLimitOpts struct {
+	Config          RateLimitConfig
+	HttpTokenGetter HttpTokenGetter
+}
+
+type RateLimitOpt func(*RateLimitOpts)
+
+func RateLimitWithConfig(conf RateLimitConfig) RateLimitOpt {
+	return func(opts *RateLimitOpts) {
+		opts.Config = conf
+	}
+}
+
+func RateLimitWithHttpTokenGetter(getter HttpTokenGetter) RateLimitOpt {
+	return func(opts *RateLimitOpts) {
+		opts.HttpTokenGetter = getter
+	}
+}
+
+type RateLimit struct {
+	middleware.NopMiddleware
+	opts   *RateLimitOpts
+	keys   *limiterSet
+	models *limiterSet
+}
+
+func NewRateLimit(opts ...RateLimitOpt) *RateLimit {
+	option := &RateLimitOpts{
+		HttpTokenGetter: DefaultAuthHttpHeaderGetter,
+	}
+
+	for _, opt := range opts {
+		opt(option)
+	}
+
+	conf := option.Config
+	return &RateLimit{
+		opts: option,
+		keys: newLimiterSet(func(key string) Quota {
+			if quota, ok := conf.Keys[key]; ok {
+				return quota
+			}
+			return conf.Key
+		}),
+		models: newLimiterSet(func(model string) Quota {
+			if quota, ok := conf.Models[model]; ok {
+				return quota
+			}
+			return conf.Model
+		}),
+	}
+}
+
+func (r *RateLimit) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (response interface{}, err error) {
+		key := r.grpcKey(ctx)
+
+		release, err := r.acquire(key, chatParamsModel(req), false)
+		if err != nil {
+			return nil, grpcRateLimitError(ctx, nil, err)
+		}
+		defer release()
+
+		return handler(r.withUsageReporter(ctx, key), req)
+	}
+}
+
+func (r *RateLimit) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		key := r.grpcKey(stream.Context())
+
+		// the model comes with the first message, only the key is known now
+		release, err := r.acquire(key, "", true)
+		if err != nil {
+			return grpcRateLimitError(stream.Context(), stream, err)
+		}
+		defer release()
+
+		releaseModel := func() {}
+		defer func() { releaseModel() }()
+
+		ctx := r.withUsageReporter(stream.Context(), key)
+		return handler(srv, &serverStream{
+			ServerStream: stream,
+			ctx:          ctx,
+			onFirst: func(m any) error {
+				model := chatParamsModel(m)
+				if model == "" {
+					return nil
+				}
+
+				release, err := r.models.acquire(model, true)
+				if err != nil {
+					return grpcRateLimitError(ctx, stream, fmt.Errorf("model %s %w", model, err))
+				}
+				releaseModel = release
+				return nil
+			},
+		})
+	}
+}
+
+func (r *RateLimit) Http() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := r.httpKey(c)
+
+			model, stream, err := httpChatRequest(c)
+			if err != nil {
+				return err
+			}
+
+			release, err := r.acquire(key, model, stream)
+			if err != nil {
+				var limitErr *rateLimitError
+				if errors.As(err, &limitErr) {
+					c.Response().Header().Set(RetryAfterHeader, retryAfterSeconds(limitErr.retryAfter))
+				}
+				return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
+			}
+			defer release()
+
+			c.SetRequest(c.Request().WithContext(r.withUsageReporter(c.Request().Context(), key)))
+			return next(c)
+		}
+	}
+}
+
+// grpcKey is the key name set by auth, or the token when the server runs without keys
+func (r *RateLimit) grpcKey(ctx context.Context) string {
+	if identity := middleware.IdentityFromContext(ctx); identity != nil {
+		return identity.Name
+	}
+	token, _ := GrpcTokenFromContext(ctx)
+	return token
+}
+
+func (r *RateLimit) httpKey(c echo.Context) string {
+	if identity := middleware.IdentityFromContext(c.Request().Context()); identity != nil {
+		return identity.Name
+	}
+	token, _ := r.opts.HttpTokenGetter(c)
+	return token
+}
+
+// acquire takes a request from the key and the model, and a stream slot for streams
+func (r *RateLimit) acquire(key, model string, stream bool) (func(), error) {
+	releaseKey, err := r.keys.acquire(key, stream)
+	if err != nil {
+		return nil, fmt.Errorf("key %w", err)
+	}
+
+	if model == "" {
+		return releaseKey, nil
+	}
+
+	releaseModel, err := r.models.acquire(model, stream)
+	if err != nil {
+		releaseKey()
+		r.keys.refund(key)
+		return nil, fmt.Errorf("model %s %w", model, err)
+	}
+
+	return func() {
+		releaseModel()
+		releaseKey()
+	}, nil
+}
+
+func (r *RateLimit) withUsageReporter(ctx context.Context, key string) context.Context {
+	return middleware.WithUsageReporter(ctx, func(model string, completion *types.Completion) {
+		total := completion.Usage.TotalTokens
+		if total == 0 {
+			total = completion.Usage.PromptTokens + completion.Usage.CompletionTokens
+		}
+		r.keys.charge(key, total)
+		r.models.charge(model, total)
+	})
+}
+
+type rateLimitError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *rateLimitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *rateLimitError) Unwrap() error {
+	return e.err
+}
+
+func grpcRateLimitError(ctx context.Context, stream grpc.ServerStream, err error) error {
+	st := status.New(codes.ResourceExhausted, err.Error())
+
+	var limitErr *rateLimitError
+	if errors.As(err, &limitErr) {
+		header := metadata.Pairs(strings.ToLower(RetryAfterHeader), retryAfterSeconds(limitErr.retryAfter))
+		if stream != nil {
+			stream.SetHeader(header)
+		} else {
+			grpc.SetHeader(ctx, header)
+		}
+
+		if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(limitErr.retryAfter)}); err == nil {
+			st = detailed
+		}
+	}
+
+	return st.Err()
+}
+
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(max(1, int(math.Ceil(d.Seconds()))))
+}
+
+// limiterSet keeps token buckets of keys or models
+type limiterSet struct {
+	quota func(key string) Quota
+
+	mu     sync.Mutex
+	states map[string]*limiterState
+	swept  time.Time
+}
+
+type limiterState struct {
+	quota    Quota
+	requests bucket
+	tokens   bucket
+	streams  int
+}
+
+// bucket refills its capacity over a minute, level may go below zero when tokens are charged after a call
+type bucket struct {
+	level float64
+	last  time.Time
+}
+
+func (b *bucket) refill(capacity float64, now time.Time) {
+	b.level = math.Min(capacity, b.level+capacity*now.Sub(b.last).Seconds()/rateLimitWindow.Seconds())
+	b.last = now
+}
+
+// wait is the time until the level reaches one
+func (b *bucket) wait(capacity float64) time.Duration {
+	return time.Duration((1 - b.level) / capacity * float64(rateLimitWindow))
+}
+
+func newLimiterSet(quota func(key string) Quota) *limiterSet {
+	return &limiterSet{
+		quota:  quota,
+		states: map[string]*limiterState{},
+		swept:  time.Now(),
+	}
+}
+
+func (s *limiterSet) state(key string, now time.Time) *limiterState {
+	if state, ok := s.states[key]; ok {
+		return state
+	}
+
+	quota := s.quota(key)
+	if quota.unlimited() {
+		return nil
+	}
+
+	state := &limiterState{
+		quota:    quota,
+		requests: bucket{level: float64(quota.RPM), last: now},
+		tokens:   bucket{level: float64(quota.TPM), last: now},
+	}
+	s.states[key] = state
+	return state
+}
+
+func (s *limiterSet) acquire(key string, stream bool) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	state := s.state(key, now)
+	if state == nil {
+		return func() {}, nil
+	}
+
+	if state.quota.RPM > 0 {
+		state.requests.refill(float64(state.quota.RPM), now)
+		if state.requests.level < 1 {
+			return nil, &rateLimitError{err: ErrRequestsPerMinute, retryAfter: state.requests.wait(float64(state.quota.RPM))}
+		}
+	}
+
+	if state.quota.TPM > 0 {
+		state.tokens.refill(float64(state.quota.TPM), now)
+		if state.tokens.level < 1 {
+			return nil, &rateLimitError{err: ErrTokensPerMinute, retryAfter: state.tokens.wait(float64(state.quota.TPM))}
+		}
+	}
+
+	if stream && state.quota.ConcurrentStreams > 0 && state.streams >= state.quota.ConcurrentStreams {
+		return nil, &rateLimitError{err: ErrConcurrentStreams, retryAfter: time.Second}
+	}
+
+	if state.quota.RPM > 0 {
+		state.requests.level--
+	}
+
+	if !stream {
+		return func() {}, nil
+	}
+
+	state.streams++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			state.streams--
+			s.mu.Unlock()
+		})
+	}, nil
+}
+
+func (s *limiterSet) refund(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state, ok := s.states[key]; ok && state.quota.RPM > 0 {
+		state.requests.level = math.Min(float64(state.quota.RPM), state.requests.level+1)
+	}
+}
+
+func (s *limiterSet) charge(key string, tokens int64) {
+	if tokens <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if state := s.state(key, now); state != nil && state.quota.TPM > 0 {
+		state.tokens.refill(float64(state.quota.TPM), now)
+		state.tokens.level -= float64(tokens)
+	}
+}
+
+// sweep drops idle states once a window, so unknown keys do not pile up
+func (s *limiterSet) sweep(now time.Time) {
+	if now.Sub(s.swept) < rateLimitWindow {
+		return
+	}
+	s.swept = now
+
+	for key, state := range s.states {
+		if state.streams > 0 {
+			continue
+		}
+		state.requests.refill(float64(state.quota.RPM), now)
+		state.tokens.refill(float64(state.quota.TPM), now)
+		if state.requests.level >= float64(state.quota.RPM) && state.tokens.level >= float64(state.quota.TPM) {
+			delete(s.states, key)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestBucketRefill(t *testing.T) {
+	now := time.Now()
+	b := bucket{level: 0, last: now}
+
+	b.refill(60, now.Add(10*time.Second))
+	if b.level != 10 {
+		t.Errorf("level after 10s at 60 rpm = %v, want 10", b.level)
+	}
+
+	// never above the capacity
+	b.refill(60, now.Add(5*time.Minute))
+	if b.level != 60 {
+		t.Errorf("level after 5m = %v, want 60", b.level)
+	}
+}
+
+func TestBucketWait(t *testing.T) {
+	cases := []struct {
+		level    float64
+		capacity float64
+		wait     time.Duration
+	}{
+		{0, 60, time.Second},
+		{0.5, 60, 500 * time.Millisecond},
+		{-59, 60, time.Minute}, // charged after a call beyond the level
+	}
+
+	for _, c := range cases {
+		b := bucket{level: c.level}
+		if got := b.wait(c.capacity); got != c.wait {
+			t.Errorf("wait of level %v capacity %v = %v, want %v", c.level, c.capacity, got, c.wait)
+		}
+	}
+}
+
+func TestLimiterRequestsPerMinute(t *testing.T) {
+	s := newLimiterSet(func(string) Quota { return Quota{RPM: 2} })
+
+	for range 2 {
+		if _, err := s.acquire("k", false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := s.acquire("k", false)
+	var limitErr *rateLimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrRequestsPerMinute) {
+		t.Fatalf("third request: %v, want %v", err, ErrRequestsPerMinute)
+	}
+	if limitErr.retryAfter <= 0 || limitErr.retryAfter > 30*time.Second {
+		t.Errorf("retry after %v, want up to 30s at 2 rpm", limitErr.retryAfter)
+	}
+
+	// half a minute later one request is back
+	s.states["k"].requests.last = s.states["k"].requests.last.Add(-30 * time.Second)
+	if _, err := s.acquire("k", false); err != nil {
+		t.Errorf("request after the refill: %v", err)
+	}
+
+	// other keys have their own bucket
+	if _, err := s.acquire("other", false); err != nil {
+		t.Errorf("request of another key: %v", err)
+	}
+}
+
+func TestLimiterTokensPerMinute(t *testing.T) {
+	s := newLimiterSet(func(string) Quota { return Quota{TPM: 1000} })
+
+	if _, err := s.acquire("k", false); err != nil {
+		t.Fatal(err)
+	}
+	// the usage is charged after the call, beyond what is left
+	s.charge("k", 1500)
+
+	_, err := s.acquire("k", false)
+	var limitErr *rateLimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrTokensPerMinute) {
+		t.Fatalf("request over the tokens: %v, want %v", err, ErrTokensPerMinute)
+	}
+	if limitErr.retryAfter < 29*time.Second || limitErr.retryAfter > 31*time.Second {
+		t.Errorf("retry after %v, want about 30s for 501 tokens at 1000 tpm", limitErr.retryAfter)
+	}
+}
+
+func TestLimiterConcurrentStreams(t *testing.T) {
+	s := newLimiterSet(func(string) Quota { return Quota{ConcurrentStreams: 1} })
+
+	release, err := s.acquire("k", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.acquire("k", true); !errors.Is(err, ErrConcurrentStreams) {
+		t.Fatalf("second stream: %v, want %v", err, ErrConcurrentStreams)
+	}
+	if _, err := s.acquire("k", false); err != nil {
+		t.Errorf("request while streaming: %v", err)
+	}
+
+	release()
+	release() // released once
+	if _, err := s.acquire("k", true); err != nil {
+		t.Errorf("stream after the release: %v", err)
+	}
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	s := newLimiterSet(func(string) Quota { return Quota{} })
+
+	for range 100 {
+		if _, err := s.acquire("k", true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.states) != 0 {
+		t.Errorf("unlimited keys keep %d states", len(s.states))
+	}
+}
+
+func TestRateLimitHttpRetryAfter(t *testing.T) {
+	r := NewRateLimit(RateLimitWithConfig(RateLimitConfig{
+		Models: map[string]Quota{"gpt-4": {RPM: 1}},
+	}))
+
+	e := echo.New()
+	e.Use(r.Http())
+	e.POST("/v1/chat/completions", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer token")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := send(); rec.Code != http.StatusOK {
+		t.Fatalf("first request: %d", rec.Code)
+	}
+
+	rec := send()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get(RetryAfterHeader); got != "60" {
+		t.Errorf("Retry-After %q, want 60", got)
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	cases := map[time.Duration]string{
+		0:                       "1",
+		300 * time.Millisecond:  "1",
+		1500 * time.Millisecond: "2",
+		time.Minute:             "60",
+	}
+	for d, want := range cases {
+		if got := retryAfterSeconds(d); got != want {
+			t.Errorf("retryAfterSeconds(%v) = %s, want %s", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	apiv1 "github.com/xucx/llmapi/api/v1"
+	"google.golang.org/grpc"
+)
+
+// serverStream replaces the context of a grpc stream, and checks the first
+// received message, which carries the chat params
+type serverStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	onFirst  func(m any) error
+	received bool
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	if s.received || s.onFirst == nil {
+		return nil
+	}
+	s.received = true
+
+	return s.onFirst(m)
+}
+
+// chatParamsModel reads the model of grpc chat and embed requests
+func chatParamsModel(req any) string {
+	switch r := req.(type) {
+	case interface{ GetChatParams() *apiv1.ChatParams }:
+		return r.GetChatParams().GetModel()
+	case interface {
+		GetInit() *apiv1.ChatRealtimeRequest_Init
+	}:
+		return r.GetInit().GetChatParams().GetModel()
+	case interface{ GetModel() string }:
+		return r.GetModel()
+	default:
+		return ""
+	}
+}
+
+// chatRequestKey keeps the peeked chat request in the echo context, for the next middlewares
+const chatRequestKey = "llmapi.chat_request"
+
+type chatRequest struct {
+	Model  string `json:"model"`
+	Stream bool   `json:"stream"`
+}
+
+// httpChatRequest peeks the model and the stream flag of openai and claude requests, the body
+// is read and parsed once per request, its size is limited by BodyLimit. The error is an
+// *echo.HTTPError.
+func httpChatRequest(c echo.Context) (string, bool, error) {
+	if chat, ok := c.Get(chatRequestKey).(*chatRequest); ok {
+		return chat.Model, chat.Stream, nil
+	}
+
+	chat := &chatRequest{}
+	req := c.Request()
+	if req.Method != http.MethodPost || req.Body == nil ||
+		!strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		c.Set(chatRequestKey, chat)
+		return "", false, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return "", false, httpErr
+		}
+		return "", false, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	// a body which is not a chat request is left to the handler
+	_ = json.Unmarshal(body, chat)
+
+	c.Set(chatRequestKey, chat)
+	return chat.Model, chat.Stream, nil
+}
@@ -0,0 +1,172 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xucx/llmapi/internal/server/api/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const tracerName = "github.com/xucx/llmapi/internal/server"
+
+// Tracing starts a server span of each request, with the trace context sent by the caller as parent.
+// The spans go to the global otel tracer provider.
+type Tracing struct {
+	middleware.NopMiddleware
+	tracer trace.Tracer
+}
+
+func NewTracing() *Tracing {
+	return &Tracing{tracer: otel.Tracer(tracerName)}
+}
+
+func (t *Tracing) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (response interface{}, err error) {
+		ctx, span := t.startGrpc(ctx, info.FullMethod)
+		defer span.End()
+
+		if model := chatParamsModel(req); model != "" {
+			span.SetAttributes(semconv.GenAIRequestModel(model))
+		}
+
+		rsp, err := handler(t.withFirstTokenEvent(ctx, span), req)
+		endGrpcSpan(span, err)
+		return rsp, err
+	}
+}
+
+func (t *Tracing) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := t.startGrpc(stream.Context(), info.FullMethod)
+		defer span.End()
+
+		err := handler(srv, &serverStream{
+			ServerStream: stream,
+			ctx:          t.withFirstTokenEvent(ctx, span),
+			onFirst: func(m any) error {
+				if model := chatParamsModel(m); model != "" {
+					span.SetAttributes(semconv.GenAIRequestModel(model))
+				}
+				return nil
+			},
+		})
+		endGrpcSpan(span, err)
+		return err
+	}
+}
+
+func (t *Tracing) Http() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+			route := c.Path()
+			ctx, span := t.tracer.Start(ctx, req.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(req.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(req.URL.Path),
+				),
+			)
+			defer span.End()
+
+			c.SetRequest(req.WithContext(t.withFirstTokenEvent(ctx, span)))
+
+			err := next(c)
+
+			code := c.Response().Status
+			var httpErr *echo.HTTPError
+			if errors.As(err, &httpErr) {
+				code = httpErr.Code
+			} else if err != nil {
+				code = http.StatusInternalServerError
+			}
+			span.SetAttributes(semconv.HTTPResponseStatusCode(code))
+			if code >= http.StatusInternalServerError {
+				span.SetStatus(otelcodes.Error, http.StatusText(code))
+			}
+			if err != nil {
+				span.RecordError(err)
+			}
+			return err
+		}
+	}
+}
+
+func (t *Tracing) startGrpc(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+
+	// full method is /package.service/method
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return t.tracer.Start(ctx, strings.TrimPrefix(fullMethod, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.RPCSystemGRPC,
+			semconv.RPCService(service),
+			semconv.RPCMethod(method),
+		),
+	)
+}
+
+// withFirstTokenEvent marks when the first chunk of a stream is sent
+func (t *Tracing) withFirstTokenEvent(ctx context.Context, span trace.Span) context.Context {
+	return middleware.WithFirstTokenReporter(ctx, func(model string) {
+		span.AddEvent("first token", trace.WithAttributes(attribute.String("model", model)))
+	})
+}
+
+func endGrpcSpan(span trace.Span, err error) {
+	code := status.Code(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	// as in otelgrpc, client errors are not errors of the server
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.ResourceExhausted, codes.FailedPrecondition,
+		codes.Aborted, codes.OutOfRange:
+	default:
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+}
+
+// metadataCarrier reads the trace context from grpc metadata
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
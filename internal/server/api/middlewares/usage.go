@@ -0,0 +1,223 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	apiv1 "github.com/xucx/llmapi/api/v1"
+	"github.com/xucx/llmapi/internal/ledger"
+	"github.com/xucx/llmapi/internal/server/api/middleware"
+	"github.com/xucx/llmapi/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrBudgetExceeded = errors.New("monthly budget exceeded")
+)
+
+type UsageOpts struct {
+	HttpEndpointGetter HttpEndpointGetter
+}
+
+type UsageOpt func(*UsageOpts)
+
+func UsageWithHttpEndpointGetter(getter HttpEndpointGetter) UsageOpt {
+	return func(opts *UsageOpts) {
+		opts.HttpEndpointGetter = getter
+	}
+}
+
+// Usage writes a ledger record of each chat request and keeps keys in their monthly budget
+type Usage struct {
+	middleware.NopMiddleware
+	opts   *UsageOpts
+	ledger *ledger.Ledger
+}
+
+func NewUsage(l *ledger.Ledger, opts ...UsageOpt) *Usage {
+	option := &UsageOpts{
+		HttpEndpointGetter: DefaultAuthHttpEndpointGetter,
+	}
+
+	for _, opt := range opts {
+		opt(option)
+	}
+
+	return &Usage{opts: option, ledger: l}
+}
+
+func (u *Usage) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (response interface{}, err error) {
+		model := chatParamsModel(req)
+		if model == "" {
+			return handler(ctx, req)
+		}
+
+		if err := u.checkBudget(ctx); err != nil {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+
+		rec := u.newRecorder(ctx, middleware.EndpointGrpc, model)
+		rsp, err := handler(rec.ctx, req)
+		rec.finish(err != nil, status.Code(err).String())
+		return rsp, err
+	}
+}
+
+func (u *Usage) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := u.checkBudget(stream.Context()); err != nil {
+			return status.Error(codes.ResourceExhausted, err.Error())
+		}
+
+		endpoint := middleware.EndpointGrpc
+		if info.FullMethod == apiv1.ApiService_ChatRealtime_FullMethodName {
+			endpoint = middleware.EndpointRealtime
+		}
+
+		// the model comes with the first message
+		rec := u.newRecorder(stream.Context(), endpoint, "")
+		err := handler(srv, &serverStream{
+			ServerStream: stream,
+			ctx:          rec.ctx,
+			onFirst: func(m any) error {
+				rec.setModel(chatParamsModel(m))
+				return nil
+			},
+		})
+		rec.finish(err != nil, status.Code(err).String())
+		return err
+	}
+}
+
+func (u *Usage) Http() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			model, _, err := httpChatRequest(c)
+			if err != nil {
+				return err
+			}
+			if model == "" {
+				return next(c)
+			}
+
+			if err := u.checkBudget(c.Request().Context()); err != nil {
+				return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
+			}
+
+			rec := u.newRecorder(c.Request().Context(), u.opts.HttpEndpointGetter(c), model)
+			c.SetRequest(c.Request().WithContext(rec.ctx))
+
+			err = next(c)
+
+			code := c.Response().Status
+			var httpErr *echo.HTTPError
+			if errors.As(err, &httpErr) {
+				code = httpErr.Code
+			} else if err != nil {
+				code = http.StatusInternalServerError
+			}
+			rec.finish(code >= http.StatusBadRequest, strconv.Itoa(code))
+			return err
+		}
+	}
+}
+
+func (u *Usage) checkBudget(ctx context.Context) error {
+	identity := middleware.IdentityFromContext(ctx)
+	if identity == nil || identity.MonthlyBudget <= 0 {
+		return nil
+	}
+
+	if u.ledger.MonthSpend(identity.Name, time.Now()) >= identity.MonthlyBudget {
+		return ErrBudgetExceeded
+	}
+	return nil
+}
+
+// usageRecorder records each completion reported by the handler, and one
+// error record when the request fails before any
+type usageRecorder struct {
+	ledger   *ledger.Ledger
+	ctx      context.Context
+	key      string
+	endpoint string
+
+	mu       sync.Mutex
+	model    string
+	last     time.Time
+	reported bool
+}
+
+func (u *Usage) newRecorder(ctx context.Context, endpoint, model string) *usageRecorder {
+	rec := &usageRecorder{
+		ledger:   u.ledger,
+		endpoint: endpoint,
+		model:    model,
+		last:     time.Now(),
+	}
+	if identity := middleware.IdentityFromContext(ctx); identity != nil {
+		rec.key = identity.Name
+	}
+	rec.ctx = middleware.WithUsageReporter(ctx, rec.report)
+	return rec
+}
+
+func (r *usageRecorder) setModel(model string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.model = model
+}
+
+// report is called for each completion, realtime sessions report many,
+// the latency of each is the time since the one before
+func (r *usageRecorder) report(model string, completion *types.Completion) {
+	r.mu.Lock()
+	now := time.Now()
+	latency := now.Sub(r.last)
+	r.last = now
+	r.reported = true
+	r.mu.Unlock()
+
+	r.ledger.Record(&ledger.Record{
+		Time:             now,
+		Key:              r.key,
+		Endpoint:         r.endpoint,
+		Model:            model,
+		Provider:         completion.Provider,
+		PromptTokens:     completion.Usage.PromptTokens,
+		CompletionTokens: completion.Usage.CompletionTokens,
+		CachedTokens:     completion.Usage.CachedTokens,
+		CacheWriteTokens: completion.Usage.CacheWriteTokens,
+		Cost:             completion.Usage.Cost,
+		LatencyMs:        latency.Milliseconds(),
+		Status:           ledger.StatusOk,
+	})
+}
+
+func (r *usageRecorder) finish(failed bool, code string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !failed || r.reported || r.model == "" {
+		return
+	}
+
+	now := time.Now()
+	r.ledger.Record(&ledger.Record{
+		Time:      now,
+		Key:       r.key,
+		Endpoint:  r.endpoint,
+		Model:     r.model,
+		LatencyMs: now.Sub(r.last).Milliseconds(),
+		Status:    ledger.StatusError,
+		Code:      code,
+	})
+}
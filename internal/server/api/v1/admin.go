@@ -0,0 +1,59 @@
+package v1
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xucx/llmapi/internal/ledger"
+)
+
+type AdminService struct {
+	ledger *ledger.Ledger
+}
+
+func NewAdminService(l *ledger.Ledger) *AdminService {
+	return &AdminService{
+		ledger: l,
+	}
+}
+
+type UsageReport struct {
+	From    string          `json:"from,omitempty"`
+	To      string          `json:"to,omitempty"`
+	GroupBy []string        `json:"groupBy"`
+	Data    []*ledger.Total `json:"data"`
+}
+
+// Usage aggregates the ledger, eg GET /usage?from=2025-01-01&to=2025-01-31&groupBy=key,model,day
+func (s *AdminService) Usage(c echo.Context) error {
+	report := &UsageReport{
+		From:    c.QueryParam("from"),
+		To:      c.QueryParam("to"),
+		GroupBy: []string{"key", "model"},
+	}
+	if groupBy := c.QueryParam("groupBy"); groupBy != "" {
+		report.GroupBy = strings.Split(groupBy, ",")
+	}
+
+	var from, to time.Time
+	var err error
+	if report.From != "" {
+		if from, err = time.Parse(time.DateOnly, report.From); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "from should be a date like 2006-01-02")
+		}
+	}
+	if report.To != "" {
+		if to, err = time.Parse(time.DateOnly, report.To); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "to should be a date like 2006-01-02")
+		}
+	}
+
+	report.Data, err = s.ledger.Totals(from, to, report.GroupBy)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
@@ -2,12 +2,18 @@ package v1
 
 import (
 	context "context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/labstack/echo/v4"
 
 	"github.com/xucx/llmapi"
 	apiprovider "github.com/xucx/llmapi/internal/providers/llmapi"
 
 	apiv1 "github.com/xucx/llmapi/api/v1"
+	"github.com/xucx/llmapi/internal/server/api/middleware"
 	"github.com/xucx/llmapi/log"
 	"github.com/xucx/llmapi/types"
 
@@ -31,6 +37,85 @@ func NewApiService(models *llmapi.Models) *ApiService {
 	}
 }
 
+// generate reports the first chunk, the usage of the completion and the error to the middlewares
+func (s *ApiService) generate(ctx context.Context, model string, messages []*types.Message, options ...types.ChatOption) (*types.Completion, error) {
+	var firstToken sync.Once
+	options = append(options, func(opts *types.ChatOptions) *types.ChatOptions {
+		if streamingFunc := opts.StreamingFunc; streamingFunc != nil {
+			opts.StreamingFunc = func(streamCtx context.Context, c *types.Completion) error {
+				firstToken.Do(func() { middleware.ReportFirstToken(ctx, model) })
+				return streamingFunc(streamCtx, c)
+			}
+		}
+		return opts
+	})
+
+	completion, err := s.models.Generate(ctx, model, messages, options...)
+	if err != nil {
+		middleware.ReportError(ctx, model, err)
+		return nil, err
+	}
+
+	middleware.ReportUsage(ctx, model, completion)
+	return completion, nil
+}
+
+// generateErrorStatus is the status of the errors the caller can fix, and of upstream errors by
+// their class, so a gateway in front of this one retries and falls back like on the provider itself
+func generateErrorStatus(err error) (codes.Code, int, bool) {
+	if errors.Is(err, llmapi.ErrContextOverflow) || errors.Is(err, llmapi.ErrMediaTooLarge) || errors.Is(err, llmapi.ErrCapability) {
+		return codes.InvalidArgument, http.StatusBadRequest, true
+	}
+
+	var upstreamErr *llmapi.UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		return codes.Internal, http.StatusInternalServerError, false
+	}
+
+	switch upstreamErr.Class {
+	case llmapi.ErrorClassRateLimit:
+		return codes.ResourceExhausted, http.StatusTooManyRequests, true
+	case llmapi.ErrorClassTimeout:
+		return codes.DeadlineExceeded, http.StatusGatewayTimeout, true
+	case llmapi.ErrorClassInvalid, llmapi.ErrorClassContextOverflow:
+		return codes.InvalidArgument, http.StatusBadRequest, true
+	default:
+		// the caller can not fix the credentials of the gateway nor an unknown upstream error
+		return codes.Unavailable, http.StatusBadGateway, true
+	}
+}
+
+// grpcGenerateError keeps the message of the errors with a status, other errors are internal
+func grpcGenerateError(err error) error {
+	code, _, ok := generateErrorStatus(err)
+	if !ok {
+		return GrpcInternalError
+	}
+	return status.Errorf(code, "%v", err)
+}
+
+// httpGenerateError is the status of generateErrorStatus, 500 for other errors
+func httpGenerateError(err error) *echo.HTTPError {
+	_, code, _ := generateErrorStatus(err)
+	return echo.NewHTTPError(code, err.Error())
+}
+
+// embed reports the usage and the error to the middlewares like generate
+func (s *ApiService) embed(ctx context.Context, model string, inputs []string, options ...types.EmbedOption) (*types.Embeddings, error) {
+	embeddings, err := s.models.Embed(ctx, model, inputs, options...)
+	if err != nil {
+		middleware.ReportError(ctx, model, err)
+		return nil, err
+	}
+
+	middleware.ReportUsage(ctx, model, &types.Completion{
+		Model:    embeddings.Model,
+		Usage:    embeddings.Usage,
+		Provider: embeddings.Provider,
+	})
+	return embeddings, nil
+}
+
 func (s *ApiService) Chat(ctx context.Context, req *apiv1.ChatRequest) (*apiv1.ChatResponse, error) {
 	if req.ChatParams == nil {
 		return nil, GrpcArgumentError
@@ -50,10 +135,10 @@ func (s *ApiService) Chat(ctx context.Context, req *apiv1.ChatRequest) (*apiv1.C
 		return nil, GrpcArgumentError
 	}
 
-	completion, err := s.models.Generate(ctx, req.ChatParams.Model, messages, types.ChatWithOptions(options))
+	completion, err := s.generate(ctx, req.ChatParams.Model, messages, types.ChatWithOptions(options))
 	if err != nil {
 		log.Errorw("llm chat fail", "model", req.ChatParams.Model, "error", err)
-		return nil, GrpcInternalError
+		return nil, grpcGenerateError(err)
 	}
 
 	retCompletion, err := apiprovider.FromChatCompletion(completion)
@@ -86,7 +171,7 @@ func (s *ApiService) ChatStream(req *apiv1.ChatStreamRequest, stream apiv1.ApiSe
 		return GrpcArgumentError
 	}
 
-	completion, err := s.models.Generate(stream.Context(), req.ChatParams.Model, messages, types.ChatWithOptions(options),
+	completion, err := s.generate(stream.Context(), req.ChatParams.Model, messages, types.ChatWithOptions(options),
 		types.ChatWithStreamingFunc(func(ctx context.Context, c *types.Completion) error {
 			log.Debugw("api recv chunck completeion", "completion", c)
 
@@ -104,7 +189,7 @@ func (s *ApiService) ChatStream(req *apiv1.ChatStreamRequest, stream apiv1.ApiSe
 
 	if err != nil {
 		log.Errorw("llm chat fail", "model", req.ChatParams.Model, "error", err)
-		return GrpcInternalError
+		return grpcGenerateError(err)
 	}
 
 	log.Debugw("api recv completeion", "completion", completion)
@@ -125,10 +210,15 @@ func (s *ApiService) ChatRealtime(stream apiv1.ApiService_ChatRealtimeServer) er
 	ctx, cancel := context.WithCancel(stream.Context())
 	defer cancel()
 
-	session, err := s.initRealtime(stream)
+	session, model, err := s.initRealtime(stream)
 	if err != nil {
+		// keep the status of middlewares, eg. rate limit
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
 		return GrpcInternalError
 	}
+	defer session.Close()
 
 	go func() {
 		defer cancel()
@@ -139,6 +229,10 @@ func (s *ApiService) ChatRealtime(stream apiv1.ApiService_ChatRealtimeServer) er
 				return
 			}
 
+			if req.Message == nil {
+				continue
+			}
+
 			message, err := apiprovider.ToMessage(req.Message)
 			if err != nil {
 				return
@@ -156,6 +250,10 @@ func (s *ApiService) ChatRealtime(stream apiv1.ApiService_ChatRealtimeServer) er
 			break
 		}
 
+		if !rsp.Delta {
+			middleware.ReportUsage(ctx, model, rsp)
+		}
+
 		completion, err := apiprovider.FromChatCompletion(rsp)
 		if err != nil {
 			return err
@@ -171,23 +269,23 @@ func (s *ApiService) ChatRealtime(stream apiv1.ApiService_ChatRealtimeServer) er
 	return nil
 }
 
-func (s *ApiService) initRealtime(stream apiv1.ApiService_ChatRealtimeServer) (types.RealTimeSession, error) {
+func (s *ApiService) initRealtime(stream apiv1.ApiService_ChatRealtimeServer) (types.RealTimeSession, string, error) {
 	ctx := stream.Context()
 
 	req, err := stream.Recv()
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	if req.Init == nil {
-		return nil, fmt.Errorf("no init message")
+		return nil, "", fmt.Errorf("no init message")
 	}
 
 	messages := []*types.Message{}
 	for _, m := range req.Init.ChatParams.Messages {
 		msg, err := apiprovider.ToMessage(m)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		messages = append(messages, msg)
 	}
@@ -203,22 +301,74 @@ func (s *ApiService) initRealtime(stream apiv1.ApiService_ChatRealtimeServer) (t
 	if req.Init.ChatParams.Tools != nil {
 		tools, err := apiprovider.ToChatTools(req.Init.ChatParams.Tools)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		options = append(options, types.RealTimeWithTools(tools))
 	}
 	if req.Init.ChatParams.Voice != "" {
 		voice, err := apiprovider.ToChatVoice(req.Init.ChatParams.Voice)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		options = append(options, types.RealTimeWithAudioVoice(voice))
 	}
 
 	session, err := s.models.Realtime(ctx, req.Init.ChatParams.Model, messages, options...)
 	if err != nil {
-		return nil, err
+		middleware.ReportError(ctx, req.Init.ChatParams.Model, err)
+		return nil, "", err
+	}
+
+	return session, req.Init.ChatParams.Model, nil
+}
+
+func (s *ApiService) Embed(ctx context.Context, req *apiv1.EmbedRequest) (*apiv1.EmbedResponse, error) {
+	if len(req.Inputs) == 0 {
+		return nil, GrpcArgumentError
 	}
 
-	return session, nil
+	options := []types.EmbedOption{}
+	if req.Dimensions != nil {
+		options = append(options, types.EmbedWithDimensions(*req.Dimensions))
+	}
+
+	embeddings, err := s.embed(ctx, req.Model, req.Inputs, options...)
+	if err != nil {
+		log.Errorw("llm embed fail", "model", req.Model, "error", err)
+		return nil, grpcGenerateError(err)
+	}
+
+	return apiprovider.FromEmbeddings(embeddings), nil
+}
+
+func (s *ApiService) CountTokens(ctx context.Context, req *apiv1.CountTokensRequest) (*apiv1.CountTokensResponse, error) {
+	if req.ChatParams == nil {
+		return nil, GrpcArgumentError
+	}
+
+	messages := []*types.Message{}
+	for _, m := range req.ChatParams.Messages {
+		msg, err := apiprovider.ToMessage(m)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+
+	options, err := apiprovider.ChatParamsToOptions(req.ChatParams)
+	if err != nil {
+		return nil, GrpcArgumentError
+	}
+
+	count, err := s.models.CountTokens(ctx, req.ChatParams.Model, messages, types.ChatWithOptions(options))
+	if err != nil {
+		log.Errorw("llm count tokens fail", "model", req.ChatParams.Model, "error", err)
+		return nil, grpcGenerateError(err)
+	}
+
+	return &apiv1.CountTokensResponse{
+		Model:       count.Model,
+		InputTokens: count.InputTokens,
+		Estimated:   count.Estimated,
+	}, nil
 }
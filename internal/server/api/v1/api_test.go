@@ -0,0 +1,80 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/xucx/llmapi"
+	"github.com/xucx/llmapi/internal/utils"
+	"github.com/xucx/llmapi/types"
+
+	codes "google.golang.org/grpc/codes"
+)
+
+func TestGenerateErrorStatus(t *testing.T) {
+	upstream := func(class llmapi.ErrorClass) error {
+		return &llmapi.UpstreamError{Model: "m", Provider: "p", Class: class, Err: errors.New("upstream")}
+	}
+
+	cases := []struct {
+		name   string
+		err    error
+		code   codes.Code
+		status int
+		ok     bool
+	}{
+		{"context overflow", fmt.Errorf("preflight: %w", llmapi.ErrContextOverflow), codes.InvalidArgument, http.StatusBadRequest, true},
+		{"capability", fmt.Errorf("x: %w", llmapi.ErrCapability), codes.InvalidArgument, http.StatusBadRequest, true},
+		{"rate limit", upstream(llmapi.ErrorClassRateLimit), codes.ResourceExhausted, http.StatusTooManyRequests, true},
+		{"timeout", upstream(llmapi.ErrorClassTimeout), codes.DeadlineExceeded, http.StatusGatewayTimeout, true},
+		{"server", upstream(llmapi.ErrorClassServer), codes.Unavailable, http.StatusBadGateway, true},
+		{"network", upstream(llmapi.ErrorClassNetwork), codes.Unavailable, http.StatusBadGateway, true},
+		{"invalid", upstream(llmapi.ErrorClassInvalid), codes.InvalidArgument, http.StatusBadRequest, true},
+		{"upstream context overflow", upstream(llmapi.ErrorClassContextOverflow), codes.InvalidArgument, http.StatusBadRequest, true},
+		// the caller can not fix the credentials of the gateway
+		{"auth", upstream(llmapi.ErrorClassAuth), codes.Unavailable, http.StatusBadGateway, true},
+		{"other", upstream(llmapi.ErrorClassOther), codes.Unavailable, http.StatusBadGateway, true},
+		{"internal", errors.New("bug"), codes.Internal, http.StatusInternalServerError, false},
+	}
+
+	for _, c := range cases {
+		code, status, ok := generateErrorStatus(c.err)
+		if code != c.code || status != c.status || ok != c.ok {
+			t.Errorf("%s: got %v %d %v, want %v %d %v", c.name, code, status, ok, c.code, c.status, c.ok)
+		}
+	}
+}
+
+func TestToOpenaiFinishReason(t *testing.T) {
+	toolCall := &types.Message{Role: types.MessageRoleAssistant, Parts: []*types.MessagePart{
+		{ToolCall: &types.MessageToolCall{ID: "call-1", Type: types.ToolTypeFunction, Function: &types.ToolCallFunction{Name: "f", Arguments: "{}"}}},
+	}}
+	text := types.NewTextMessage(types.MessageRoleAssistant, "hi")
+
+	cases := []struct {
+		completion *types.Completion
+		reason     *string
+	}{
+		{&types.Completion{Message: text, FinishReason: types.FinishReasonStop}, utils.Ptr("stop")},
+		{&types.Completion{Message: text, FinishReason: types.FinishReasonLength}, utils.Ptr("length")},
+		{&types.Completion{Message: text, FinishReason: types.FinishReasonContentFilter}, utils.Ptr("content_filter")},
+		{&types.Completion{Message: toolCall}, utils.Ptr("tool_calls")},
+		{&types.Completion{Message: text}, utils.Ptr("stop")},
+		// chunks have no finish reason until the last one
+		{&types.Completion{Delta: true, Message: text}, nil},
+		{&types.Completion{Delta: true, Message: text, FinishReason: types.FinishReasonLength}, utils.Ptr("length")},
+	}
+
+	for i, c := range cases {
+		resp, err := toOpenaiCompletionResponse(c.completion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := resp.Choices[0].FinishReason
+		if (got == nil) != (c.reason == nil) || (got != nil && *got != *c.reason) {
+			t.Errorf("case %d: finish reason %v, want %v", i, got, c.reason)
+		}
+	}
+}
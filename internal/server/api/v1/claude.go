@@ -2,16 +2,23 @@ package v1
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/xucx/llmapi"
+	"github.com/xucx/llmapi/internal/providers/provider"
+	"github.com/xucx/llmapi/internal/utils"
 	"github.com/xucx/llmapi/log"
 	"github.com/xucx/llmapi/types"
 )
 
+// decoded size limit of the images and documents of a request
+const ClaudeMaxMediaSize = 32 << 20
+
 // See https://docs.anthropic.com/en/api/messages
 type ClaudeMessageRequest struct {
 	Model         string            `json:"model"`
@@ -35,20 +42,30 @@ type ClaudeMessage struct {
 }
 
 type ClaudeContent struct {
-	Type      string             `json:"type"`
-	Text      string             `json:"text,omitempty"`
-	Source    *ClaudeImageSource `json:"source,omitempty"`
-	ID        string             `json:"id,omitempty"`
-	Name      string             `json:"name,omitempty"`
-	Input     interface{}        `json:"input,omitempty"`
-	ToolUseID string             `json:"tool_use_id,omitempty"`
-	Content   interface{}        `json:"content,omitempty"` // For tool_result
+	Type         string              `json:"type"`
+	Text         string              `json:"text,omitempty"`
+	Thinking     string              `json:"thinking,omitempty"`
+	Signature    string              `json:"signature,omitempty"`
+	Source       *ClaudeImageSource  `json:"source,omitempty"`
+	ID           string              `json:"id,omitempty"`
+	Name         string              `json:"name,omitempty"`
+	Input        interface{}         `json:"input,omitempty"`
+	ToolUseID    string              `json:"tool_use_id,omitempty"`
+	Content      interface{}         `json:"content,omitempty"` // For tool_result
+	CacheControl *ClaudeCacheControl `json:"cache_control,omitempty"`
+}
+
+type ClaudeCacheControl struct {
+	Type string `json:"type"`          // ephemeral
+	TTL  string `json:"ttl,omitempty"` // 5m or 1h
 }
 
+// ClaudeImageSource is the source of image and document blocks
 type ClaudeImageSource struct {
-	Type      string `json:"type"`
-	MediaType string `json:"media_type"`
-	Data      string `json:"data"`
+	Type      string `json:"type"` // base64, url or text
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
 }
 
 type ClaudeMetadata struct {
@@ -56,9 +73,10 @@ type ClaudeMetadata struct {
 }
 
 type ClaudeTool struct {
-	Name        string                 `json:"name"`
-	Description string                 `json:"description,omitempty"`
-	InputSchema map[string]interface{} `json:"input_schema"`
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description,omitempty"`
+	InputSchema  map[string]interface{} `json:"input_schema"`
+	CacheControl *ClaudeCacheControl    `json:"cache_control,omitempty"`
 }
 
 type ClaudeToolChoice struct {
@@ -83,23 +101,36 @@ type ClaudeMessageResponse struct {
 	Usage        ClaudeUsage     `json:"usage"`
 }
 
+type ClaudeTokensCount struct {
+	InputTokens int64 `json:"input_tokens"`
+}
+
+// ClaudeUsage counts cached tokens apart, input_tokens are the ones neither read from nor written to the cache
 type ClaudeUsage struct {
-	InputTokens  int64 `json:"input_tokens"`
-	OutputTokens int64 `json:"output_tokens"`
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
 }
 
 // Streaming events
 type ClaudeStreamEvent struct {
 	Type         string                 `json:"type"`
 	Message      *ClaudeMessageResponse `json:"message,omitempty"`
-	Index        int                    `json:"index,omitempty"`
-	ContentBlock *ClaudeContent         `json:"content_block,omitempty"`
+	Index        *int                   `json:"index,omitempty"`
+	ContentBlock map[string]any         `json:"content_block,omitempty"` // keeps empty text, thinking and input fields
 	Delta        *ClaudeDelta           `json:"delta,omitempty"`
 	Usage        *ClaudeUsage           `json:"usage,omitempty"`
+	Error        *ClaudeError           `json:"error,omitempty"`
+}
+
+type ClaudeError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
 }
 
 type ClaudeDelta struct {
-	Type         string  `json:"type"`
+	Type         string  `json:"type,omitempty"`
 	Text         string  `json:"text,omitempty"`
 	Thinking     string  `json:"thinking,omitempty"`
 	Signature    string  `json:"signature,omitempty"`
@@ -114,8 +145,66 @@ func (s *ApiService) ClaudeCreateMessage(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	messages, options, err := fromClaudeRequest(req)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
 	ctx := c.Request().Context()
 
+	// Generate
+	if req.Stream {
+		stream := newClaudeStream(c, req.Model)
+		options = append(options, types.ChatWithStreamingFunc(func(ctx context.Context, completion *types.Completion) error {
+			return stream.add(completion)
+		}))
+
+		completion, err := s.generate(ctx, req.Model, messages, options...)
+		if err != nil {
+			log.Errorw("llm chat fail", "model", req.Model, "error", err)
+			return stream.fail(err)
+		}
+
+		return stream.finish(completion)
+
+	} else {
+		completion, err := s.generate(ctx, req.Model, messages, options...)
+		if err != nil {
+			log.Errorw("llm chat fail", "model", req.Model, "error", err)
+			return httpGenerateError(err)
+		}
+
+		resp, err := toClaudeMessageResponse(completion)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// ClaudeCountTokens is the count_tokens endpoint, see https://docs.anthropic.com/en/api/messages-count-tokens
+func (s *ApiService) ClaudeCountTokens(c echo.Context) error {
+	req := &ClaudeMessageRequest{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	messages, options, err := fromClaudeRequest(req)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	count, err := s.models.CountTokens(c.Request().Context(), req.Model, messages, options...)
+	if err != nil {
+		log.Errorw("llm count tokens fail", "model", req.Model, "error", err)
+		return httpGenerateError(err)
+	}
+
+	return c.JSON(http.StatusOK, &ClaudeTokensCount{InputTokens: count.InputTokens})
+}
+
+func fromClaudeRequest(req *ClaudeMessageRequest) ([]*types.Message, []types.ChatOption, error) {
 	// Convert messages
 	messages := []*types.Message{}
 
@@ -130,7 +219,10 @@ func (s *ApiService) ClaudeCreateMessage(c echo.Context) error {
 				if itemMap, ok := item.(map[string]interface{}); ok {
 					if typeVal, ok := itemMap["type"].(string); ok && typeVal == "text" {
 						if text, ok := itemMap["text"].(string); ok {
-							sysMsg.Parts = append(sysMsg.Parts, &types.MessagePart{Text: &types.MessageText{Text: text}})
+							sysMsg.Parts = append(sysMsg.Parts, &types.MessagePart{
+								Text:         &types.MessageText{Text: text},
+								CacheControl: fromClaudeCacheControl(itemMap),
+							})
 						}
 					}
 				}
@@ -142,7 +234,7 @@ func (s *ApiService) ClaudeCreateMessage(c echo.Context) error {
 	for _, m := range req.Messages {
 		msg, err := fromClaudeMessage(m)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			return nil, nil, err
 		}
 		messages = append(messages, msg)
 	}
@@ -156,143 +248,51 @@ func (s *ApiService) ClaudeCreateMessage(c echo.Context) error {
 	if len(req.Tools) > 0 {
 		tools, err := fromClaudeTools(req.Tools)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			return nil, nil, err
 		}
 		options = append(options, types.ChatWithTools(tools))
 	}
 
-	// Temperature
 	if req.Temperature != nil {
-		options = append(options, func(opts *types.ChatOptions) *types.ChatOptions {
-			opts.Temperature = req.Temperature
-			return opts
-		})
+		options = append(options, types.ChatWithTemperature(*req.Temperature))
 	}
 
-	// MaxTokens
 	if req.MaxTokens > 0 {
-		options = append(options, func(opts *types.ChatOptions) *types.ChatOptions {
-			opts.MaxTokens = &req.MaxTokens
-			return opts
-		})
+		options = append(options, types.ChatWithMaxTokens(req.MaxTokens))
 	}
 
-	// TopP
 	if req.TopP != nil {
-		options = append(options, func(opts *types.ChatOptions) *types.ChatOptions {
-			opts.TopP = req.TopP
-			return opts
-		})
+		options = append(options, types.ChatWithTopP(*req.TopP))
 	}
 
-	// TopK
 	if req.TopK != nil {
-		options = append(options, func(opts *types.ChatOptions) *types.ChatOptions {
-			opts.TopK = req.TopK
-			return opts
-		})
+		options = append(options, types.ChatWithTopK(*req.TopK))
 	}
 
-	// StopSequences
 	if len(req.StopSequences) > 0 {
-		options = append(options, func(opts *types.ChatOptions) *types.ChatOptions {
-			opts.StopSequences = req.StopSequences
-			return opts
-		})
+		options = append(options, types.ChatWithStopSequences(req.StopSequences))
 	}
 
-	// Generate
-	if req.Stream {
-		c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
-		c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
-		c.Response().Header().Set(echo.HeaderConnection, "keep-alive")
-		c.Response().WriteHeader(http.StatusOK)
-
-		options = append(options, types.ChatWithStreamingFunc(func(ctx context.Context, completion *types.Completion) error {
-			events, err := toClaudeStreamEvents(completion)
-			if err != nil {
-				return err
-			}
-
-			for _, event := range events {
-				eventData, err := json.Marshal(event)
-				if err != nil {
-					return err
-				}
-				fmt.Fprintf(c.Response(), "event: %s\ndata: %s\n\n", event.Type, eventData)
-			}
-			c.Response().Flush()
-			return nil
+	if req.Thinking != nil {
+		options = append(options, types.ChatWithReasoning(&types.Reasoning{
+			Enabled:         req.Thinking.Type == "enabled",
+			BudgetTokens:    int64(req.Thinking.BudgetTokens),
+			IncludeThoughts: true,
 		}))
+	}
 
-		// Send message_start event first (simplified)
-		// Since we don't have the initial response structure available before Generate returns,
-		// we might need to send a dummy message_start or wait for first chunk if it contained metadata.
-		// For now, we rely on content_block_delta being acceptable by some clients,
-		// but standard Claude stream starts with message_start.
-
-		msgID := "msg_" + uuid.NewString()
-		msgStart := ClaudeStreamEvent{
-			Type: "message_start",
-			Message: &ClaudeMessageResponse{
-				ID:      msgID,
-				Type:    "message",
-				Role:    "assistant",
-				Model:   req.Model,
-				Content: []ClaudeContent{},
-				Usage:   ClaudeUsage{InputTokens: 0, OutputTokens: 0}, // Placeholder
-			},
-		}
-
-		if startData, err := json.Marshal(msgStart); err == nil {
-			fmt.Fprintf(c.Response(), "event: message_start\ndata: %s\n\n", startData)
-
-			// Also send content_block_start for text
-			blockStart := ClaudeStreamEvent{
-				Type:  "content_block_start",
-				Index: 0,
-				ContentBlock: &ClaudeContent{
-					Type: "text",
-					Text: "",
-				},
-			}
-			if blockData, err := json.Marshal(blockStart); err == nil {
-				fmt.Fprintf(c.Response(), "event: content_block_start\ndata: %s\n\n", blockData)
-			}
-			c.Response().Flush()
-		}
-
-		_, err := s.models.Generate(ctx, req.Model, messages, options...)
-		if err != nil {
-			log.Errorw("llm chat fail", "model", req.Model, "error", err)
-			return nil
-		}
-
-		// Send message_stop event at the end
-		msgStop := ClaudeStreamEvent{
-			Type: "message_stop",
-		}
-		if stopData, err := json.Marshal(msgStop); err == nil {
-			fmt.Fprintf(c.Response(), "event: message_stop\ndata: %s\n\n", stopData)
-			c.Response().Flush()
-		}
-
-		return nil
-
-	} else {
-		completion, err := s.models.Generate(ctx, req.Model, messages, options...)
+	if req.ToolChoice != nil {
+		choice, err := fromClaudeToolChoice(req.ToolChoice)
 		if err != nil {
-			log.Errorw("llm chat fail", "model", req.Model, "error", err)
-			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			return nil, nil, err
 		}
-
-		resp, err := toClaudeMessageResponse(completion)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		options = append(options, types.ChatWithToolChoice(choice))
+		if req.ToolChoice.DisableParallelToolUse != nil {
+			options = append(options, types.ChatWithParallelToolCalls(!*req.ToolChoice.DisableParallelToolUse))
 		}
-
-		return c.JSON(http.StatusOK, resp)
 	}
+
+	return messages, options, nil
 }
 
 func fromClaudeMessage(m ClaudeMessage) (*types.Message, error) {
@@ -316,21 +316,33 @@ func fromClaudeMessage(m ClaudeMessage) (*types.Message, error) {
 		case []interface{}:
 			for _, item := range c {
 				if itemMap, ok := item.(map[string]interface{}); ok {
+					parts := len(msg.Parts)
 					itemType, _ := itemMap["type"].(string)
 					switch itemType {
 					case "text":
 						if text, ok := itemMap["text"].(string); ok {
 							msg.Parts = append(msg.Parts, &types.MessagePart{Text: &types.MessageText{Text: text}})
 						}
+					case "thinking":
+						// the signature goes back to anthropic with the thinking
+						thinking, _ := itemMap["thinking"].(string)
+						signature, _ := itemMap["signature"].(string)
+						msg.Parts = append(msg.Parts, &types.MessagePart{Reasoning: &types.MessageReasoning{
+							Text:             thinking,
+							ThoughtSignature: signature,
+						}})
 					case "image":
-						if sourceMap, ok := itemMap["source"].(map[string]interface{}); ok {
-							// Check if it's base64
-							if srcType, ok := sourceMap["type"].(string); ok && srcType == "base64" {
-								// We don't have a direct MessageImageBase64 type yet in types.go based on read file
-								// Mapping to MessageImageURL as placeholder or if supported
-								// TODO: Support base64 image if needed, for now ignoring or assuming URL structure if exists
-							}
+						image, err := fromClaudeImage(itemMap)
+						if err != nil {
+							return nil, err
 						}
+						msg.Parts = append(msg.Parts, &types.MessagePart{ImageURL: image})
+					case "document":
+						file, err := fromClaudeDocument(itemMap)
+						if err != nil {
+							return nil, err
+						}
+						msg.Parts = append(msg.Parts, &types.MessagePart{File: file})
 					case "tool_use":
 						id, _ := itemMap["id"].(string)
 						name, _ := itemMap["name"].(string)
@@ -363,6 +375,10 @@ func fromClaudeMessage(m ClaudeMessage) (*types.Message, error) {
 							Result: contentVal,
 						}})
 					}
+
+					if len(msg.Parts) > parts {
+						msg.Parts[len(msg.Parts)-1].CacheControl = fromClaudeCacheControl(itemMap)
+					}
 				}
 			}
 		}
@@ -371,6 +387,87 @@ func fromClaudeMessage(m ClaudeMessage) (*types.Message, error) {
 	return msg, nil
 }
 
+func fromClaudeSource(itemMap map[string]interface{}) (*ClaudeImageSource, error) {
+	source := &ClaudeImageSource{}
+	b, _ := json.Marshal(itemMap["source"])
+	if err := json.Unmarshal(b, source); err != nil || source.Type == "" {
+		return nil, fmt.Errorf("%s block needs a source", itemMap["type"])
+	}
+	return source, nil
+}
+
+// fromClaudeImage is a base64 image as a data url with the type of its bytes
+func fromClaudeImage(itemMap map[string]interface{}) (*types.MessageImageURL, error) {
+	source, err := fromClaudeSource(itemMap)
+	if err != nil {
+		return nil, err
+	}
+
+	switch source.Type {
+	case "base64":
+		data, err := provider.DecodeMedia(source.Data, ClaudeMaxMediaSize)
+		if err != nil {
+			return nil, fmt.Errorf("image: %w", err)
+		}
+		return types.NewImageDataURL(provider.SniffMIMEType(source.MediaType, data), base64.StdEncoding.EncodeToString(data)), nil
+	case "url":
+		return &types.MessageImageURL{URL: source.URL}, nil
+	default:
+		return nil, fmt.Errorf("image source %s not support", source.Type)
+	}
+}
+
+// fromClaudeDocument is a base64 or plain text document as a file
+func fromClaudeDocument(itemMap map[string]interface{}) (*types.MessageFile, error) {
+	source, err := fromClaudeSource(itemMap)
+	if err != nil {
+		return nil, err
+	}
+
+	title, _ := itemMap["title"].(string)
+	switch source.Type {
+	case "base64":
+		data, err := provider.DecodeMedia(source.Data, ClaudeMaxMediaSize)
+		if err != nil {
+			return nil, fmt.Errorf("document: %w", err)
+		}
+		return &types.MessageFile{
+			MIMEType: provider.SniffMIMEType(source.MediaType, data),
+			Name:     title,
+			Data:     base64.StdEncoding.EncodeToString(data),
+		}, nil
+	case "text":
+		if len(source.Data) > ClaudeMaxMediaSize {
+			return nil, fmt.Errorf("document: %w: %d bytes, limit %d", llmapi.ErrMediaTooLarge, len(source.Data), ClaudeMaxMediaSize)
+		}
+		return &types.MessageFile{
+			MIMEType: "text/plain",
+			Name:     title,
+			Data:     base64.StdEncoding.EncodeToString([]byte(source.Data)),
+		}, nil
+	default:
+		return nil, fmt.Errorf("document source %s not support", source.Type)
+	}
+}
+
+func fromClaudeToolChoice(choice *ClaudeToolChoice) (*types.ToolChoice, error) {
+	switch choice.Type {
+	case "auto":
+		return &types.ToolChoice{Type: types.ToolChoiceAuto}, nil
+	case "none":
+		return &types.ToolChoice{Type: types.ToolChoiceNone}, nil
+	case "any":
+		return &types.ToolChoice{Type: types.ToolChoiceRequired}, nil
+	case "tool":
+		if choice.Name == "" {
+			return nil, fmt.Errorf("tool_choice tool needs a name")
+		}
+		return types.NewFunctionToolChoice(choice.Name), nil
+	default:
+		return nil, fmt.Errorf("tool_choice %s not support", choice.Type)
+	}
+}
+
 func fromClaudeTools(tools []ClaudeTool) ([]*types.Tool, error) {
 	ts := []*types.Tool{}
 	for _, t := range tools {
@@ -381,11 +478,38 @@ func fromClaudeTools(tools []ClaudeTool) ([]*types.Tool, error) {
 				Description: t.Description,
 				Parameters:  t.InputSchema,
 			},
+			CacheControl: toCacheControl(t.CacheControl),
 		})
 	}
 	return ts, nil
 }
 
+// fromClaudeCacheControl reads the cache_control of a content block
+func fromClaudeCacheControl(itemMap map[string]interface{}) *types.CacheControl {
+	cacheControl, ok := itemMap["cache_control"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	ttl, _ := cacheControl["ttl"].(string)
+	return &types.CacheControl{TTL: ttl}
+}
+
+func toCacheControl(cacheControl *ClaudeCacheControl) *types.CacheControl {
+	if cacheControl == nil {
+		return nil
+	}
+	return &types.CacheControl{TTL: cacheControl.TTL}
+}
+
+func toClaudeUsage(usage types.CompletionUsage) ClaudeUsage {
+	return ClaudeUsage{
+		InputTokens:              usage.PromptTokens - usage.CachedTokens - usage.CacheWriteTokens,
+		OutputTokens:             usage.CompletionTokens,
+		CacheCreationInputTokens: usage.CacheWriteTokens,
+		CacheReadInputTokens:     usage.CachedTokens,
+	}
+}
+
 func toClaudeMessageResponse(c *types.Completion) (*ClaudeMessageResponse, error) {
 	resp := &ClaudeMessageResponse{
 		ID:      c.Message.ID,
@@ -393,10 +517,7 @@ func toClaudeMessageResponse(c *types.Completion) (*ClaudeMessageResponse, error
 		Role:    string(c.Message.Role),
 		Model:   c.Model,
 		Content: []ClaudeContent{},
-		Usage: ClaudeUsage{
-			InputTokens:  c.Usage.PromptTokens,
-			OutputTokens: c.Usage.CompletionTokens,
-		},
+		Usage:   toClaudeUsage(c.Usage),
 	}
 
 	// Ensure ID starts with msg_
@@ -407,6 +528,16 @@ func toClaudeMessageResponse(c *types.Completion) (*ClaudeMessageResponse, error
 	}
 
 	// Content
+	for _, part := range c.Message.Parts {
+		if part.Reasoning != nil {
+			resp.Content = append(resp.Content, ClaudeContent{
+				Type:      "thinking",
+				Thinking:  part.Reasoning.Text,
+				Signature: part.Reasoning.ThoughtSignature,
+			})
+		}
+	}
+
 	text := c.Message.Text()
 	if text != "" {
 		resp.Content = append(resp.Content, ClaudeContent{
@@ -429,41 +560,285 @@ func toClaudeMessageResponse(c *types.Completion) (*ClaudeMessageResponse, error
 		})
 	}
 
-	stopReason := "end_turn"
-	if len(toolCalls) > 0 {
-		stopReason = "tool_use"
-	}
+	stopReason := toClaudeStopReason(c)
 	resp.StopReason = &stopReason
 
 	return resp, nil
 }
 
-func toClaudeStreamEvents(c *types.Completion) ([]*ClaudeStreamEvent, error) {
-	events := []*ClaudeStreamEvent{}
-
-	if c.Delta {
-		// 检查是否有文本增量
-		text := c.Message.Text()
-		if text != "" {
-			events = append(events, &ClaudeStreamEvent{
-				Type:  "content_block_delta",
-				Index: 0,
-				Delta: &ClaudeDelta{
-					Type: "text_delta",
-					Text: text,
-				},
-			})
+func toClaudeStopReason(c *types.Completion) string {
+	switch c.FinishReason {
+	case types.FinishReasonLength:
+		return "max_tokens"
+	case types.FinishReasonContentFilter:
+		return "refusal"
+	case types.FinishReasonToolCalls:
+		return "tool_use"
+	}
+
+	if c.Message != nil && len(c.Message.ToolCalls()) > 0 {
+		return "tool_use"
+	}
+	return "end_turn"
+}
+
+// claudeStream turns completion deltas into claude stream events, see
+// https://docs.anthropic.com/en/docs/build-with-claude/streaming
+//
+// Every thinking, text and tool_use segment gets its own content block, a block
+// is closed as soon as a delta of another segment arrives. Nothing is written
+// before the first delta, so a request failing early still gets a plain http error.
+type claudeStream struct {
+	c       echo.Context
+	id      string
+	model   string
+	started bool
+	index   int
+	block   string          // type of the open content block, empty if none
+	toolID  string          // id of the open tool_use block
+	toolIDs map[string]bool // tool calls already streamed
+	usage   types.CompletionUsage
+}
+
+func newClaudeStream(c echo.Context, model string) *claudeStream {
+	return &claudeStream{
+		c:       c,
+		id:      "msg_" + uuid.NewString(),
+		model:   model,
+		toolIDs: map[string]bool{},
+	}
+}
+
+func (s *claudeStream) add(completion *types.Completion) error {
+	if err := s.start(); err != nil {
+		return err
+	}
+
+	if completion.Message != nil {
+		for _, part := range completion.Message.Parts {
+			var err error
+			switch {
+			case part.Reasoning != nil:
+				err = s.addReasoning(part.Reasoning)
+			case part.Text != nil:
+				err = s.addText(part.Text)
+			case part.ToolCall != nil:
+				err = s.addToolCall(part.ToolCall)
+			}
+			if err != nil {
+				return err
+			}
 		}
+	}
+
+	if completion.Usage.TotalTokens > 0 {
+		s.usage = completion.Usage
+	}
+
+	s.c.Response().Flush()
+	return nil
+}
+
+func (s *claudeStream) finish(completion *types.Completion) error {
+	if err := s.start(); err != nil {
+		return err
+	}
+
+	// some providers only report tool calls in the final completion
+	if completion.Message != nil {
+		for _, toolCall := range completion.Message.ToolCalls() {
+			if !s.toolIDs[toolCall.ID] {
+				if err := s.addToolCall(toolCall); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := s.closeBlock(); err != nil {
+		return err
+	}
+
+	usage := completion.Usage
+	if usage.TotalTokens == 0 {
+		usage = s.usage
+	}
+
+	stopReason := toClaudeStopReason(completion)
+	if err := s.write(&ClaudeStreamEvent{
+		Type: "message_delta",
+		Delta: &ClaudeDelta{
+			StopReason: &stopReason,
+		},
+		Usage: utils.Ptr(toClaudeUsage(usage)),
+	}); err != nil {
+		return err
+	}
+
+	if err := s.write(&ClaudeStreamEvent{Type: "message_stop"}); err != nil {
+		return err
+	}
+
+	s.c.Response().Flush()
+	return nil
+}
 
-		// 检查工具调用增量（需要额外支持，目前types.Message的ToolCalls可能不是增量的）
-		// 如果需要完整支持Claude流式协议，这里需要更复杂的状态机来生成:
-		// message_start, content_block_start, content_block_delta, content_block_stop, message_delta, message_stop
+func (s *claudeStream) fail(err error) error {
+	if !s.started {
+		return httpGenerateError(err)
+	}
+
+	if err := s.write(&ClaudeStreamEvent{
+		Type: "error",
+		Error: &ClaudeError{
+			Type:    "api_error",
+			Message: err.Error(),
+		},
+	}); err != nil {
+		return err
+	}
+
+	s.c.Response().Flush()
+	return nil
+}
+
+func (s *claudeStream) start() error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+
+	s.c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+	s.c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
+	s.c.Response().Header().Set(echo.HeaderConnection, "keep-alive")
+	s.c.Response().WriteHeader(http.StatusOK)
+
+	return s.write(&ClaudeStreamEvent{
+		Type: "message_start",
+		Message: &ClaudeMessageResponse{
+			ID:      s.id,
+			Type:    "message",
+			Role:    "assistant",
+			Model:   s.model,
+			Content: []ClaudeContent{},
+		},
+	})
+}
+
+func (s *claudeStream) addReasoning(reasoning *types.MessageReasoning) error {
+	if reasoning.Text == "" && reasoning.ThoughtSignature == "" {
+		return nil
+	}
+
+	if s.block != "thinking" {
+		if err := s.openBlock("thinking", map[string]any{"type": "thinking", "thinking": ""}); err != nil {
+			return err
+		}
+	}
+
+	if reasoning.Text != "" {
+		if err := s.delta(&ClaudeDelta{Type: "thinking_delta", Thinking: reasoning.Text}); err != nil {
+			return err
+		}
+	}
+
+	if reasoning.ThoughtSignature != "" {
+		if err := s.delta(&ClaudeDelta{Type: "signature_delta", Signature: reasoning.ThoughtSignature}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *claudeStream) addText(text *types.MessageText) error {
+	if text.Text == "" {
+		return nil
+	}
+
+	if s.block != "text" {
+		if err := s.openBlock("text", map[string]any{"type": "text", "text": ""}); err != nil {
+			return err
+		}
+	}
+
+	return s.delta(&ClaudeDelta{Type: "text_delta", Text: text.Text})
+}
+
+// addToolCall opens a new tool_use block for every new tool call id, deltas without
+// id are argument fragments of the open tool_use block
+func (s *claudeStream) addToolCall(toolCall *types.MessageToolCall) error {
+	if toolCall.Function == nil {
+		return nil
+	}
+
+	if toolCall.ID != "" && (s.block != "tool_use" || toolCall.ID != s.toolID) {
+		if err := s.openBlock("tool_use", map[string]any{
+			"type":  "tool_use",
+			"id":    toolCall.ID,
+			"name":  toolCall.Function.Name,
+			"input": map[string]any{},
+		}); err != nil {
+			return err
+		}
+		s.toolID = toolCall.ID
+		s.toolIDs[toolCall.ID] = true
+	} else if s.block != "tool_use" {
+		return nil
+	}
+
+	if toolCall.Function.Arguments == "" {
+		return nil
+	}
+
+	return s.delta(&ClaudeDelta{Type: "input_json_delta", PartialJson: toolCall.Function.Arguments})
+}
+
+func (s *claudeStream) openBlock(blockType string, block map[string]any) error {
+	if err := s.closeBlock(); err != nil {
+		return err
+	}
+
+	s.block = blockType
+	return s.write(&ClaudeStreamEvent{
+		Type:         "content_block_start",
+		Index:        utils.Ptr(s.index),
+		ContentBlock: block,
+	})
+}
+
+func (s *claudeStream) closeBlock() error {
+	if s.block == "" {
+		return nil
+	}
+
+	if err := s.write(&ClaudeStreamEvent{
+		Type:  "content_block_stop",
+		Index: utils.Ptr(s.index),
+	}); err != nil {
+		return err
+	}
+
+	s.block = ""
+	s.toolID = ""
+	s.index++
+	return nil
+}
+
+func (s *claudeStream) delta(delta *ClaudeDelta) error {
+	return s.write(&ClaudeStreamEvent{
+		Type:  "content_block_delta",
+		Index: utils.Ptr(s.index),
+		Delta: delta,
+	})
+}
 
-		// 简单的模拟结束：如果是流的最后一帧（如何判断？）
-		// 目前的types.Completion没有明确的“流结束”标志，除了Stream回调结束。
-		// 但OpenAI兼容接口通过 "[DONE]" 标记。
-		// Claude接口需要在回调外发送 message_stop。
+func (s *claudeStream) write(event *ClaudeStreamEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
 	}
 
-	return events, nil
+	_, err = fmt.Fprintf(s.c.Response(), "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
 }
@@ -2,26 +2,49 @@ package v1
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/xucx/llmapi/internal/server/api/middleware"
 	"github.com/xucx/llmapi/log"
 	"github.com/xucx/llmapi/types"
 )
 
 // see https://platform.openai.com/docs/api-reference/chat/create
 type OpenaiCompletionRequest struct {
-	Messages         []OpenaiMessage `json:"messages,omitempty"`
-	Model            string          `json:"model,omitempty"`
-	Tools            []OpenaiTool    `json:"tools,omitempty"`
-	Stream           bool            `json:"stream,omitempty"`
-	Temperature      *float32        `json:"temperature,omitempty"`
-	MaxTokens        int64           `json:"max_tokens,omitempty"`
-	PresencePenalty  float32         `json:"presence_penalty,omitempty"`
-	FrequencyPenalty float32         `json:"frequency_penalty,omitempty"`
+	Messages            []OpenaiMessage       `json:"messages,omitempty"`
+	Model               string                `json:"model,omitempty"`
+	Tools               []OpenaiTool          `json:"tools,omitempty"`
+	Stream              bool                  `json:"stream,omitempty"`
+	Temperature         *float32              `json:"temperature,omitempty"`
+	TopP                *float32              `json:"top_p,omitempty"`
+	MaxTokens           int64                 `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int64                 `json:"max_completion_tokens,omitempty"`
+	Stop                any                   `json:"stop,omitempty"` // string or []string
+	PresencePenalty     float32               `json:"presence_penalty,omitempty"`
+	FrequencyPenalty    float32               `json:"frequency_penalty,omitempty"`
+	ResponseFormat      *OpenaiResponseFormat `json:"response_format,omitempty"`
+	ToolChoice          any                   `json:"tool_choice,omitempty"` // string or {"type": "function", "function": {"name": ...}}
+	ParallelToolCalls   *bool                 `json:"parallel_tool_calls,omitempty"`
+	ReasoningEffort     string                `json:"reasoning_effort,omitempty"` // minimal, low, medium or high
+}
+
+type OpenaiResponseFormat struct {
+	Type       string            `json:"type"` // text, json_object or json_schema
+	JSONSchema *OpenaiJSONSchema `json:"json_schema,omitempty"`
+}
+
+type OpenaiJSONSchema struct {
+	Name        string         `json:"name,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Schema      map[string]any `json:"schema,omitempty"`
+	Strict      bool           `json:"strict,omitempty"`
 }
 
 type OpenaiMessage struct {
@@ -75,9 +98,75 @@ type OpenaiCompletion struct {
 }
 
 type OpenaiUsage struct {
-	PromptTokens     int64 `json:"prompt_tokens"`
-	CompletionTokens int64 `json:"completion_tokens"`
-	TotalTokens      int64 `json:"total_tokens"`
+	PromptTokens        int64                      `json:"prompt_tokens"`
+	CompletionTokens    int64                      `json:"completion_tokens"`
+	TotalTokens         int64                      `json:"total_tokens"`
+	PromptTokensDetails *OpenaiPromptTokensDetails `json:"prompt_tokens_details,omitempty"`
+}
+
+type OpenaiPromptTokensDetails struct {
+	CachedTokens     int64 `json:"cached_tokens"`
+	CacheWriteTokens int64 `json:"cache_write_tokens,omitempty"` // not in openai, for providers with paid cache writes
+}
+
+// see https://platform.openai.com/docs/api-reference/embeddings/create
+type OpenaiEmbeddingRequest struct {
+	Input          any    `json:"input"` // string or []string
+	Model          string `json:"model"`
+	Dimensions     *int64 `json:"dimensions,omitempty"`
+	EncodingFormat string `json:"encoding_format,omitempty"` // float or base64
+	User           string `json:"user,omitempty"`
+}
+
+type OpenaiEmbeddingResponse struct {
+	Object string            `json:"object"`
+	Data   []OpenaiEmbedding `json:"data"`
+	Model  string            `json:"model"`
+	Usage  OpenaiUsage       `json:"usage"`
+}
+
+type OpenaiEmbedding struct {
+	Object    string `json:"object"`
+	Embedding any    `json:"embedding"` // []float32, or base64 of little endian float32
+	Index     int    `json:"index"`
+}
+
+// see https://platform.openai.com/docs/api-reference/models/list
+type OpenaiModelList struct {
+	Object string        `json:"object"`
+	Data   []OpenaiModel `json:"data"`
+}
+
+type OpenaiModel struct {
+	ID       string `json:"id"`
+	Object   string `json:"object"`
+	Created  int64  `json:"created"`
+	OwnedBy  string `json:"owned_by"`
+	Provider string `json:"provider"`
+	MaxToken int64  `json:"max_tokens"`
+}
+
+func (s *ApiService) OpenaiListModels(c echo.Context) error {
+	list := &OpenaiModelList{
+		Object: "list",
+		Data:   []OpenaiModel{},
+	}
+
+	identity := middleware.IdentityFromContext(c.Request().Context())
+	for _, info := range s.models.List() {
+		if identity != nil && !identity.AllowModel(info.Name) {
+			continue
+		}
+		list.Data = append(list.Data, OpenaiModel{
+			ID:       info.Name,
+			Object:   "model",
+			OwnedBy:  info.ProviderType,
+			Provider: info.Provider,
+			MaxToken: info.MaxToken,
+		})
+	}
+
+	return c.JSON(http.StatusOK, list)
 }
 
 func (s *ApiService) OpenaiCompletion(c echo.Context) error {
@@ -112,22 +201,68 @@ func (s *ApiService) OpenaiCompletion(c echo.Context) error {
 		options = append(options, types.ChatWithTools(tools))
 	}
 
-	// Temperature
 	if req.Temperature != nil {
-		options = append(options, func(opts *types.ChatOptions) *types.ChatOptions {
-			opts.Temperature = req.Temperature
-			return opts
-		})
+		options = append(options, types.ChatWithTemperature(*req.Temperature))
+	}
+
+	if req.TopP != nil {
+		options = append(options, types.ChatWithTopP(*req.TopP))
+	}
+
+	// max_tokens is deprecated in favor of max_completion_tokens
+	if req.MaxCompletionTokens > 0 {
+		options = append(options, types.ChatWithMaxTokens(req.MaxCompletionTokens))
+	} else if req.MaxTokens > 0 {
+		options = append(options, types.ChatWithMaxTokens(req.MaxTokens))
+	}
+
+	if req.Stop != nil {
+		stop, err := fromOpenaiStop(req.Stop)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		options = append(options, types.ChatWithStopSequences(stop))
+	}
+
+	if req.ReasoningEffort != "" {
+		options = append(options, types.ChatWithReasoning(types.NewReasoning(types.ReasoningEffort(req.ReasoningEffort))))
+	}
+
+	if req.ToolChoice != nil {
+		choice, err := fromOpenaiToolChoice(req.ToolChoice)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		options = append(options, types.ChatWithToolChoice(choice))
+	}
+
+	if req.ParallelToolCalls != nil {
+		options = append(options, types.ChatWithParallelToolCalls(*req.ParallelToolCalls))
+	}
+
+	if req.ResponseFormat != nil {
+		format, err := fromOpenaiResponseFormat(req.ResponseFormat)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		options = append(options, types.ChatWithResponseFormat(format))
 	}
 
 	// Generate
 	if req.Stream {
-		c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
-		c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
-		c.Response().Header().Set(echo.HeaderConnection, "keep-alive")
-		c.Response().WriteHeader(http.StatusOK)
+		// headers are written with the first chunk, so headers set while generating are sent
+		// and a request failing before any chunk gets an error status
+		startStream := func() {
+			if c.Response().Committed {
+				return
+			}
+			c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+			c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
+			c.Response().Header().Set(echo.HeaderConnection, "keep-alive")
+			c.Response().WriteHeader(http.StatusOK)
+		}
 
-		options = append(options, types.ChatWithStreamingFunc(func(ctx context.Context, completion *types.Completion) error {
+		writeChunk := func(completion *types.Completion) error {
 			resp, err := toOpenaiCompletionResponse(completion)
 			if err != nil {
 				return err
@@ -138,26 +273,53 @@ func (s *ApiService) OpenaiCompletion(c echo.Context) error {
 				return err
 			}
 
+			startStream()
 			fmt.Fprintf(c.Response(), "data: %s\n\n", chunkData)
 			c.Response().Flush()
 			return nil
+		}
+
+		finished := false
+		options = append(options, types.ChatWithStreamingFunc(func(ctx context.Context, completion *types.Completion) error {
+			if completion.FinishReason != "" {
+				finished = true
+			}
+			return writeChunk(completion)
 		}))
 
-		_, err := s.models.Generate(ctx, req.Model, messages, options...)
+		completion, err := s.generate(ctx, req.Model, messages, options...)
 		if err != nil {
 			log.Errorw("llm chat fail", "model", req.Model, "error", err)
+			if !c.Response().Committed {
+				return httpGenerateError(err)
+			}
 			return nil
 		}
 
+		// clients wait for a chunk with a finish reason, providers without one in their stream get it at the end
+		if !finished {
+			final := &types.Completion{
+				Delta:        true,
+				Model:        completion.Model,
+				Message:      &types.Message{ID: completion.Message.ID, Role: completion.Message.Role},
+				FinishReason: types.FinishReason(toOpenaiFinishReason(completion)),
+			}
+			if err := writeChunk(final); err != nil {
+				log.Errorw("llm chat stream fail", "model", req.Model, "error", err)
+				return nil
+			}
+		}
+
+		startStream()
 		fmt.Fprintf(c.Response(), "data: [DONE]\n\n")
 		c.Response().Flush()
 		return nil
 
 	} else {
-		completion, err := s.models.Generate(ctx, req.Model, messages, options...)
+		completion, err := s.generate(ctx, req.Model, messages, options...)
 		if err != nil {
 			log.Errorw("llm chat fail", "model", req.Model, "error", err)
-			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			return httpGenerateError(err)
 		}
 
 		resp, err := toOpenaiCompletionResponse(completion)
@@ -169,6 +331,81 @@ func (s *ApiService) OpenaiCompletion(c echo.Context) error {
 	}
 }
 
+func (s *ApiService) OpenaiEmbeddings(c echo.Context) error {
+	req := &OpenaiEmbeddingRequest{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	inputs, err := fromOpenaiEmbeddingInput(req.Input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if req.EncodingFormat != "" && req.EncodingFormat != "float" && req.EncodingFormat != "base64" {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("encoding_format %s not support", req.EncodingFormat))
+	}
+
+	options := []types.EmbedOption{}
+	if req.Dimensions != nil {
+		options = append(options, types.EmbedWithDimensions(*req.Dimensions))
+	}
+
+	embeddings, err := s.embed(c.Request().Context(), req.Model, inputs, options...)
+	if err != nil {
+		log.Errorw("llm embed fail", "model", req.Model, "error", err)
+		return httpGenerateError(err)
+	}
+
+	resp := &OpenaiEmbeddingResponse{
+		Object: "list",
+		Data:   []OpenaiEmbedding{},
+		Model:  embeddings.Model,
+		Usage: OpenaiUsage{
+			PromptTokens: embeddings.Usage.PromptTokens,
+			TotalTokens:  embeddings.Usage.TotalTokens,
+		},
+	}
+	for _, e := range embeddings.Embeddings {
+		var embedding any = e.Vector
+		if req.EncodingFormat == "base64" {
+			embedding = encodeEmbedding(e.Vector)
+		}
+		resp.Data = append(resp.Data, OpenaiEmbedding{Object: "embedding", Embedding: embedding, Index: e.Index})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func fromOpenaiEmbeddingInput(input any) ([]string, error) {
+	switch v := input.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		inputs := []string{}
+		for _, item := range v {
+			text, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("input only support strings")
+			}
+			inputs = append(inputs, text)
+		}
+		if len(inputs) > 0 {
+			return inputs, nil
+		}
+	}
+	return nil, fmt.Errorf("input needs a string or an array of strings")
+}
+
+// encodeEmbedding is the base64 encoding_format of openai
+func encodeEmbedding(vector []float32) string {
+	b := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
 func fromOpenaiMessage(m OpenaiMessage) (*types.Message, error) {
 	role := types.MessageRoleUser
 	switch m.Role {
@@ -242,6 +479,25 @@ func fromOpenaiMessage(m OpenaiMessage) (*types.Message, error) {
 	return msg, nil
 }
 
+func fromOpenaiStop(stop any) ([]string, error) {
+	switch v := stop.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		stops := []string{}
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("stop must be string or array of string")
+			}
+			stops = append(stops, s)
+		}
+		return stops, nil
+	default:
+		return nil, fmt.Errorf("stop must be string or array of string")
+	}
+}
+
 func fromOpenaiTools(tools []OpenaiTool) ([]*types.Tool, error) {
 	ts := []*types.Tool{}
 	for _, t := range tools {
@@ -266,6 +522,41 @@ func fromOpenaiTools(tools []OpenaiTool) ([]*types.Tool, error) {
 	return ts, nil
 }
 
+func fromOpenaiToolChoice(choice any) (*types.ToolChoice, error) {
+	switch v := choice.(type) {
+	case string:
+		switch types.ToolChoiceType(v) {
+		case types.ToolChoiceAuto, types.ToolChoiceNone, types.ToolChoiceRequired:
+			return &types.ToolChoice{Type: types.ToolChoiceType(v)}, nil
+		}
+	case map[string]interface{}:
+		if function, ok := v["function"].(map[string]interface{}); ok {
+			if name, ok := function["name"].(string); ok && name != "" {
+				return types.NewFunctionToolChoice(name), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("tool_choice %v not support", choice)
+}
+
+func fromOpenaiResponseFormat(f *OpenaiResponseFormat) (*types.ResponseFormat, error) {
+	format := &types.ResponseFormat{Type: types.ResponseFormatType(f.Type)}
+	switch format.Type {
+	case types.ResponseFormatText, types.ResponseFormatJSONObject:
+	case types.ResponseFormatJSONSchema:
+		if f.JSONSchema == nil {
+			return nil, fmt.Errorf("response_format json_schema needs json_schema")
+		}
+		format.Name = f.JSONSchema.Name
+		format.Description = f.JSONSchema.Description
+		format.Schema = f.JSONSchema.Schema
+		format.Strict = f.JSONSchema.Strict
+	default:
+		return nil, fmt.Errorf("response_format type %s not support", f.Type)
+	}
+	return format, nil
+}
+
 func toOpenaiCompletionResponse(c *types.Completion) (*OpenaiCompletionResponse, error) {
 	resp := &OpenaiCompletionResponse{
 		ID:      c.Message.ID,
@@ -309,14 +600,13 @@ func toOpenaiCompletionResponse(c *types.Completion) (*OpenaiCompletionResponse,
 		msg.ToolCalls = openaiToolCalls
 	}
 
-	finishReason := "stop"
-	if len(toolCalls) > 0 {
-		finishReason = "tool_calls"
-	}
-
+	finishReason := toOpenaiFinishReason(c)
 	if c.Delta {
 		choice.Delta = msg
-		choice.FinishReason = nil
+		// only the last chunk has a finish reason
+		if c.FinishReason != "" {
+			choice.FinishReason = &finishReason
+		}
 	} else {
 		choice.Message = msg
 		choice.FinishReason = &finishReason
@@ -329,8 +619,28 @@ func toOpenaiCompletionResponse(c *types.Completion) (*OpenaiCompletionResponse,
 			PromptTokens:     c.Usage.PromptTokens,
 			CompletionTokens: c.Usage.CompletionTokens,
 			TotalTokens:      c.Usage.TotalTokens,
+			PromptTokensDetails: &OpenaiPromptTokensDetails{
+				CachedTokens:     c.Usage.CachedTokens,
+				CacheWriteTokens: c.Usage.CacheWriteTokens,
+			},
 		}
 	}
 
 	return resp, nil
 }
+
+func toOpenaiFinishReason(c *types.Completion) string {
+	switch c.FinishReason {
+	case types.FinishReasonLength:
+		return "length"
+	case types.FinishReasonContentFilter:
+		return "content_filter"
+	case types.FinishReasonToolCalls:
+		return "tool_calls"
+	}
+
+	if c.Message != nil && len(c.Message.ToolCalls()) > 0 {
+		return "tool_calls"
+	}
+	return "stop"
+}
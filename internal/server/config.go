@@ -4,6 +4,8 @@ import (
 	"os"
 
 	"github.com/xucx/llmapi"
+	"github.com/xucx/llmapi/internal/ledger"
+	"github.com/xucx/llmapi/internal/server/api/middlewares"
 	"github.com/xucx/llmapi/log"
 
 	"go.yaml.in/yaml/v3"
@@ -15,15 +17,23 @@ var (
 		Log: log.ZapLoggerConfig{
 			Level: "info",
 		},
-		Host: "0.0.0.0:9000",
+		Host:         "0.0.0.0:9000",
+		OpenaiPrefix: "/v1",
 	}
 )
 
 type Config struct {
-	Log    log.ZapLoggerConfig `yaml:"log"`
-	Host   string              `yaml:"host"`
-	Tokens []string            `yaml:"tokens"`
-	LLM    llmapi.Config       `yaml:"llm"`
+	Log          log.ZapLoggerConfig         `yaml:"log"`
+	Host         string                      `yaml:"host"`
+	Tokens       []string                    `yaml:"tokens"`   // deprecated, plain tokens with every scope, use keys
+	Keys         []middlewares.KeyConfig     `yaml:"keys"`     // api keys with scopes
+	KeysFile     string                      `yaml:"keysFile"` // yaml file of keys, reloaded when it changes
+	RateLimit    middlewares.RateLimitConfig `yaml:"rateLimit"`
+	Ledger       ledger.Config               `yaml:"ledger"` // usage records, without a sink totals are kept in memory only
+	Tracing      TracingConfig               `yaml:"tracing"`
+	OpenaiPrefix string                      `yaml:"openaiPrefix"` // openai compatible api mount path, eg /v1/chat/completions
+	BodyLimit    string                      `yaml:"bodyLimit"`    // of http requests, eg 64M, default 64M
+	LLM          llmapi.Config               `yaml:"llm"`
 }
 
 func LoadConfig(f string) error {
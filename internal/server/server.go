@@ -12,6 +12,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	apiv1 "github.com/xucx/llmapi/api/v1"
+	"github.com/xucx/llmapi/internal/ledger"
 	"github.com/xucx/llmapi/internal/server/api/middleware"
 	"github.com/xucx/llmapi/internal/server/api/middlewares"
 	v1 "github.com/xucx/llmapi/internal/server/api/v1"
@@ -27,6 +28,18 @@ func Run(ctx context.Context) error {
 		return err
 	}
 
+	shutdownTracing, err := initTracing(ctx, C.Tracing)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			log.Errorf("tracing shutdown error: %v", err)
+		}
+	}()
+
 	listen, err := net.Listen("tcp", C.Host)
 	if err != nil {
 		return err
@@ -36,18 +49,46 @@ func Run(ctx context.Context) error {
 	grpcListener := m.MatchWithWriters(cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"))
 	httpListener := m.Match(cmux.Any())
 
+	httpTokenGetter := func(ctx echo.Context) (string, error) {
+		if strings.HasPrefix(ctx.Request().URL.Path, "/api/v1/claude/") {
+			return ctx.Request().Header.Get("X-Api-Key"), nil
+		}
+		return middlewares.DefaultAuthHttpHeaderGetter(ctx)
+	}
+
+	keyStore, err := newKeyStore()
+	if err != nil {
+		return err
+	}
+
+	usageLedger, err := ledger.Open(C.Ledger)
+	if err != nil {
+		return err
+	}
+	if usageLedger == nil {
+		// budgets and the usage report still work until a restart
+		usageLedger = ledger.New(nil)
+	}
+	defer usageLedger.Close()
+
+	metrics := middlewares.NewMetrics()
+
 	midds := []middleware.Middleware{
+		middlewares.NewTracing(),
 		middlewares.NewGzip(),
+		middlewares.NewBodyLimit(C.BodyLimit),
 		middlewares.NewLogger(),
+		metrics,
 		middlewares.NewAuth(
-			middlewares.AuthWithTokens(C.Tokens),
-			middlewares.AuthWithHttpTokenGetter(func(ctx echo.Context) (string, error) {
-				if ctx.Request().URL.Path == "/api/v1/claude/messages" {
-					return ctx.Request().Header.Get("X-Api-Key"), nil
-				}
-				return middlewares.DefaultAuthHttpHeaderGetter(ctx)
-			}),
+			middlewares.AuthWithKeyStore(keyStore),
+			middlewares.AuthWithHttpTokenGetter(httpTokenGetter),
 		),
+		middlewares.NewUsage(usageLedger),
+		middlewares.NewRateLimit(
+			middlewares.RateLimitWithConfig(C.RateLimit),
+			middlewares.RateLimitWithHttpTokenGetter(httpTokenGetter),
+		),
+		middlewares.NewCache(),
 	}
 
 	grpcOpts := []grpc.ServerOption{}
@@ -57,6 +98,7 @@ func Run(ctx context.Context) error {
 	}
 
 	apiService := v1.NewApiService(models)
+	adminService := v1.NewAdminService(usageLedger)
 
 	grpcServer := grpc.NewServer(grpcOpts...)
 	apiv1.RegisterApiServiceServer(grpcServer, apiService)
@@ -67,9 +109,21 @@ func Run(ctx context.Context) error {
 		httpServer.Use(m.Http())
 	}
 
+	httpServer.GET("/metrics", metrics.Handler())
+
 	httpApiV1 := httpServer.Group("/api/v1")
 	httpApiV1.POST("/openai/completions", apiService.OpenaiCompletion)
+	httpApiV1.GET("/openai/models", apiService.OpenaiListModels)
+	httpApiV1.POST("/openai/embeddings", apiService.OpenaiEmbeddings)
 	httpApiV1.POST("/claude/messages", apiService.ClaudeCreateMessage)
+	httpApiV1.POST("/claude/messages/count_tokens", apiService.ClaudeCountTokens)
+	httpApiV1.GET("/admin/usage", adminService.Usage)
+
+	// openai sdk clients use the standard paths
+	httpOpenai := httpServer.Group(strings.TrimSuffix(C.OpenaiPrefix, "/"))
+	httpOpenai.POST("/chat/completions", apiService.OpenaiCompletion)
+	httpOpenai.GET("/models", apiService.OpenaiListModels)
+	httpOpenai.POST("/embeddings", apiService.OpenaiEmbeddings)
 
 	g, gctx := errgroup.WithContext(ctx)
 
@@ -138,6 +192,37 @@ func Run(ctx context.Context) error {
 
 }
 
+// newKeyStore merges the keys in config, the keys file and the deprecated tokens
+func newKeyStore() (middlewares.KeyStore, error) {
+	keys := append([]middlewares.KeyConfig{}, C.Keys...)
+	for i, token := range C.Tokens {
+		keys = append(keys, middlewares.KeyConfig{Name: fmt.Sprintf("token-%d", i), Hash: middlewares.HashKey(token)})
+	}
+
+	stores := middlewares.MultiKeyStore{}
+	if len(keys) > 0 {
+		store, err := middlewares.NewMemoryKeyStore(keys)
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, store)
+	}
+
+	if C.KeysFile != "" {
+		store, err := middlewares.NewFileKeyStore(C.KeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("load keys file %s fail: %w", C.KeysFile, err)
+		}
+		stores = append(stores, store)
+	}
+
+	// no keys, the api is open
+	if len(stores) == 0 {
+		return nil, nil
+	}
+	return stores, nil
+}
+
 func isMuxClosedConnError(err error) bool {
 	return strings.Contains(err.Error(), "use of closed network connection") ||
 		strings.Contains(err.Error(), "mux: server closed")
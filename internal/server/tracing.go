@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+const (
+	TracingExporterStdout = "stdout"
+	TracingExporterFile   = "file"
+	TracingExporterOtlp   = "otlp"
+)
+
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`    // stdout, file or otlp, empty disables tracing
+	File        string  `yaml:"file"`        // spans as json lines, for the file exporter
+	Endpoint    string  `yaml:"endpoint"`    // otlp http endpoint, eg localhost:4318
+	Insecure    bool    `yaml:"insecure"`    // otlp over http instead of https
+	SampleRatio float64 `yaml:"sampleRatio"` // of new traces, a sampled caller is always followed, default 1
+	ServiceName string  `yaml:"serviceName"` // default llmapi
+}
+
+// initTracing sets the global tracer provider and the w3c propagator,
+// the returned func flushes the spans left
+func initTracing(ctx context.Context, conf TracingConfig) (func(context.Context) error, error) {
+	// the trace context of callers is passed upstream even when spans are not exported
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var closer io.Closer
+	var err error
+	switch conf.Exporter {
+	case "":
+		return func(context.Context) error { return nil }, nil
+	case TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case TracingExporterFile:
+		if conf.File == "" {
+			return nil, fmt.Errorf("tracing file exporter needs a file")
+		}
+		var fd *os.File
+		fd, err = os.OpenFile(conf.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		closer = fd
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(fd))
+	case TracingExporterOtlp:
+		opts := []otlptracehttp.Option{}
+		if conf.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(conf.Endpoint))
+		}
+		if conf.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("tracing exporter %s not support", conf.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := conf.ServiceName
+	if serviceName == "" {
+		serviceName = "llmapi"
+	}
+
+	ratio := conf.SampleRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}, nil
+}
@@ -0,0 +1,165 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// names of the openai encodings
+const (
+	EncodingCl100k = "cl100k_base"
+	EncodingO200k  = "o200k_base"
+)
+
+// pre-tokenizer patterns of the encodings, their `\s+(?!\S)` is done by split as go has no lookahead
+var patterns = map[string]*regexp.Regexp{
+	EncodingCl100k: regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`),
+	EncodingO200k: regexp.MustCompile(`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+`),
+}
+
+// Encoding is a byte pair encoding of openai, loaded from its tiktoken rank file
+type Encoding struct {
+	name    string
+	ranks   map[string]int
+	pattern *regexp.Regexp
+}
+
+// encoding is used by Estimate when it is set
+var encoding atomic.Pointer[Encoding]
+
+// SetEncoding makes Estimate count with the encoding, nil goes back to the heuristic
+func SetEncoding(e *Encoding) {
+	encoding.Store(e)
+}
+
+// LoadEncoding reads a tiktoken rank file of the encoding name, eg cl100k_base.tiktoken,
+// with a base64 token and its rank on each line
+func LoadEncoding(name, path string) (*Encoding, error) {
+	pattern, ok := patterns[name]
+	if !ok {
+		return nil, fmt.Errorf("encoding %s not support", name)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ranks := map[string]int{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := bytes.Fields(scanner.Bytes())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("load encoding %s fail, line %d is not a token and its rank", name, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(string(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("load encoding %s fail, line %d: %w", name, line, err)
+		}
+		rank, err := strconv.Atoi(string(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("load encoding %s fail, line %d: %w", name, line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &Encoding{name: name, ranks: ranks, pattern: pattern}, nil
+}
+
+func (e *Encoding) Name() string {
+	return e.name
+}
+
+// Count is the number of tokens of text, special tokens are counted as text
+func (e *Encoding) Count(text string) int64 {
+	var tokens int64
+	for _, piece := range e.split(text) {
+		if _, ok := e.ranks[piece]; ok {
+			tokens++
+			continue
+		}
+		tokens += int64(e.merge(piece))
+	}
+	return tokens
+}
+
+// split is the pre-tokenizer of the encoding
+func (e *Encoding) split(text string) []string {
+	pieces := []string{}
+	for len(text) > 0 {
+		loc := e.pattern.FindStringIndex(text)
+		if loc == nil {
+			pieces = append(pieces, text)
+			break
+		}
+		end := loc[1]
+
+		// `\s+(?!\S)` leaves the last space of a run to the word after it
+		if match := text[:end]; end < len(text) && isSpaceRun(match) {
+			if _, size := utf8.DecodeLastRuneInString(match); end-size > 0 {
+				end -= size
+			}
+		}
+
+		pieces = append(pieces, text[:end])
+		text = text[end:]
+	}
+	return pieces
+}
+
+// isSpaceRun is a match of the `\s+` of the patterns, runs with a newline end with it
+func isSpaceRun(match string) bool {
+	last, _ := utf8.DecodeLastRuneInString(match)
+	if last == '\r' || last == '\n' {
+		return false
+	}
+	for _, r := range match {
+		switch r {
+		case ' ', '\t', '\v', '\f':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// merge applies the merges of the lowest rank to the bytes of piece until none is left,
+// and returns the number of tokens
+func (e *Encoding) merge(piece string) int {
+	// starts of the parts, with the end of piece
+	parts := make([]int, len(piece)+1)
+	for i := range parts {
+		parts[i] = i
+	}
+
+	for len(parts) > 2 {
+		best, at := math.MaxInt, -1
+		for i := 0; i+2 < len(parts); i++ {
+			if rank, ok := e.ranks[piece[parts[i]:parts[i+2]]]; ok && rank < best {
+				best, at = rank, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		parts = append(parts[:at+1], parts[at+2:]...)
+	}
+	return len(parts) - 1
+}
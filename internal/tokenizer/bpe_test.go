@@ -0,0 +1,107 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeRanks writes a tiktoken rank file of tokens, ranked in order
+func writeRanks(t *testing.T, tokens ...string) string {
+	t.Helper()
+
+	lines := []string{}
+	for rank, token := range tokens {
+		lines = append(lines, fmt.Sprintf("%s %d", base64.StdEncoding.EncodeToString([]byte(token)), rank))
+	}
+	path := filepath.Join(t.TempDir(), "test.tiktoken")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEncodingSplit(t *testing.T) {
+	cases := []struct {
+		encoding string
+		text     string
+		pieces   []string
+	}{
+		{EncodingCl100k, "hello world", []string{"hello", " world"}},
+		{EncodingCl100k, "Hello   world", []string{"Hello", "  ", " world"}},
+		{EncodingCl100k, "1234567", []string{"123", "456", "7"}},
+		{EncodingCl100k, "I'm here", []string{"I", "'m", " here"}},
+		{EncodingCl100k, "a\n\n b", []string{"a", "\n\n", " b"}},
+		{EncodingCl100k, "end  ", []string{"end", "  "}},
+		{EncodingCl100k, `{"a": 1}`, []string{`{"`, "a", `":`, " ", "1", "}"}},
+		{EncodingCl100k, "Hello World's", []string{"Hello", " World", "'s"}},
+		// o200k splits words at case changes and keeps contractions
+		{EncodingO200k, "Hello World's", []string{"Hello", " World's"}},
+		{EncodingO200k, "camelCase", []string{"camel", "Case"}},
+		{EncodingO200k, "a/\nb", []string{"a", "/\n", "b"}},
+	}
+
+	path := writeRanks(t, "a")
+	for _, c := range cases {
+		e, err := LoadEncoding(c.encoding, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := e.split(c.text); !reflect.DeepEqual(got, c.pieces) {
+			t.Errorf("%s split %q = %q, want %q", c.encoding, c.text, got, c.pieces)
+		}
+	}
+}
+
+func TestEncodingCount(t *testing.T) {
+	e, err := LoadEncoding(EncodingCl100k, writeRanks(t, "a", "b", "c", " ", "ab", "abc", " a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		text   string
+		tokens int64
+	}{
+		{"abc", 1},
+		{"abcab", 2}, // ab, ab then abc
+		{"cba", 3},
+		{" abc", 2}, // ab before the space, then abc
+		{"abc abc", 3},
+		{"", 0},
+	}
+	for _, c := range cases {
+		if got := e.Count(c.text); got != c.tokens {
+			t.Errorf("Count(%q) = %d, want %d", c.text, got, c.tokens)
+		}
+	}
+}
+
+func TestSetEncoding(t *testing.T) {
+	e, err := LoadEncoding(EncodingO200k, writeRanks(t, "a", "b", "ab"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetEncoding(e)
+	t.Cleanup(func() { SetEncoding(nil) })
+	if got := Estimate("abab"); got != 2 {
+		t.Errorf("Estimate with the encoding = %d, want 2", got)
+	}
+}
+
+func TestLoadEncodingErrors(t *testing.T) {
+	if _, err := LoadEncoding("p50k_base", writeRanks(t, "a")); err == nil {
+		t.Errorf("unknown encoding loaded")
+	}
+
+	path := filepath.Join(t.TempDir(), "bad.tiktoken")
+	os.WriteFile(path, []byte("YQ== 0\nnot-a-rank\n"), 0o644)
+	if _, err := LoadEncoding(EncodingCl100k, path); err == nil {
+		t.Errorf("malformed rank file loaded")
+	}
+}
@@ -0,0 +1,232 @@
+// Package tokenizer counts tokens where a provider can not count, with the byte pair encoding
+// of a tiktoken rank file when one is loaded and with a heuristic otherwise, the overheads of
+// messages and media are approximate either way
+package tokenizer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/xucx/llmapi/types"
+)
+
+// overheads of the chat format of openai models
+const (
+	MessageTokens = 3 // role and separators of each message
+	ReplyTokens   = 3 // every reply is primed with the assistant role
+	ToolTokens    = 8 // each tool definition
+
+	// inputs which are not text, at their usual size
+	ImageTokens = 765  // 1024x1024 image in high detail
+	FileTokens  = 1500 // a few pages of a document
+	AudioTokens = 10   // each second of audio
+
+	audioBytesPerSecond = 32000 // 16khz pcm16
+)
+
+// Margin is the relative error allowed to the estimates before they reject a request
+const Margin = 0.15
+
+// Estimate is a heuristic count of the tokens of text: the text is split with the pre-tokenizer
+// rules of the cl100k/o200k encodings, and each piece costs what the merges usually leave of it.
+// Without the merges it is not exact, see Margin. With an encoding set by SetEncoding the text is
+// counted by its merges instead.
+func Estimate(text string) int64 {
+	if e := encoding.Load(); e != nil {
+		return e.Count(text)
+	}
+
+	var tokens int64
+	for len(text) > 0 {
+		n, cost := piece(text)
+		tokens += cost
+		text = text[n:]
+	}
+	return tokens
+}
+
+// piece returns the length in bytes and the token cost of the piece at the start of text
+func piece(text string) (int, int64) {
+	r, size := utf8.DecodeRuneInString(text)
+
+	switch {
+	case unicode.IsSpace(r):
+		// a run of spaces, a single space before a word or punctuation belongs to it
+		n := size
+		for n < len(text) {
+			next, s := utf8.DecodeRuneInString(text[n:])
+			if !unicode.IsSpace(next) {
+				if r == ' ' && n == size && isLetter(next) {
+					return word(text, n)
+				}
+				if r == ' ' && n == size && !unicode.IsDigit(next) {
+					return punctuation(text, n)
+				}
+				break
+			}
+			n += s
+		}
+		return n, 1
+
+	case unicode.IsDigit(r):
+		// numbers are split in groups of up to three digits
+		n := size
+		for n < len(text) {
+			next, s := utf8.DecodeRuneInString(text[n:])
+			if !unicode.IsDigit(next) {
+				break
+			}
+			n += s
+		}
+		return n, int64((n + 2) / 3)
+
+	case isLetter(r):
+		return word(text, 0)
+
+	default:
+		return punctuation(text, 0)
+	}
+}
+
+// punctuation is a run of punctuation starting at offset start of text, its leading space included,
+// common pairs like `":` or `},` are merged
+func punctuation(text string, start int) (int, int64) {
+	n := start
+	for n < len(text) {
+		r, s := utf8.DecodeRuneInString(text[n:])
+		if unicode.IsSpace(r) || unicode.IsDigit(r) || isLetter(r) {
+			break
+		}
+		n += s
+	}
+	return n, int64((utf8.RuneCountInString(text[start:n]) + 1) / 2)
+}
+
+// word is a run of letters starting at offset start of text, its leading space included
+func word(text string, start int) (int, int64) {
+	n := start
+	ascii, other := 0, 0
+	var cost int64
+	for n < len(text) {
+		r, s := utf8.DecodeRuneInString(text[n:])
+		if !isLetter(r) {
+			break
+		}
+		switch {
+		case r < utf8.RuneSelf:
+			ascii++
+		case isIdeograph(r):
+			// cjk characters are about one token each
+			cost++
+		default:
+			other++
+		}
+		n += s
+	}
+
+	// common english words are single tokens, longer ones split every few letters
+	if ascii > 0 {
+		if ascii <= 8 {
+			cost++
+		} else {
+			cost += int64((ascii + 5) / 6)
+		}
+	}
+	// other scripts merge less, about three letters to a token
+	cost += int64((other + 2) / 3)
+
+	if cost == 0 {
+		cost = 1
+	}
+	return n, cost
+}
+
+func isLetter(r rune) bool {
+	return unicode.IsLetter(r) || unicode.Is(unicode.Mn, r)
+}
+
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// EstimateMessages counts the prompt tokens of a chat request, with the formatting overhead
+// of openai chat models
+func EstimateMessages(messages []*types.Message, opts *types.ChatOptions) int64 {
+	tokens := int64(ReplyTokens)
+
+	if opts != nil {
+		if opts.Instructions != "" {
+			tokens += MessageTokens + Estimate(opts.Instructions)
+		}
+		tokens += EstimateTools(opts.Tools)
+		if opts.ResponseFormat != nil && opts.ResponseFormat.Schema != nil {
+			tokens += estimateJSON(opts.ResponseFormat.Schema)
+		}
+	}
+
+	for _, msg := range messages {
+		tokens += EstimateMessage(msg)
+	}
+
+	return tokens
+}
+
+// EstimateMessage is what a message adds to EstimateMessages
+func EstimateMessage(msg *types.Message) int64 {
+	tokens := int64(MessageTokens)
+	for _, part := range msg.Parts {
+		tokens += EstimatePart(part)
+	}
+	return tokens
+}
+
+func EstimatePart(part *types.MessagePart) int64 {
+	switch {
+	case part.Text != nil:
+		return Estimate(part.Text.Text)
+	case part.Reasoning != nil:
+		return Estimate(part.Reasoning.Text)
+	case part.Refusal != nil:
+		return Estimate(part.Refusal.Text)
+	case part.ImageURL != nil:
+		if part.ImageURL.Detail == "low" {
+			return 85
+		}
+		return ImageTokens
+	case part.File != nil:
+		return FileTokens
+	case part.Audio != nil:
+		if part.Audio.Transcript != "" && part.Audio.Data == "" {
+			return Estimate(part.Audio.Transcript)
+		}
+		seconds := int64(base64.StdEncoding.DecodedLen(len(part.Audio.Data)) / audioBytesPerSecond)
+		return (seconds + 1) * AudioTokens
+	case part.ToolCall != nil:
+		if part.ToolCall.Function == nil {
+			return 0
+		}
+		return Estimate(part.ToolCall.Function.Name) + Estimate(part.ToolCall.Function.Arguments)
+	case part.ToolResult != nil:
+		return Estimate(part.ToolResult.Result)
+	default:
+		return 0
+	}
+}
+
+func EstimateTools(tools []*types.Tool) int64 {
+	var tokens int64
+	for _, tool := range tools {
+		tokens += ToolTokens + estimateJSON(tool)
+	}
+	return tokens
+}
+
+func estimateJSON(v any) int64 {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return Estimate(string(b))
+}
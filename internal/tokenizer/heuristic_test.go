@@ -0,0 +1,80 @@
+package tokenizer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/xucx/llmapi/types"
+)
+
+// counts of the cl100k_base encoding
+var cl100kCounts = []struct {
+	text   string
+	tokens int64
+}{
+	{"hello world", 2},
+	{"Hello, world!", 4},
+	{"The quick brown fox jumps over the lazy dog.", 10},
+	{"tiktoken is great!", 6},
+	{"antidisestablishmentarianism", 6},
+	{"2 + 2 = 4", 7},
+	{"お誕生日おめでとう", 9},
+	{"1234567", 3},
+}
+
+func TestEstimate(t *testing.T) {
+	var known, estimated int64
+	for _, c := range cl100kCounts {
+		got := Estimate(c.text)
+		if diff := got - c.tokens; diff > 2 || diff < -2 {
+			t.Errorf("Estimate(%q) = %d, cl100k %d", c.text, got, c.tokens)
+		}
+		known += c.tokens
+		estimated += got
+	}
+
+	if diff := math.Abs(float64(estimated-known)) / float64(known); diff > Margin {
+		t.Errorf("estimates are off by %.0f%% in total, margin %.0f%%", diff*100, Margin*100)
+	}
+}
+
+func TestEstimateEmpty(t *testing.T) {
+	if got := Estimate(""); got != 0 {
+		t.Errorf("Estimate(\"\") = %d, want 0", got)
+	}
+}
+
+func TestEstimateMessages(t *testing.T) {
+	messages := []*types.Message{
+		types.NewTextMessage(types.MessageRoleUser, "Hello, world!"),
+	}
+
+	if got, want := EstimateMessages(messages, nil), int64(ReplyTokens+MessageTokens+4); got != want {
+		t.Errorf("EstimateMessages = %d, want %d", got, want)
+	}
+
+	opts := &types.ChatOptions{Instructions: "hello world"}
+	if got, want := EstimateMessages(messages, opts), int64(ReplyTokens+2*MessageTokens+4+2); got != want {
+		t.Errorf("EstimateMessages with instructions = %d, want %d", got, want)
+	}
+}
+
+func TestEstimatePart(t *testing.T) {
+	cases := []struct {
+		name   string
+		part   *types.MessagePart
+		tokens int64
+	}{
+		{"image", &types.MessagePart{ImageURL: &types.MessageImageURL{URL: "https://example.com/a.png"}}, ImageTokens},
+		{"low detail image", &types.MessagePart{ImageURL: &types.MessageImageURL{URL: "https://example.com/a.png", Detail: "low"}}, 85},
+		{"file", &types.MessagePart{File: &types.MessageFile{Data: "JVBERi0="}}, FileTokens},
+		{"tool result", &types.MessagePart{ToolResult: &types.MessageToolResult{Result: "hello world"}}, 2},
+		{"empty", &types.MessagePart{}, 0},
+	}
+
+	for _, c := range cases {
+		if got := EstimatePart(c.part); got != c.tokens {
+			t.Errorf("EstimatePart(%s) = %d, want %d", c.name, got, c.tokens)
+		}
+	}
+}
@@ -4,20 +4,36 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/xucx/llmapi/internal/providers"
 	"github.com/xucx/llmapi/internal/providers/provider"
 	"github.com/xucx/llmapi/types"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
+	// max tokens listed for models without a max token, their requests are sent without
+	// max tokens so the provider default applies, and the context window check reserves none
 	DefaultMaxToken = 32000
 )
 
+var (
+	// ErrCapability is returned when a provider can not honour a chat option
+	ErrCapability = provider.ErrCapability
+	// ErrMediaTooLarge is returned when an inline image or file is above the limit of a provider
+	ErrMediaTooLarge = provider.ErrMediaTooLarge
+)
+
 type Config struct {
 	Providers []ProviderConfig `yaml:"providers"`
 	Models    []ModelConfig    `yaml:"models"`
+	Groups    []GroupConfig    `yaml:"groups"`
+	Cache     CacheConfig      `yaml:"cache"`
+	Tokenizer TokenizerConfig  `yaml:"tokenizer"`
 }
 
 type ProviderConfig struct {
@@ -29,22 +45,76 @@ type ProviderConfig struct {
 }
 
 type ModelConfig struct {
-	Name     string `yaml:"name"`
-	Model    string `yaml:"model"`
-	Provider string `yaml:"provider"`
-	MaxToken int64  `yaml:"maxToken"`
+	Name          string               `yaml:"name"`
+	Model         string               `yaml:"model"`
+	Provider      string               `yaml:"provider"`
+	MaxToken      int64                `yaml:"maxToken"`      // max tokens of requests without their own, zero leaves it to the provider
+	ContextWindow int64                `yaml:"contextWindow"` // prompt and completion tokens, zero skips the check before dispatch
+	Overflow      OverflowPolicy       `yaml:"overflow"`      // reject or trim, default reject
+	Context       *types.ContextPolicy `yaml:"context"`       // of the requests without their own ChatWithContextPolicy
+	Fallbacks     []string             `yaml:"fallbacks"`     // model names or "<provider>/<model>", tried in order when the model fails
+	Retry         RetryConfig          `yaml:"retry"`
+	Price         ModelPrice           `yaml:"price"`
+	Cache         bool                 `yaml:"cache"` // use the response cache of Config.Cache
+}
+
+// ModelPrice is in USD per million tokens
+type ModelPrice struct {
+	Prompt     float64 `yaml:"prompt"`
+	Completion float64 `yaml:"completion"`
+	Cached     float64 `yaml:"cached"`     // cached prompt tokens, zero uses the prompt price
+	CacheWrite float64 `yaml:"cacheWrite"` // prompt tokens written to the cache, zero uses the prompt price
+}
+
+func (p ModelPrice) Cost(usage types.CompletionUsage) float64 {
+	cachedPrice := p.Cached
+	if cachedPrice == 0 {
+		cachedPrice = p.Prompt
+	}
+	cacheWritePrice := p.CacheWrite
+	if cacheWritePrice == 0 {
+		cacheWritePrice = p.Prompt
+	}
+
+	return (float64(usage.PromptTokens-usage.CachedTokens-usage.CacheWriteTokens)*p.Prompt +
+		float64(usage.CachedTokens)*cachedPrice +
+		float64(usage.CacheWriteTokens)*cacheWritePrice +
+		float64(usage.CompletionTokens)*p.Completion) / 1e6
 }
 
 type Model struct {
-	Name     string
-	Model    string
-	Provider provider.Provider
-	MaxToken int64
+	Name          string
+	Model         string
+	Provider      provider.Provider
+	ProviderName  string
+	ProviderType  string // openai, anthropic, google or llmapi
+	MaxToken      int64
+	ContextWindow int64
+	Overflow      OverflowPolicy
+	ContextPolicy *types.ContextPolicy
+	Fallbacks     []string
+	Retry         RetryConfig
+	Price         ModelPrice
+	Cache         bool
 }
 
 type Models struct {
-	providers map[string]provider.Provider
-	models    map[string]*Model
+	providers       map[string]provider.Provider
+	providerTypes   map[string]string
+	models          map[string]*Model
+	groups          map[string]*modelGroup
+	cache           Cache
+	contextManagers map[types.ContextStrategy]ContextManager
+}
+
+// ModelInfo describes a model name accepted by Models.GetModel
+type ModelInfo struct {
+	Name         string
+	Model        string
+	Provider     string // provider name in config
+	ProviderType string // openai, anthropic, google, llmapi, or group
+	MaxToken     int64
+	Passthrough  bool // Name is "<provider>/*", any upstream model of the provider is accepted
 }
 
 func NewProvider(name string, opts ...provider.ProviderOption) (provider.Provider, error) {
@@ -97,24 +167,129 @@ func NewModelFromConfig(conf ProviderConfig, name, model string, maxToken int64)
 	return NewModel(name, model, maxToken, provider)
 }
 
+func (m *Model) maxTokenOrDefault() int64 {
+	if m.MaxToken > 0 {
+		return m.MaxToken
+	}
+	return DefaultMaxToken
+}
+
 func (m *Model) Generate(ctx context.Context, messages []*types.Message, options ...types.ChatOption) (*types.Completion, error) {
+	ctx, span := m.startSpan(ctx, semconv.GenAIOperationNameChat)
 	optionsWithModel := append(options, types.ChatWithModel(m.Model))
-	return m.Provider.Generate(ctx, messages, optionsWithModel...)
+	// without a max token in config the provider default applies, eg anthropic which requires one
+	if m.MaxToken > 0 {
+		optionsWithModel = append(optionsWithModel, types.ChatWithDefaultMaxTokens(m.MaxToken))
+	}
+	completion, err := m.Provider.Generate(ctx, messages, optionsWithModel...)
+	endSpan(span, completion, err)
+	if err != nil {
+		return nil, m.upstreamError(err)
+	}
+
+	completion.Provider = m.ProviderName
+	completion.Usage.Cost = m.Price.Cost(completion.Usage)
+	return completion, nil
 }
 
 func (m *Model) Realtime(ctx context.Context, messages []*types.Message, options ...types.RealTimeOption) (types.RealTimeSession, error) {
+	ctx, span := m.startSpan(ctx, semconv.GenAIOperationNameKey.String("realtime"))
 	optionsWithModel := append(options, types.RealTimeWithModel(m.Model))
-	return m.Provider.Realtime(ctx, messages, optionsWithModel...)
+	session, err := m.Provider.Realtime(ctx, messages, optionsWithModel...)
+	if err != nil {
+		endSpan(span, nil, err)
+		return nil, m.upstreamError(err)
+	}
+
+	return &modelSession{RealTimeSession: session, model: m, span: span}, nil
+}
+
+func (m *Model) Embed(ctx context.Context, inputs []string, options ...types.EmbedOption) (*types.Embeddings, error) {
+	embedder, ok := m.Provider.(provider.Embedder)
+	if !ok {
+		return nil, provider.CapabilityError(m.ProviderName, "embeddings")
+	}
+
+	ctx, span := m.startSpan(ctx, semconv.GenAIOperationNameEmbeddings)
+	optionsWithModel := append(options, types.EmbedWithModel(m.Model))
+	embeddings, err := embedder.Embed(ctx, inputs, optionsWithModel...)
+	if err != nil {
+		endSpan(span, nil, err)
+		return nil, m.upstreamError(err)
+	}
+	endSpan(span, &types.Completion{Model: embeddings.Model, Usage: embeddings.Usage}, nil)
+
+	embeddings.Provider = m.ProviderName
+	embeddings.Usage.Cost = m.Price.Cost(embeddings.Usage)
+	return embeddings, nil
+}
+
+func (m *Model) upstreamError(err error) error {
+	return &UpstreamError{
+		Model:    m.Name,
+		Provider: m.ProviderName,
+		Class:    provider.ClassifyError(m.Provider, err),
+		Err:      err,
+	}
+}
+
+// modelSession sets the provider and the cost like Model.Generate,
+// its span lasts until the session is closed
+type modelSession struct {
+	types.RealTimeSession
+	model *Model
+
+	mu    sync.Mutex
+	span  trace.Span
+	usage types.CompletionUsage
+	last  *types.Completion
+}
+
+func (s *modelSession) Recv(ctx context.Context) (*types.Completion, error) {
+	completion, err := s.RealTimeSession.Recv(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	completion.Provider = s.model.ProviderName
+	completion.Usage.Cost = s.model.Price.Cost(completion.Usage)
+
+	if !completion.Delta {
+		s.mu.Lock()
+		s.usage.PromptTokens += completion.Usage.PromptTokens
+		s.usage.CompletionTokens += completion.Usage.CompletionTokens
+		s.last = completion
+		s.mu.Unlock()
+	}
+	return completion, nil
+}
+
+func (s *modelSession) Close() {
+	s.RealTimeSession.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.span != nil {
+		summary := &types.Completion{Usage: s.usage}
+		if s.last != nil {
+			summary.Model = s.last.Model
+			summary.FinishReason = s.last.FinishReason
+		}
+		endSpan(s.span, summary, nil)
+		s.span = nil
+	}
 }
 
 func NewModels(conf Config) (*Models, error) {
 	providers := map[string]provider.Provider{}
+	providerTypes := map[string]string{}
 	for _, p := range conf.Providers {
 		provider, err := NewProviderFromConfig(p)
 		if err != nil {
 			return nil, err
 		}
 		providers[p.Name] = provider
+		providerTypes[p.Name] = p.Provider
 	}
 
 	models := map[string]*Model{}
@@ -124,13 +299,121 @@ func NewModels(conf Config) (*Models, error) {
 			if err != nil {
 				return nil, err
 			}
+			m.ProviderName = model.Provider
+			m.ProviderType = providerTypes[model.Provider]
+			m.Fallbacks = model.Fallbacks
+			m.Retry = model.Retry
+			m.Price = model.Price
+			m.Cache = model.Cache
+			m.ContextWindow = model.ContextWindow
+			m.ContextPolicy = model.Context
+			switch model.Overflow {
+			case "", OverflowReject, OverflowTrim:
+				m.Overflow = model.Overflow
+			default:
+				return nil, fmt.Errorf("init model %s fail, overflow %s not support", model.Name, model.Overflow)
+			}
 			models[model.Name] = m
 		} else {
 			return nil, fmt.Errorf("init model %s fail, can not find provider %s", model.Name, model.Provider)
 		}
 	}
 
-	return &Models{providers: providers, models: models}, nil
+	cache, err := NewCache(conf.Cache)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := loadTokenizer(conf.Tokenizer); err != nil {
+		return nil, err
+	}
+
+	all := &Models{providers: providers, providerTypes: providerTypes, models: models, groups: map[string]*modelGroup{}, cache: cache}
+	all.contextManagers = all.builtinContextManagers()
+
+	// groups are built before they are registered, so a group can not contain another group
+	groups := map[string]*modelGroup{}
+	for _, conf := range conf.Groups {
+		g, err := all.newModelGroup(conf)
+		if err != nil {
+			return nil, err
+		}
+		groups[g.name] = g
+	}
+	all.groups = groups
+
+	for _, model := range models {
+		for _, fallback := range model.Fallbacks {
+			if _, err := all.GetModel(fallback); err != nil {
+				return nil, fmt.Errorf("init model %s fail, fallback: %w", model.Name, err)
+			}
+		}
+	}
+	for _, model := range models {
+		if model.ContextPolicy != nil && model.ContextPolicy.SummaryModel != "" {
+			if _, err := all.GetModel(model.ContextPolicy.SummaryModel); err != nil {
+				return nil, fmt.Errorf("init model %s fail, summary model: %w", model.Name, err)
+			}
+		}
+	}
+	for _, g := range groups {
+		for _, fallback := range g.fallbacks {
+			if _, err := all.GetModel(fallback); err != nil {
+				return nil, fmt.Errorf("init model group %s fail, fallback: %w", g.name, err)
+			}
+		}
+	}
+
+	return all, nil
+}
+
+// List returns the configured models and groups sorted by name, followed by one
+// "<provider>/*" entry for each provider that accepts passthrough models
+func (m *Models) List() []*ModelInfo {
+	infos := []*ModelInfo{}
+	for _, md := range m.models {
+		infos = append(infos, &ModelInfo{
+			Name:         md.Name,
+			Model:        md.Model,
+			Provider:     md.ProviderName,
+			ProviderType: m.providerTypes[md.ProviderName],
+			MaxToken:     md.maxTokenOrDefault(),
+		})
+	}
+	for _, g := range m.groups {
+		// a group is only as large as its smallest deployment
+		maxToken := int64(0)
+		for _, d := range g.deployments {
+			if maxToken == 0 || d.model.maxTokenOrDefault() < maxToken {
+				maxToken = d.model.maxTokenOrDefault()
+			}
+		}
+		infos = append(infos, &ModelInfo{
+			Name:         g.name,
+			ProviderType: GroupProviderType,
+			MaxToken:     maxToken,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	providerNames := []string{}
+	for name := range m.providers {
+		providerNames = append(providerNames, name)
+	}
+	sort.Strings(providerNames)
+
+	for _, name := range providerNames {
+		infos = append(infos, &ModelInfo{
+			Name:         name + "/*",
+			Model:        "*",
+			Provider:     name,
+			ProviderType: m.providerTypes[name],
+			MaxToken:     DefaultMaxToken,
+			Passthrough:  true,
+		})
+	}
+
+	return infos
 }
 
 func (m *Models) GetModel(name string) (*Model, error) {
@@ -139,13 +422,19 @@ func (m *Models) GetModel(name string) (*Model, error) {
 		return md, nil
 	}
 
+	if g, ok := m.groups[name]; ok {
+		return g.model(), nil
+	}
+
 	items := strings.SplitN(name, "/", 2)
 	if len(items) == 2 {
 		if provider, ok := m.providers[items[0]]; ok {
 			return &Model{
-				Name:     items[1],
-				Model:    items[1],
-				Provider: provider,
+				Name:         items[1],
+				Model:        items[1],
+				Provider:     provider,
+				ProviderName: items[0],
+				ProviderType: m.providerTypes[items[0]],
 			}, nil
 		}
 	}
@@ -160,9 +449,44 @@ func (m *Models) Generate(ctx context.Context, modelName string, messages []*typ
 		return nil, err
 	}
 
+	fitCtx, contextUsage := withContextUsage(ctx)
+	messages, err = m.preflight(fitCtx, modelName, md, messages, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	var completion *types.Completion
+	if md.Cache && m.cache != nil {
+		completion, err = m.generateWithCache(ctx, md, modelName, messages, options...)
+	} else {
+		completion, err = m.generate(ctx, md, messages, options...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// a summary of the context is paid by the request
+	addUsage(&completion.Usage, *contextUsage)
+	return completion, nil
+}
+
+func (m *Models) generate(ctx context.Context, md *Model, messages []*types.Message, options ...types.ChatOption) (*types.Completion, error) {
+	if len(md.Fallbacks) > 0 || md.Retry.MaxAttempts > 1 {
+		return m.generateWithFallback(ctx, md, messages, options...)
+	}
+
 	return md.Generate(ctx, messages, options...)
 }
 
+// Embed returns an embedding of each input, models without embeddings return ErrCapability
+func (m *Models) Embed(ctx context.Context, modelName string, inputs []string, options ...types.EmbedOption) (*types.Embeddings, error) {
+	md, err := m.GetModel(modelName)
+	if err != nil {
+		return nil, err
+	}
+	return md.Embed(ctx, inputs, options...)
+}
+
 func (m *Models) Realtime(ctx context.Context, modelName string, messages []*types.Message, options ...types.RealTimeOption) (types.RealTimeSession, error) {
 	md, err := m.GetModel(modelName)
 	if err != nil {
@@ -0,0 +1,115 @@
+package llmapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/xucx/llmapi/types"
+)
+
+// ObjectValidator is implemented by results of GenerateObject which check themselves
+type ObjectValidator interface {
+	Validate() error
+}
+
+// GenerateObject asks the model for json and decodes it into T, format defaults to json_object.
+// A response which is not json, misses a required property of the schema or fails the Validate
+// of T is sent back to the model once to be repaired.
+func GenerateObject[T any](ctx context.Context, models *Models, modelName string, messages []*types.Message, format *types.ResponseFormat, options ...types.ChatOption) (*T, *types.Completion, error) {
+	if format == nil {
+		format = &types.ResponseFormat{Type: types.ResponseFormatJSONObject}
+	}
+	options = append(options, types.ChatWithResponseFormat(format))
+
+	completion, err := models.Generate(ctx, modelName, messages, options...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	obj, err := decodeObject[T](completion, format)
+	if err == nil {
+		return obj, completion, nil
+	}
+
+	repair := append([]*types.Message{}, messages...)
+	repair = append(repair,
+		types.NewTextMessage(types.MessageRoleAssistant, completionText(completion)),
+		types.NewTextMessage(types.MessageRoleUser, fmt.Sprintf("The response is invalid: %v. Reply with the corrected JSON only.", err)),
+	)
+
+	completion, err = models.Generate(ctx, modelName, repair, options...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	obj, err = decodeObject[T](completion, format)
+	if err != nil {
+		return nil, completion, fmt.Errorf("invalid object: %w", err)
+	}
+	return obj, completion, nil
+}
+
+func decodeObject[T any](completion *types.Completion, format *types.ResponseFormat) (*T, error) {
+	text := strings.TrimSpace(completionText(completion))
+	// json mode of some models still wraps the json in a markdown code block
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(text, "```")
+		text = strings.TrimSpace(text)
+	}
+	if text == "" {
+		return nil, fmt.Errorf("empty response")
+	}
+
+	if required := schemaRequired(format.Schema); len(required) > 0 {
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal([]byte(text), &fields); err != nil {
+			return nil, err
+		}
+		for _, name := range required {
+			if _, ok := fields[name]; !ok {
+				return nil, fmt.Errorf("missing required property %s", name)
+			}
+		}
+	}
+
+	obj := new(T)
+	if err := json.Unmarshal([]byte(text), obj); err != nil {
+		return nil, err
+	}
+
+	if v, ok := any(obj).(ObjectValidator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return obj, nil
+}
+
+func completionText(completion *types.Completion) string {
+	if completion.Message == nil {
+		return ""
+	}
+	return completion.Message.Text()
+}
+
+func schemaRequired(schema map[string]any) []string {
+	switch required := schema["required"].(type) {
+	case []string:
+		return required
+	case []any:
+		names := []string{}
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,85 @@
+package llmapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/xucx/llmapi/types"
+)
+
+type testWeather struct {
+	City        string  `json:"city"`
+	Temperature float64 `json:"temperature"`
+}
+
+func (w *testWeather) Validate() error {
+	if w.Temperature < -100 {
+		return errors.New("temperature below -100")
+	}
+	return nil
+}
+
+// answers replies with the texts in order, the last one again after them
+func answers(texts ...string) func(turn int) *types.Message {
+	return func(turn int) *types.Message {
+		return types.NewTextMessage(types.MessageRoleAssistant, texts[min(turn, len(texts)-1)])
+	}
+}
+
+func TestGenerateObject(t *testing.T) {
+	schema := &types.ResponseFormat{
+		Type:   types.ResponseFormatJSONSchema,
+		Schema: map[string]any{"type": "object", "required": []any{"city", "temperature"}},
+	}
+
+	cases := []struct {
+		name   string
+		reply  func(turn int) *types.Message
+		format *types.ResponseFormat
+		calls  int
+		city   string
+		err    string
+	}{
+		{"valid", answers(`{"city":"Paris","temperature":20}`), schema, 1, "Paris", ""},
+		{"code block", answers("```json\n{\"city\":\"Paris\",\"temperature\":20}\n```"), nil, 1, "Paris", ""},
+		{"repaired json", answers(`{"city":"Paris",`, `{"city":"Paris","temperature":20}`), schema, 2, "Paris", ""},
+		{"repaired required property", answers(`{"city":"Paris"}`, `{"city":"Paris","temperature":20}`), schema, 2, "Paris", ""},
+		{"repaired validation", answers(`{"city":"Paris","temperature":-300}`, `{"city":"Paris","temperature":20}`), schema, 2, "Paris", ""},
+		// repaired once only
+		{"still invalid", answers(`{"city":"Paris"}`), schema, 2, "", "missing required property temperature"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m, p := newScriptModels(t, c.reply)
+			messages := testMessages("weather in Paris as json")
+
+			obj, _, err := GenerateObject[testWeather](context.Background(), m, "s", messages, c.format)
+			if len(p.calls) != c.calls {
+				t.Errorf("%d calls, want %d", len(p.calls), c.calls)
+			}
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("error %v, want %s", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if obj.City != c.city {
+				t.Errorf("city %s, want %s", obj.City, c.city)
+			}
+
+			// the repair gets the invalid response and the reason
+			if c.calls == 2 {
+				sent := p.calls[1]
+				if len(sent) != 3 || sent[1].Role != types.MessageRoleAssistant || !strings.Contains(sent[2].Text(), "The response is invalid") {
+					t.Errorf("repair sent %d messages", len(sent))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package llmapi
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/xucx/llmapi/internal/providers/provider"
+	"github.com/xucx/llmapi/log"
+	"github.com/xucx/llmapi/types"
+)
+
+const (
+	DefaultRetryBackoff    = 500 * time.Millisecond
+	DefaultRetryMaxBackoff = 10 * time.Second
+)
+
+// ErrorClass groups upstream errors, RetryConfig.RetryOn lists the retryable ones
+type ErrorClass = provider.ErrorClass
+
+const (
+	ErrorClassRateLimit       = provider.ErrorClassRateLimit
+	ErrorClassServer          = provider.ErrorClassServer
+	ErrorClassTimeout         = provider.ErrorClassTimeout
+	ErrorClassNetwork         = provider.ErrorClassNetwork
+	ErrorClassContextOverflow = provider.ErrorClassContextOverflow
+	ErrorClassAuth            = provider.ErrorClassAuth
+	ErrorClassInvalid         = provider.ErrorClassInvalid
+	ErrorClassOther           = provider.ErrorClassOther
+)
+
+var (
+	// retry when RetryConfig.RetryOn is empty, context overflow only helps with a fallback so it is opt-in
+	DefaultRetryOn = []ErrorClass{ErrorClassRateLimit, ErrorClassServer, ErrorClassTimeout, ErrorClassNetwork}
+)
+
+// UpstreamError is an error of the provider behind a model, with fallbacks it is the one of the last attempt
+type UpstreamError struct {
+	Model    string // model name in config
+	Provider string // provider name in config
+	Class    ErrorClass
+	Err      error
+}
+
+func (e *UpstreamError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+type RetryConfig struct {
+	MaxAttempts int           `yaml:"maxAttempts"` // attempts on each target, default 1
+	Backoff     time.Duration `yaml:"backoff"`     // wait before the first retry, doubled on each next one
+	MaxBackoff  time.Duration `yaml:"maxBackoff"`
+	RetryOn     []ErrorClass  `yaml:"retryOn"`
+}
+
+func (r RetryConfig) withDefaults() RetryConfig {
+	if r.MaxAttempts < 1 {
+		r.MaxAttempts = 1
+	}
+	if r.Backoff <= 0 {
+		r.Backoff = DefaultRetryBackoff
+	}
+	if r.MaxBackoff <= 0 {
+		r.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if len(r.RetryOn) == 0 {
+		r.RetryOn = DefaultRetryOn
+	}
+	return r
+}
+
+func (r RetryConfig) retryable(class ErrorClass) bool {
+	return slices.Contains(r.RetryOn, class)
+}
+
+// wait sleeps an exponential backoff with equal jitter before the n-th retry
+func (r RetryConfig) wait(ctx context.Context, n int) error {
+	backoff := r.Backoff << n
+	if backoff > r.MaxBackoff || backoff <= 0 {
+		backoff = r.MaxBackoff
+	}
+	backoff = backoff/2 + rand.N(backoff/2+1)
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// generateWithFallback retries the model and then its fallbacks in order,
+// a stream is never retried once a chunk has been passed to the caller
+func (m *Models) generateWithFallback(ctx context.Context, md *Model, messages []*types.Message, options ...types.ChatOption) (*types.Completion, error) {
+	targets := []*Model{md}
+	for _, name := range md.Fallbacks {
+		fallback, err := m.GetModel(name)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, fallback)
+	}
+
+	retry := md.Retry.withDefaults()
+
+	var emitted atomic.Bool
+	opts := *types.GetChatOptions(&types.ChatOptions{}, options...)
+	if streamingFunc := opts.StreamingFunc; streamingFunc != nil {
+		opts.StreamingFunc = func(ctx context.Context, c *types.Completion) error {
+			emitted.Store(true)
+			return streamingFunc(ctx, c)
+		}
+	}
+	if streamingAccFunc := opts.StreamingAccFunc; streamingAccFunc != nil {
+		opts.StreamingAccFunc = func(ctx context.Context, c *types.Completion) error {
+			emitted.Store(true)
+			return streamingAccFunc(ctx, c)
+		}
+	}
+
+	var lastErr error
+	for _, target := range targets {
+		for attempt := 0; attempt < retry.MaxAttempts; attempt++ {
+			if attempt > 0 {
+				if err := retry.wait(ctx, attempt-1); err != nil {
+					return nil, lastErr
+				}
+				// a group retries on the deployment it picks now, not on the failing one
+				target = target.repick()
+			}
+
+			// every attempt gets its own copy, models fill in their own name and max tokens
+			attemptOpts := opts
+			completion, err := target.Generate(ctx, messages, types.ChatWithOptions(&attemptOpts))
+			if err == nil {
+				return completion, nil
+			}
+			lastErr = err
+
+			if emitted.Load() || ctx.Err() != nil {
+				return nil, err
+			}
+
+			class := ErrorClassOther
+			var upstreamErr *UpstreamError
+			if errors.As(err, &upstreamErr) {
+				class = upstreamErr.Class
+			}
+			if !retry.retryable(class) {
+				return nil, err
+			}
+
+			log.Warnw("llm generate fail, retry", "model", target.Name, "attempt", attempt+1, "class", class, "error", err)
+
+			// the same model will overflow again, only a fallback can help
+			if class == ErrorClassContextOverflow {
+				break
+			}
+		}
+	}
+
+	return nil, lastErr
+}
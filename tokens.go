@@ -0,0 +1,177 @@
+package llmapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/xucx/llmapi/internal/providers/provider"
+	"github.com/xucx/llmapi/internal/tokenizer"
+	"github.com/xucx/llmapi/types"
+)
+
+// OverflowPolicy is what Models.Generate does with a request larger than the context window
+type OverflowPolicy string
+
+const (
+	OverflowReject OverflowPolicy = "reject"
+	OverflowTrim   OverflowPolicy = "trim" // drop the oldest turns when the model has no context policy
+)
+
+// TokenizerConfig of the local token counts, without a file they are a heuristic estimate
+type TokenizerConfig struct {
+	Encoding string `yaml:"encoding"` // cl100k_base or o200k_base, default o200k_base
+	File     string `yaml:"file"`     // tiktoken rank file of the encoding
+}
+
+// loadTokenizer sets the encoding of the local token counts, which is shared by every Models
+func loadTokenizer(conf TokenizerConfig) error {
+	if conf.File == "" {
+		return nil
+	}
+
+	name := conf.Encoding
+	if name == "" {
+		name = tokenizer.EncodingO200k
+	}
+	e, err := tokenizer.LoadEncoding(name, conf.File)
+	if err != nil {
+		return fmt.Errorf("init tokenizer fail, %w", err)
+	}
+	tokenizer.SetEncoding(e)
+	return nil
+}
+
+// ErrContextOverflow is returned before dispatch when a request does not fit the context window
+var ErrContextOverflow = errors.New("context window exceeded")
+
+type ContextOverflowError struct {
+	Model  string
+	Tokens int64 // prompt tokens of the request
+	Limit  int64 // context window less the max tokens the request is sent with
+}
+
+func (e *ContextOverflowError) Error() string {
+	return fmt.Sprintf("%v: model %s has %d prompt tokens, limit %d", ErrContextOverflow, e.Model, e.Tokens, e.Limit)
+}
+
+func (e *ContextOverflowError) Unwrap() error {
+	return ErrContextOverflow
+}
+
+// CountTokens counts with the provider when it can, and with the local heuristic otherwise
+func (m *Model) CountTokens(ctx context.Context, messages []*types.Message, options ...types.ChatOption) (*types.TokenCount, error) {
+	optionsWithModel := append(options, types.ChatWithModel(m.Model))
+
+	if counter, ok := m.Provider.(provider.TokenCounter); ok {
+		count, err := counter.CountTokens(ctx, messages, optionsWithModel...)
+		if err == nil {
+			return count, nil
+		}
+		if !errors.Is(err, ErrCapability) {
+			return nil, m.upstreamError(err)
+		}
+	}
+
+	opts := types.GetChatOptions(&types.ChatOptions{}, optionsWithModel...)
+	return &types.TokenCount{
+		Model:       m.Model,
+		InputTokens: tokenizer.EstimateMessages(messages, opts),
+		Estimated:   true,
+	}, nil
+}
+
+// CountTokens returns the prompt tokens of a chat request to the model
+func (m *Models) CountTokens(ctx context.Context, modelName string, messages []*types.Message, options ...types.ChatOption) (*types.TokenCount, error) {
+	md, err := m.GetModel(modelName)
+	if err != nil {
+		return nil, err
+	}
+	return md.CountTokens(ctx, messages, options...)
+}
+
+// preflight fits the messages to the context policy and checks that the request fits the context
+// window of the model, the local estimate is tried first so only requests close to the limit wait
+// for the provider to count them. Estimated counts are only rejected when they are over the limit
+// by more than the margin of the estimate.
+func (m *Models) preflight(ctx context.Context, modelName string, md *Model, messages []*types.Message, options ...types.ChatOption) ([]*types.Message, error) {
+	opts := types.GetChatOptions(&types.ChatOptions{}, options...)
+
+	limit := int64(0)
+	if md.ContextWindow > 0 {
+		// only the max tokens the request is sent with, see Model.Generate
+		completionTokens := md.MaxToken
+		if opts.MaxTokens != nil {
+			completionTokens = *opts.MaxTokens
+		}
+		limit = md.ContextWindow - completionTokens
+	}
+
+	if policy := contextPolicy(modelName, md, opts); policy != nil {
+		budget := policy.MaxTokens
+		if budget <= 0 || (limit > 0 && limit < budget) {
+			budget = limit
+		}
+
+		if budget > 0 && tokenizer.EstimateMessages(messages, opts) > budget {
+			manager, ok := m.contextManagers[policy.Strategy]
+			if !ok {
+				return nil, fmt.Errorf("context strategy %s not support", policy.Strategy)
+			}
+
+			opts.ContextPolicy = policy
+			fitted, err := manager.Fit(ctx, messages, budget, opts)
+			if err != nil {
+				return nil, err
+			}
+			messages = fitted
+		}
+	}
+
+	if limit <= 0 {
+		return messages, nil
+	}
+
+	tokens := tokenizer.EstimateMessages(messages, opts)
+	if tokens <= limit {
+		return messages, nil
+	}
+
+	count, err := md.CountTokens(ctx, messages, options...)
+	if err != nil {
+		return nil, err
+	}
+	tokens = count.InputTokens
+	if tokens <= limit {
+		return messages, nil
+	}
+	if count.Estimated && float64(tokens) <= float64(limit)*(1+tokenizer.Margin) {
+		// the estimate is approximate, a request close to the limit is left to the provider
+		return messages, nil
+	}
+
+	return nil, &ContextOverflowError{Model: md.Name, Tokens: tokens, Limit: limit}
+}
+
+// contextPolicy is the policy of the call, or else the one of the model, with its defaults
+func contextPolicy(modelName string, md *Model, opts *types.ChatOptions) *types.ContextPolicy {
+	policy := opts.ContextPolicy
+	if policy == nil {
+		policy = md.ContextPolicy
+	}
+	if policy == nil {
+		if md.Overflow != OverflowTrim {
+			return nil
+		}
+		policy = &types.ContextPolicy{Strategy: types.ContextStrategyDropOldest}
+	}
+
+	withDefaults := *policy
+	if withDefaults.Strategy == "" {
+		withDefaults.Strategy = types.ContextStrategyDropOldest
+	}
+	if withDefaults.SummaryModel == "" {
+		withDefaults.SummaryModel = modelName
+	}
+	return &withDefaults
+}
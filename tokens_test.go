@@ -0,0 +1,62 @@
+package llmapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/xucx/llmapi/internal/providers/provider"
+	"github.com/xucx/llmapi/internal/tokenizer"
+	"github.com/xucx/llmapi/types"
+)
+
+// countingProvider counts tokens as inputTokens, or can not count when it is negative
+type countingProvider struct {
+	testProvider
+	inputTokens int64
+}
+
+func (p *countingProvider) CountTokens(ctx context.Context, messages []*types.Message, options ...types.ChatOption) (*types.TokenCount, error) {
+	if p.inputTokens < 0 {
+		return nil, provider.CapabilityError(p.name, "count tokens")
+	}
+	return &types.TokenCount{InputTokens: p.inputTokens}, nil
+}
+
+func TestPreflight(t *testing.T) {
+	messages := []*types.Message{types.NewTextMessage(types.MessageRoleUser, strings.Repeat("hello world ", 50))}
+	estimate := tokenizer.EstimateMessages(messages, &types.ChatOptions{})
+
+	cases := []struct {
+		name        string
+		window      int64
+		maxToken    int64
+		options     []types.ChatOption
+		inputTokens int64
+		overflow    bool
+	}{
+		{"estimate fits", estimate, 0, nil, -1, false},
+		{"estimate within the margin", estimate - 1, 0, nil, -1, false},
+		{"estimate over the margin", estimate / 2, 0, nil, -1, true},
+		{"provider count fits", estimate - 1, 0, nil, estimate - 1, false},
+		// a count of the provider is exact, it has no margin
+		{"provider count over", estimate - 1, 0, nil, estimate, true},
+		{"model max token reserved", estimate + 10, 20, nil, estimate, true},
+		{"request max tokens reserved", estimate + 10, 0, []types.ChatOption{types.ChatWithMaxTokens(20)}, estimate, true},
+		{"request max tokens over the model one", estimate + 10, 20, []types.ChatOption{types.ChatWithMaxTokens(10)}, estimate, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := &countingProvider{testProvider: testProvider{name: "a"}, inputTokens: c.inputTokens}
+			m := &Models{models: map[string]*Model{}}
+			md := &Model{Name: "a", Model: "a", Provider: p, ProviderName: "a", MaxToken: c.maxToken, ContextWindow: c.window}
+
+			_, err := m.preflight(context.Background(), "a", md, messages, c.options...)
+			if overflow := errors.Is(err, ErrContextOverflow); overflow != c.overflow {
+				t.Errorf("overflow %v, want %v: %v", overflow, c.overflow, err)
+			}
+		})
+	}
+}
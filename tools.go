@@ -0,0 +1,237 @@
+package llmapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xucx/llmapi/types"
+)
+
+const DefaultToolMaxTurns = 10
+
+var ErrToolMaxTurns = errors.New("tool runner reached max turns")
+
+// ToolFunc runs a tool call, arguments is the json the model sent, the returned
+// string is the result given back to the model
+type ToolFunc func(ctx context.Context, arguments string) (string, error)
+
+// ToolApproveFunc decides whether a tool call runs, the reason of a rejected call
+// is given back to the model as its result
+type ToolApproveFunc func(ctx context.Context, call *types.MessageToolCall) (approved bool, reason string)
+
+type ToolRunnerOption func(*ToolRunnerOptions) *ToolRunnerOptions
+type ToolRunnerOptions struct {
+	MaxTurns int           // model calls of a run, default 10
+	Parallel bool          // run the tool calls of a turn concurrently
+	Timeout  time.Duration // of each tool call, 0 means no timeout
+	Approve  ToolApproveFunc
+}
+
+func ToolRunnerWithMaxTurns(maxTurns int) ToolRunnerOption {
+	return func(opts *ToolRunnerOptions) *ToolRunnerOptions {
+		opts.MaxTurns = maxTurns
+		return opts
+	}
+}
+
+func ToolRunnerWithParallel(parallel bool) ToolRunnerOption {
+	return func(opts *ToolRunnerOptions) *ToolRunnerOptions {
+		opts.Parallel = parallel
+		return opts
+	}
+}
+
+func ToolRunnerWithTimeout(timeout time.Duration) ToolRunnerOption {
+	return func(opts *ToolRunnerOptions) *ToolRunnerOptions {
+		opts.Timeout = timeout
+		return opts
+	}
+}
+
+func ToolRunnerWithApprove(approve ToolApproveFunc) ToolRunnerOption {
+	return func(opts *ToolRunnerOptions) *ToolRunnerOptions {
+		opts.Approve = approve
+		return opts
+	}
+}
+
+// ToolRunner calls a model and runs the tool calls it makes, until it answers without tool calls
+type ToolRunner struct {
+	opts  *ToolRunnerOptions
+	tools []*types.Tool
+	funcs map[string]ToolFunc
+}
+
+// ToolRunResult is the end of a run, also returned with the error of a failed run
+type ToolRunResult struct {
+	Messages   []*types.Message      // assistant and tool messages added by the run
+	Completion *types.Completion     // of the last model call
+	Usage      types.CompletionUsage // of all model calls
+	Turns      int
+}
+
+func NewToolRunner(options ...ToolRunnerOption) *ToolRunner {
+	opts := &ToolRunnerOptions{MaxTurns: DefaultToolMaxTurns}
+	for _, opt := range options {
+		opts = opt(opts)
+	}
+
+	return &ToolRunner{opts: opts, funcs: map[string]ToolFunc{}}
+}
+
+// Register adds a function tool and the func running its calls
+func (r *ToolRunner) Register(tool *types.Tool, fn ToolFunc) error {
+	if tool.Function == nil || tool.Function.Name == "" {
+		return errors.New("tool needs a function name")
+	}
+	if _, ok := r.funcs[tool.Function.Name]; ok {
+		return fmt.Errorf("tool %s already registered", tool.Function.Name)
+	}
+
+	r.tools = append(r.tools, tool)
+	r.funcs[tool.Function.Name] = fn
+	return nil
+}
+
+// Tools are sent with each model call, unless options set other tools
+func (r *ToolRunner) Tools() []*types.Tool {
+	return r.tools
+}
+
+// Run generates with the model of models, the streaming funcs of options get the chunks of every model call
+func (r *ToolRunner) Run(ctx context.Context, models *Models, modelName string, messages []*types.Message, options ...types.ChatOption) (*ToolRunResult, error) {
+	options = append([]types.ChatOption{types.ChatWithTools(r.tools)}, options...)
+
+	result := &ToolRunResult{}
+	history := append([]*types.Message{}, messages...)
+	for {
+		if r.opts.MaxTurns > 0 && result.Turns >= r.opts.MaxTurns {
+			return result, ErrToolMaxTurns
+		}
+		result.Turns++
+
+		completion, err := models.Generate(ctx, modelName, history, options...)
+		if err != nil {
+			return result, err
+		}
+		result.Completion = completion
+		addUsage(&result.Usage, completion.Usage)
+
+		if completion.Message == nil {
+			return result, nil
+		}
+		history = append(history, completion.Message)
+		result.Messages = append(result.Messages, completion.Message)
+
+		calls := completion.Message.ToolCalls()
+		if len(calls) == 0 {
+			return result, nil
+		}
+
+		toolMessage := r.runCalls(ctx, calls)
+		history = append(history, toolMessage)
+		result.Messages = append(result.Messages, toolMessage)
+	}
+}
+
+// runCalls fills Result, Tip and Error of the calls, and returns the tool message of their results
+func (r *ToolRunner) runCalls(ctx context.Context, calls []*types.MessageToolCall) *types.Message {
+	if r.opts.Parallel && len(calls) > 1 {
+		wg := sync.WaitGroup{}
+		for _, call := range calls {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r.runCall(ctx, call)
+			}()
+		}
+		wg.Wait()
+	} else {
+		for _, call := range calls {
+			r.runCall(ctx, call)
+		}
+	}
+
+	msg := types.NewMessage(types.MessageRoleTool)
+	for _, call := range calls {
+		result := call.Result
+		switch {
+		case call.Tip != "":
+			result = call.Tip
+		case call.Error != nil:
+			result = "error: " + call.Error.Error()
+		}
+
+		name := ""
+		if call.Function != nil {
+			name = call.Function.Name
+		}
+		msg.Parts = append(msg.Parts, &types.MessagePart{ToolResult: &types.MessageToolResult{
+			ID:     call.ID,
+			Name:   name,
+			Result: result,
+		}})
+	}
+	return msg
+}
+
+func (r *ToolRunner) runCall(ctx context.Context, call *types.MessageToolCall) {
+	if call.Function == nil {
+		call.Error = errors.New("tool call without function")
+		return
+	}
+
+	fn, ok := r.funcs[call.Function.Name]
+	if !ok {
+		call.Error = fmt.Errorf("tool %s not found", call.Function.Name)
+		return
+	}
+
+	if r.opts.Approve != nil {
+		if approved, reason := r.opts.Approve(ctx, call); !approved {
+			if reason == "" {
+				reason = fmt.Sprintf("tool call %s rejected", call.Function.Name)
+			}
+			call.Tip = reason
+			return
+		}
+	}
+
+	if r.opts.Timeout <= 0 {
+		call.Result, call.Error = fn(ctx, call.Function.Arguments)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+	defer cancel()
+
+	type toolResult struct {
+		result string
+		err    error
+	}
+	done := make(chan toolResult, 1)
+	go func() {
+		result, err := fn(ctx, call.Function.Arguments)
+		done <- toolResult{result, err}
+	}()
+
+	// a func ignoring its context is left behind at the timeout
+	select {
+	case res := <-done:
+		call.Result, call.Error = res.result, res.err
+	case <-ctx.Done():
+		call.Error = fmt.Errorf("tool %s: %w", call.Function.Name, ctx.Err())
+	}
+}
+
+func addUsage(total *types.CompletionUsage, usage types.CompletionUsage) {
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+	total.CachedTokens += usage.CachedTokens
+	total.CacheWriteTokens += usage.CacheWriteTokens
+	total.Cost += usage.Cost
+}
@@ -0,0 +1,206 @@
+package llmapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xucx/llmapi/internal/providers/provider"
+	"github.com/xucx/llmapi/types"
+)
+
+// scriptProvider answers the turn-th call with reply(turn), from 0
+type scriptProvider struct {
+	provider.ProviderNop
+	reply func(turn int) *types.Message
+
+	mu    sync.Mutex
+	calls [][]*types.Message // messages of each call
+}
+
+func (p *scriptProvider) Generate(ctx context.Context, messages []*types.Message, options ...types.ChatOption) (*types.Completion, error) {
+	p.mu.Lock()
+	turn := len(p.calls)
+	p.calls = append(p.calls, messages)
+	p.mu.Unlock()
+
+	return &types.Completion{
+		Message: p.reply(turn),
+		Usage:   types.CompletionUsage{PromptTokens: 10, CompletionTokens: 1, TotalTokens: 11},
+	}, nil
+}
+
+func newScriptModels(t *testing.T, reply func(turn int) *types.Message) (*Models, *scriptProvider) {
+	t.Helper()
+
+	p := &scriptProvider{reply: reply}
+	m := newTestModels(t)
+	m.models["s"] = &Model{Name: "s", Model: "s", Provider: p, ProviderName: "s"}
+	return m, p
+}
+
+// callsMessage is an assistant message calling the tools of names, with ids call-0, call-1...
+func callsMessage(names ...string) *types.Message {
+	msg := types.NewMessage(types.MessageRoleAssistant)
+	for i, name := range names {
+		msg.Parts = append(msg.Parts, &types.MessagePart{ToolCall: &types.MessageToolCall{
+			ID:       "call-" + string(rune('0'+i)),
+			Type:     types.ToolTypeFunction,
+			Index:    i,
+			Function: &types.ToolCallFunction{Name: name, Arguments: `{"city":"Paris"}`},
+		}})
+	}
+	return msg
+}
+
+// callThenAnswer calls the tools of names, then answers
+func callThenAnswer(names ...string) func(turn int) *types.Message {
+	return func(turn int) *types.Message {
+		if turn == 0 {
+			return callsMessage(names...)
+		}
+		return types.NewTextMessage(types.MessageRoleAssistant, "done")
+	}
+}
+
+func TestToolRunner(t *testing.T) {
+	weather := func(ctx context.Context, arguments string) (string, error) {
+		return "sunny in " + arguments, nil
+	}
+	slow := func(ctx context.Context, arguments string) (string, error) {
+		<-ctx.Done()
+		time.Sleep(time.Second) // the runner does not wait for a tool past its timeout
+		return "late", nil
+	}
+
+	cases := []struct {
+		name    string
+		reply   func(turn int) *types.Message
+		fn      ToolFunc
+		options []ToolRunnerOption
+		err     error
+		turns   int
+		result  string // of the first tool call
+	}{
+		{
+			name:   "answer after the tool call",
+			reply:  callThenAnswer("weather"),
+			fn:     weather,
+			turns:  2,
+			result: `sunny in {"city":"Paris"}`,
+		},
+		{
+			name:    "max turns",
+			reply:   func(int) *types.Message { return callsMessage("weather") },
+			fn:      weather,
+			options: []ToolRunnerOption{ToolRunnerWithMaxTurns(3)},
+			err:     ErrToolMaxTurns,
+			turns:   3,
+			result:  `sunny in {"city":"Paris"}`,
+		},
+		{
+			name:  "rejected call",
+			reply: callThenAnswer("weather"),
+			fn: func(ctx context.Context, arguments string) (string, error) {
+				t.Error("rejected tool called")
+				return "", nil
+			},
+			options: []ToolRunnerOption{ToolRunnerWithApprove(func(ctx context.Context, call *types.MessageToolCall) (bool, string) {
+				return false, "not allowed"
+			})},
+			turns:  2,
+			result: "not allowed",
+		},
+		{
+			name:    "timeout",
+			reply:   callThenAnswer("weather"),
+			fn:      slow,
+			options: []ToolRunnerOption{ToolRunnerWithTimeout(10 * time.Millisecond)},
+			turns:   2,
+			result:  "error: tool weather: context deadline exceeded",
+		},
+		{
+			name:   "unknown tool",
+			reply:  callThenAnswer("other"),
+			fn:     weather,
+			turns:  2,
+			result: "error: tool other not found",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m, p := newScriptModels(t, c.reply)
+			runner := NewToolRunner(c.options...)
+			if err := runner.Register(types.NewFunctionTool("weather", "", nil), c.fn); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := runner.Run(context.Background(), m, "s", testMessages("weather in Paris?"))
+			if !errors.Is(err, c.err) {
+				t.Fatalf("run: %v, want %v", err, c.err)
+			}
+			if result.Turns != c.turns || len(p.calls) != c.turns {
+				t.Errorf("%d turns and %d calls, want %d", result.Turns, len(p.calls), c.turns)
+			}
+			if result.Usage.PromptTokens != int64(10*c.turns) {
+				t.Errorf("usage %+v of %d turns", result.Usage, c.turns)
+			}
+
+			// the second call gets the call and its result
+			sent := p.calls[1]
+			if len(sent) != 3 || sent[2].Role != types.MessageRoleTool {
+				t.Fatalf("second call sent %d messages", len(sent))
+			}
+			if got := sent[2].Parts[0].ToolResult; got.ID != "call-0" || got.Result != c.result {
+				t.Errorf("tool result %+v, want %q", got, c.result)
+			}
+		})
+	}
+}
+
+func TestToolRunnerParallel(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		m, p := newScriptModels(t, callThenAnswer("a", "b"))
+
+		// each tool waits for the other one to start
+		started := sync.WaitGroup{}
+		started.Add(2)
+		both := make(chan struct{})
+		go func() {
+			started.Wait()
+			close(both)
+		}()
+		fn := func(ctx context.Context, arguments string) (string, error) {
+			started.Done()
+			select {
+			case <-both:
+				return "together", nil
+			case <-time.After(50 * time.Millisecond):
+				return "alone", nil
+			}
+		}
+
+		runner := NewToolRunner(ToolRunnerWithParallel(parallel))
+		runner.Register(types.NewFunctionTool("a", "", nil), fn)
+		runner.Register(types.NewFunctionTool("b", "", nil), fn)
+		if _, err := runner.Run(context.Background(), m, "s", testMessages("go")); err != nil {
+			t.Fatal(err)
+		}
+
+		results := []string{}
+		for _, part := range p.calls[1][2].Parts {
+			results = append(results, part.ToolResult.ID+"="+part.ToolResult.Result)
+		}
+		want := "call-0=alone call-1=together"
+		if parallel {
+			want = "call-0=together call-1=together"
+		}
+		if got := strings.Join(results, " "); got != want {
+			t.Errorf("parallel %v: results %s, want %s", parallel, got, want)
+		}
+	}
+}
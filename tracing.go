@@ -0,0 +1,63 @@
+package llmapi
+
+import (
+	"context"
+
+	"github.com/xucx/llmapi/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/xucx/llmapi"
+
+// gen_ai.system of the provider types, others use the provider type itself
+var genAISystems = map[string]attribute.KeyValue{
+	"openai":    semconv.GenAISystemOpenAI,
+	"anthropic": semconv.GenAISystemAnthropic,
+	"google":    semconv.GenAISystemGemini,
+}
+
+// startSpan starts the span of a provider call, spans are dropped unless the
+// application sets an otel tracer provider
+func (m *Model) startSpan(ctx context.Context, operation attribute.KeyValue) (context.Context, trace.Span) {
+	system, ok := genAISystems[m.ProviderType]
+	if !ok {
+		system = semconv.GenAISystemKey.String(m.ProviderType)
+	}
+
+	return otel.Tracer(tracerName).Start(ctx, operation.Value.AsString()+" "+m.Model,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			operation,
+			system,
+			semconv.GenAIRequestModel(m.Model),
+			attribute.String("llmapi.model", m.Name),
+			attribute.String("llmapi.provider", m.ProviderName),
+		),
+	)
+}
+
+func endSpan(span trace.Span, completion *types.Completion, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	if completion != nil {
+		span.SetAttributes(
+			semconv.GenAIUsageInputTokens(int(completion.Usage.PromptTokens)),
+			semconv.GenAIUsageOutputTokens(int(completion.Usage.CompletionTokens)),
+		)
+		if completion.Model != "" {
+			span.SetAttributes(semconv.GenAIResponseModel(completion.Model))
+		}
+		if completion.FinishReason != "" {
+			span.SetAttributes(semconv.GenAIResponseFinishReasons(string(completion.FinishReason)))
+		}
+	}
+
+	span.End()
+}
@@ -9,8 +9,15 @@ import (
 )
 
 type Tool struct {
-	Type     ToolType
-	Function *ToolFunction
+	Type         ToolType
+	Function     *ToolFunction
+	CacheControl *CacheControl // caches the tools up to this one
+}
+
+// CacheControl marks the end of a prompt prefix the provider should cache, anthropic caches
+// up to the marked block, gemini up to the message of the marked part
+type CacheControl struct {
+	TTL string `json:"ttl,omitempty" yaml:"ttl,omitempty"` // 5m or 1h, default 5m
 }
 
 type ToolType string
@@ -36,6 +43,118 @@ func NewFunctionTool(name, desc string, params map[string]any) *Tool {
 	}
 }
 
+type ToolChoiceType string
+
+const (
+	ToolChoiceAuto     ToolChoiceType = "auto"
+	ToolChoiceNone     ToolChoiceType = "none"
+	ToolChoiceRequired ToolChoiceType = "required" // any tool, "any" of anthropic
+	ToolChoiceFunction ToolChoiceType = "function" // the function of Name
+)
+
+type ToolChoice struct {
+	Type ToolChoiceType
+	Name string
+}
+
+func NewFunctionToolChoice(name string) *ToolChoice {
+	return &ToolChoice{Type: ToolChoiceFunction, Name: name}
+}
+
+type ReasoningEffort string
+
+const (
+	ReasoningEffortMinimal ReasoningEffort = "minimal"
+	ReasoningEffortLow     ReasoningEffort = "low"
+	ReasoningEffortMedium  ReasoningEffort = "medium"
+	ReasoningEffortHigh    ReasoningEffort = "high"
+)
+
+// thinking tokens of the effort levels, for providers which take a budget
+var reasoningEffortBudgets = map[ReasoningEffort]int64{
+	ReasoningEffortMinimal: 1024,
+	ReasoningEffortLow:     2048,
+	ReasoningEffortMedium:  8192,
+	ReasoningEffortHigh:    24576,
+}
+
+// Reasoning controls the thinking of reasoning models, set either BudgetTokens or Effort
+type Reasoning struct {
+	Enabled         bool // false turns thinking off, or down to the minimal effort where it can not be off
+	BudgetTokens    int64
+	Effort          ReasoningEffort
+	IncludeThoughts bool // return the thoughts, anthropic always does
+}
+
+func NewReasoning(effort ReasoningEffort) *Reasoning {
+	return &Reasoning{Enabled: true, Effort: effort, IncludeThoughts: true}
+}
+
+// Budget is BudgetTokens or the budget of Effort, 0 means none was set
+func (r *Reasoning) Budget() int64 {
+	if r.BudgetTokens > 0 {
+		return r.BudgetTokens
+	}
+	return reasoningEffortBudgets[r.Effort]
+}
+
+// EffortLevel is Effort or the level closest to BudgetTokens, empty means none was set
+func (r *Reasoning) EffortLevel() ReasoningEffort {
+	if r.Effort != "" || r.BudgetTokens <= 0 {
+		return r.Effort
+	}
+
+	for _, effort := range []ReasoningEffort{ReasoningEffortMinimal, ReasoningEffortLow, ReasoningEffortMedium} {
+		if r.BudgetTokens <= reasoningEffortBudgets[effort] {
+			return effort
+		}
+	}
+	return ReasoningEffortHigh
+}
+
+type ResponseFormatType string
+
+const (
+	ResponseFormatText       ResponseFormatType = "text"
+	ResponseFormatJSONObject ResponseFormatType = "json_object"
+	ResponseFormatJSONSchema ResponseFormatType = "json_schema"
+)
+
+// ResponseFormat asks for json output, Name, Description, Schema and Strict are for json_schema
+type ResponseFormat struct {
+	Type        ResponseFormatType
+	Name        string
+	Description string
+	Schema      map[string]any
+	Strict      bool
+}
+
+func NewJSONSchemaResponseFormat(name string, schema map[string]any, strict bool) *ResponseFormat {
+	return &ResponseFormat{
+		Type:   ResponseFormatJSONSchema,
+		Name:   name,
+		Schema: schema,
+		Strict: strict,
+	}
+}
+
+type ContextStrategy string
+
+const (
+	ContextStrategyDropOldest    ContextStrategy = "drop_oldest"    // drop the oldest turns
+	ContextStrategySlidingWindow ContextStrategy = "sliding_window" // keep the newest messages which fit
+	ContextStrategySummarize     ContextStrategy = "summarize"      // replace the older turns with a summary
+)
+
+// ContextPolicy fits long conversations into a budget of prompt tokens before Models.Generate
+// sends them, system messages and tool calls with their results are always kept together
+type ContextPolicy struct {
+	Strategy     ContextStrategy `yaml:"strategy"`
+	MaxTokens    int64           `yaml:"maxTokens"`    // budget of the prompt, zero is the context window less the completion
+	KeepTurns    int             `yaml:"keepTurns"`    // newest turns the summary leaves as they are, default 4
+	SummaryModel string          `yaml:"summaryModel"` // model writing the summary, default the model itself
+}
+
 type MessageRole string
 
 const (
@@ -152,6 +271,9 @@ type MessagePart struct {
 	ToolCall         *MessageToolCall         `json:"toolcall,omitempty" yaml:"toolcall,omitempty"`
 	ToolResult       *MessageToolResult       `json:"toolresult,omitempty" yaml:"toolresult,omitempty"`
 	RealtimeResponse *MessageRealtimeResponse `json:"realtimeresponse,omitempty" yaml:"realtimeresponse,omitempty"`
+
+	// with any of the above
+	CacheControl *CacheControl `json:"cachecontrol,omitempty" yaml:"cachecontrol,omitempty"`
 }
 
 func NewTextMessage(role MessageRole, text string) *Message {
@@ -174,6 +296,7 @@ type MessageRefusal struct {
 	Text string `json:"text,omitempty" yaml:"text,omitempty"`
 }
 
+// MessageImageURL is an image by url, inline images are data urls, see NewImageDataURL
 type MessageImageURL struct {
 	URL string `json:"url,omitempty" yaml:"url,omitempty"`
 	// Any of "auto", "low", "high".
@@ -181,6 +304,34 @@ type MessageImageURL struct {
 	Format string `json:"format,omitempty" yaml:"format,omitempty"`
 }
 
+// NewImageDataURL is an inline image of base64 data
+func NewImageDataURL(mimeType, data string) *MessageImageURL {
+	return &MessageImageURL{URL: DataURL(mimeType, data)}
+}
+
+// DataURL is the data url of base64 data
+func DataURL(mimeType, data string) string {
+	return "data:" + mimeType + ";base64," + data
+}
+
+// ParseDataURL returns the mime type and the base64 data of a data url, ok is false
+// for other urls and for data urls which are not base64
+func ParseDataURL(url string) (mimeType string, data string, ok bool) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return "", "", false
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	if !ok {
+		return "", "", false
+	}
+	mimeType, ok = strings.CutSuffix(meta, ";base64")
+	if !ok {
+		return "", "", false
+	}
+	return mimeType, data, true
+}
+
 type MessageAudio struct {
 	ID         string `json:"id,omitempty" yaml:"id,omitempty"`
 	Data       string `json:"data,omitempty" yaml:"data,omitempty"`     // base64 encoded audio data.
@@ -199,6 +350,7 @@ type MessageToolCall struct {
 	ID       string            `json:"id,omitempty" yaml:"id,omitempty"`
 	Type     ToolType          `json:"type,omitempty" yaml:"type,omitempty"`
 	Function *ToolCallFunction `json:"function,omitempty" yaml:"function,omitempty"`
+	Index    int               `json:"index,omitempty" yaml:"index,omitempty"`
 	Result   string            `json:"-" yaml:"-"` //option
 	Tip      string            `json:"-" yaml:"-"` //option
 	Error    error             `json:"-" yaml:"-"` //option
@@ -219,30 +371,51 @@ type MessageRealtimeResponse struct {
 }
 
 type Completion struct {
-	Delta   bool
-	Model   string
-	Message *Message
-	Usage   CompletionUsage
+	Delta        bool
+	Model        string
+	Message      *Message
+	Usage        CompletionUsage
+	FinishReason FinishReason
+	Provider     string // name of the provider in config which served the completion
 }
 
+type FinishReason string
+
+const (
+	FinishReasonStop          FinishReason = "stop"
+	FinishReasonLength        FinishReason = "length"
+	FinishReasonToolCalls     FinishReason = "tool_calls"
+	FinishReasonContentFilter FinishReason = "content_filter"
+	FinishReasonTruncated     FinishReason = "truncated"   // stream ended before the completion finished
+	FinishReasonInterrupted   FinishReason = "interrupted" // realtime response cut off by the user speaking
+)
+
 type CompletionUsage struct {
 	PromptTokens     int64
 	CompletionTokens int64
 	TotalTokens      int64
+	CachedTokens     int64   // part of PromptTokens read from the provider cache
+	CacheWriteTokens int64   // part of PromptTokens written to the provider cache
+	Cost             float64 // USD, set by llmapi.Model from its price
 }
 
 type ChatOption func(*ChatOptions) *ChatOptions
 type ChatOptions struct {
-	Model            string
-	Instructions     string
-	Tools            []*Tool
-	StreamingFunc    ChatStreamingFunc
-	StreamingAccFunc ChatStreamingFunc
-	Temperature      *float32
-	TopP             *float32
-	TopK             *int
-	MaxTokens        *int64
-	StopSequences    []string
+	Model             string
+	Instructions      string
+	Tools             []*Tool
+	StreamingFunc     ChatStreamingFunc `json:"-"`
+	StreamingAccFunc  ChatStreamingFunc `json:"-"`
+	Temperature       *float32
+	TopP              *float32
+	TopK              *int
+	MaxTokens         *int64
+	StopSequences     []string
+	ResponseFormat    *ResponseFormat
+	ToolChoice        *ToolChoice
+	ParallelToolCalls *bool // whether the model may call several tools at once
+	Reasoning         *Reasoning
+	ContextPolicy     *ContextPolicy // of Models.Generate, providers ignore it
 	// Modalities    []Modality
 	AudioVoice AudioVoiceType
 }
@@ -281,6 +454,86 @@ func ChatWithTools(tools []*Tool) ChatOption {
 	}
 }
 
+func ChatWithTemperature(temperature float32) ChatOption {
+	return func(opts *ChatOptions) *ChatOptions {
+		opts.Temperature = &temperature
+		return opts
+	}
+}
+
+func ChatWithTopP(topP float32) ChatOption {
+	return func(opts *ChatOptions) *ChatOptions {
+		opts.TopP = &topP
+		return opts
+	}
+}
+
+func ChatWithTopK(topK int) ChatOption {
+	return func(opts *ChatOptions) *ChatOptions {
+		opts.TopK = &topK
+		return opts
+	}
+}
+
+func ChatWithMaxTokens(maxTokens int64) ChatOption {
+	return func(opts *ChatOptions) *ChatOptions {
+		opts.MaxTokens = &maxTokens
+		return opts
+	}
+}
+
+// ChatWithDefaultMaxTokens only sets MaxTokens when no one set it before
+func ChatWithDefaultMaxTokens(maxTokens int64) ChatOption {
+	return func(opts *ChatOptions) *ChatOptions {
+		if opts.MaxTokens == nil {
+			opts.MaxTokens = &maxTokens
+		}
+		return opts
+	}
+}
+
+func ChatWithStopSequences(stopSequences []string) ChatOption {
+	return func(opts *ChatOptions) *ChatOptions {
+		opts.StopSequences = stopSequences
+		return opts
+	}
+}
+
+func ChatWithToolChoice(choice *ToolChoice) ChatOption {
+	return func(opts *ChatOptions) *ChatOptions {
+		opts.ToolChoice = choice
+		return opts
+	}
+}
+
+func ChatWithParallelToolCalls(parallel bool) ChatOption {
+	return func(opts *ChatOptions) *ChatOptions {
+		opts.ParallelToolCalls = &parallel
+		return opts
+	}
+}
+
+func ChatWithReasoning(reasoning *Reasoning) ChatOption {
+	return func(opts *ChatOptions) *ChatOptions {
+		opts.Reasoning = reasoning
+		return opts
+	}
+}
+
+func ChatWithContextPolicy(policy *ContextPolicy) ChatOption {
+	return func(opts *ChatOptions) *ChatOptions {
+		opts.ContextPolicy = policy
+		return opts
+	}
+}
+
+func ChatWithResponseFormat(format *ResponseFormat) ChatOption {
+	return func(opts *ChatOptions) *ChatOptions {
+		opts.ResponseFormat = format
+		return opts
+	}
+}
+
 func GetChatOptions(def *ChatOptions, opts ...ChatOption) *ChatOptions {
 	if def == nil {
 		def = &ChatOptions{}
@@ -376,3 +629,51 @@ type RealTimeSession interface {
 	Recv(context.Context) (*Completion, error)
 	Close()
 }
+
+type EmbedOption func(*EmbedOptions) *EmbedOptions
+type EmbedOptions struct {
+	Model      string
+	Dimensions *int64 // of the output, for models which can shorten their embeddings
+}
+
+func EmbedWithModel(model string) EmbedOption {
+	return func(opts *EmbedOptions) *EmbedOptions {
+		opts.Model = model
+		return opts
+	}
+}
+
+func EmbedWithDimensions(dimensions int64) EmbedOption {
+	return func(opts *EmbedOptions) *EmbedOptions {
+		opts.Dimensions = &dimensions
+		return opts
+	}
+}
+
+func GetEmbedOptions(def *EmbedOptions, opts ...EmbedOption) *EmbedOptions {
+	if def == nil {
+		def = &EmbedOptions{}
+	}
+	for _, opt := range opts {
+		def = opt(def)
+	}
+	return def
+}
+
+type Embedding struct {
+	Index  int // of the input
+	Vector []float32
+}
+
+type Embeddings struct {
+	Model      string
+	Embeddings []*Embedding // in the order of the inputs
+	Usage      CompletionUsage
+	Provider   string // name of the provider in config which served the embeddings
+}
+
+type TokenCount struct {
+	Model       string
+	InputTokens int64 // of the messages, instructions and tools
+	Estimated   bool  // a heuristic estimate of the local tokenizer instead of a count of the provider
+}